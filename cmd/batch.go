@@ -0,0 +1,89 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/pflag"
+
+	"github.com/hypershift-community/hyper-console/pkg/batch"
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/recipes"
+)
+
+// runBatch runs a recipe in several environments without the TUI and prints the
+// summary of the runs as JSON. It returns the exit code: 1 if any run failed, 2 if
+// the batch couldn't be run.
+//
+// Example:
+//
+//	hyperdev batch --recipe show-hosted-clusters --env dev,stage,prod --parallel 2
+func runBatch(cfg *config.Config, args []string) int {
+	flags := pflag.NewFlagSet("batch", pflag.ContinueOnError)
+	recipe := flags.String("recipe", "", "name of the recipe to run")
+	envs := flags.StringSlice("env", nil, "environments to run the recipe in, in order")
+	parallel := flags.Int("parallel", 1, "maximum number of environments the recipe runs in at the same time")
+	applyEnvWrites := flags.Bool("apply-env-writes", false, "apply the changes the recipe makes to each environment without confirmation, they are left pending in the run history otherwise")
+	flags.StringVar(&cfg.RecipesDir, "recipes-dir", cfg.RecipesDir, "directory of the recipes")
+	flags.StringVar(&cfg.EnvironmentsDir, "environments-dir", cfg.EnvironmentsDir, "directory of the environments")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *recipe == "" || len(*envs) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: hyperdev batch --recipe NAME --env ENV[,ENV...] [--parallel N] [--apply-env-writes]")
+		flags.PrintDefaults()
+		return 2
+	}
+	r, err := findRecipe(cfg.RecipesDir, *recipe)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 2
+	}
+
+	// Interrupting kills the current step of the runs, which end once their
+	// deferred commands ran
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	summary := batch.New(r, *envs, batch.Options{Config: cfg, Parallelism: *parallel, ApplyEnvWrites: *applyEnvWrites}).Run(ctx)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(summary); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing summary:", err)
+		return 2
+	}
+	if summary.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func findRecipe(dir, name string) (recipes.Recipe, error) {
+	all, err := recipes.GetRecipes(dir)
+	if err != nil {
+		return recipes.Recipe{}, err
+	}
+	for _, r := range all {
+		if r.Name == name {
+			return r, nil
+		}
+	}
+	return recipes.Recipe{}, fmt.Errorf("recipe %s not found in %s", name, dir)
+}
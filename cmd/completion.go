@@ -0,0 +1,337 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hypershift-community/hyper-console/pkg/api"
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/supervisor"
+	"github.com/hypershift-community/hyper-console/pkg/taskexec"
+)
+
+//go:embed completion/bash/hyperdev.bash
+var completionBash string
+
+//go:embed completion/fish/hyperdev.fish
+var completionFish string
+
+//go:embed completion/zsh/_hyperdev
+var completionZsh string
+
+// runCompletion prints the completion script of the shell. The scripts call back
+// into hyperdev, see runComplete, so that they complete the recipes, tasks and
+// environments of the time.
+//
+// Example:
+//
+//	source <(hyperdev completion bash)
+//	hyperdev completion fish | source
+func runCompletion(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: hyperdev completion bash|zsh|fish")
+		return 2
+	}
+	switch args[0] {
+	case "bash":
+		fmt.Print(completionBash)
+	case "zsh":
+		fmt.Print(completionZsh)
+	case "fish":
+		fmt.Print(completionFish)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown shell %s, expected bash, zsh or fish\n", args[0])
+		return 2
+	}
+	return 0
+}
+
+// completion is what a word completes to.
+type completion int
+
+const (
+	// completeNothing is for the values which aren't completed.
+	completeNothing completion = iota
+	// completeFiles leaves the completion of the paths to the shell.
+	completeFiles
+	completeRecipes
+	// completeTasks completes the tasks of the recipe given as first argument.
+	completeTasks
+	completeEnvironments
+	// completeParams completes the parameters of the recipe given with --recipe.
+	completeParams
+	completeRuns
+	completeFormats
+	completeShells
+)
+
+// commandSpec describes the flags and arguments of a command for its completion.
+type commandSpec struct {
+	subcommands map[string]*commandSpec
+	// flags are the flags taking a value, and what the value completes to.
+	flags map[string]completion
+	// switches are the boolean flags.
+	switches []string
+	// args are what the arguments complete to, the last one for the remaining
+	// arguments.
+	args []completion
+}
+
+var (
+	recipesDirFlag      = map[string]completion{"--recipes-dir": completeFiles}
+	environmentsDirFlag = map[string]completion{"--environments-dir": completeFiles}
+	socketFlag          = map[string]completion{"--socket": completeFiles}
+	outputFlag          = map[string]completion{"-o": completeFormats, "--output": completeFormats}
+)
+
+// commands are the commands of the CLI, see main.
+var commands = map[string]*commandSpec{
+	"batch": {flags: merge(recipesDirFlag, environmentsDirFlag, map[string]completion{
+		"--recipe": completeRecipes, "--env": completeEnvironments, "--parallel": completeNothing,
+	}), switches: []string{"--apply-env-writes"}},
+	"supervisor": {flags: merge(socketFlag, recipesDirFlag, environmentsDirFlag, map[string]completion{
+		"--history-dir": completeFiles, "--workspaces-dir": completeFiles,
+	})},
+	"runs": {subcommands: map[string]*commandSpec{
+		"start": {
+			flags: merge(socketFlag, recipesDirFlag, environmentsDirFlag, map[string]completion{
+				"--recipe": completeRecipes, "--env": completeEnvironments, "--param": completeParams,
+			}),
+			switches: []string{"--detach"},
+		},
+		"list":   {flags: socketFlag, switches: []string{"--all"}},
+		"attach": {flags: socketFlag, args: []completion{completeRuns, completeNothing}},
+		"abort":  {flags: socketFlag, args: []completion{completeRuns, completeNothing}},
+	}},
+	"serve": {flags: merge(socketFlag, recipesDirFlag, environmentsDirFlag, map[string]completion{
+		"--listen": completeNothing, "--token": completeNothing, "--token-file": completeFiles,
+	})},
+	"recipes": {subcommands: map[string]*commandSpec{
+		"list":  {flags: merge(recipesDirFlag, outputFlag), args: []completion{completeNothing}},
+		"tasks": {flags: merge(recipesDirFlag, outputFlag), args: []completion{completeRecipes, completeTasks}},
+	}},
+	"env": {subcommands: map[string]*commandSpec{
+		"list": {flags: merge(environmentsDirFlag, outputFlag), args: []completion{completeNothing}},
+		"show": {flags: merge(environmentsDirFlag, outputFlag), args: []completion{completeEnvironments, completeNothing}},
+	}},
+	"completion": {args: []completion{completeShells, completeNothing}},
+}
+
+func merge(flags ...map[string]completion) map[string]completion {
+	merged := make(map[string]completion)
+	for _, f := range flags {
+		maps.Copy(merged, f)
+	}
+	return merged
+}
+
+// candidate is a completion of the word, with its description.
+type candidate struct {
+	value       string
+	description string
+}
+
+// runComplete prints the completions of the last of the words, the arguments of
+// hyperdev up to the word completed, one per line and followed by a tab and
+// their description if they have one. It prints nothing when the shell should
+// complete the paths.
+//
+// Example:
+//
+//	hyperdev __complete runs start --recipe hyper
+func runComplete(cfg *config.Config, words []string) int {
+	for _, c := range complete(cfg, words) {
+		if c.description == "" {
+			fmt.Println(c.value)
+			continue
+		}
+		fmt.Printf("%s\t%s\n", c.value, oneLine(c.description))
+	}
+	return 0
+}
+
+func complete(cfg *config.Config, words []string) []candidate {
+	if len(words) == 0 {
+		return nil
+	}
+	cur := words[len(words)-1]
+	words = words[:len(words)-1]
+	if len(words) == 0 {
+		return matching(cur, slices.Collect(maps.Keys(commands)))
+	}
+	spec, ok := commands[words[0]]
+	if !ok {
+		return nil
+	}
+	words = words[1:]
+	if spec.subcommands != nil {
+		if len(words) == 0 {
+			return matching(cur, slices.Collect(maps.Keys(spec.subcommands)))
+		}
+		if spec, ok = spec.subcommands[words[0]]; !ok {
+			return nil
+		}
+		words = words[1:]
+	}
+
+	// The values of the flags given before tell where the recipes and the
+	// environments are, and which recipe the parameters are of
+	values := make(map[string]string)
+	var args []string
+	var pending string
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		if name, value, ok := strings.Cut(w, "="); ok && strings.HasPrefix(w, "-") {
+			values[name] = value
+			continue
+		}
+		if _, ok := spec.flags[w]; ok {
+			if i+1 == len(words) {
+				pending = w
+			} else {
+				values[w] = words[i+1]
+				i++
+			}
+			continue
+		}
+		if !strings.HasPrefix(w, "-") {
+			args = append(args, w)
+		}
+	}
+	if dir, ok := values["--recipes-dir"]; ok {
+		cfg.RecipesDir = dir
+	}
+	if dir, ok := values["--environments-dir"]; ok {
+		cfg.EnvironmentsDir = dir
+	}
+	if socket, ok := values["--socket"]; ok {
+		cfg.SupervisorSocket = socket
+	}
+
+	switch {
+	case pending != "":
+		return completeValues(cfg, spec.flags[pending], cur, values, args)
+	case strings.HasPrefix(cur, "-"):
+		if name, value, ok := strings.Cut(cur, "="); ok {
+			candidates := completeValues(cfg, spec.flags[name], value, values, args)
+			for i := range candidates {
+				candidates[i].value = name + "=" + candidates[i].value
+			}
+			return candidates
+		}
+		return matching(cur, append(slices.Collect(maps.Keys(spec.flags)), spec.switches...))
+	case len(spec.args) > 0:
+		return completeValues(cfg, spec.args[min(len(args), len(spec.args)-1)], cur, values, args)
+	}
+	return nil
+}
+
+// completeValues returns the values of the kind which start with the prefix.
+func completeValues(cfg *config.Config, kind completion, prefix string, values map[string]string, args []string) []candidate {
+	var candidates []candidate
+	switch kind {
+	case completeRecipes:
+		list, _ := api.Recipes(cfg.RecipesDir)
+		for _, r := range list {
+			candidates = append(candidates, candidate{r.Name, r.DisplayName})
+		}
+	case completeTasks:
+		r, err := findRecipe(cfg.RecipesDir, args[0])
+		if err != nil {
+			return nil
+		}
+		tf, _ := taskexec.ListTasks(r.Dir)
+		if tf == nil {
+			return nil
+		}
+		for _, t := range tf.Tasks {
+			candidates = append(candidates, candidate{t.Name, t.Desc})
+		}
+	case completeEnvironments:
+		list, _ := api.Environments(cfg.EnvironmentsDir)
+		for _, e := range list {
+			candidates = append(candidates, candidate{e.Name, e.Description})
+		}
+	case completeParams:
+		// Completed up to the = of the parameter, the values are left to the user
+		if strings.Contains(prefix, "=") {
+			return nil
+		}
+		r, err := findRecipe(cfg.RecipesDir, values["--recipe"])
+		if err != nil {
+			return nil
+		}
+		names, _ := taskexec.EnvNames(r.Dir)
+		for _, in := range r.Inputs {
+			names = append(names, in.Outputs...)
+		}
+		slices.Sort(names)
+		for _, name := range slices.Compact(names) {
+			candidates = append(candidates, candidate{value: name + "="})
+		}
+	case completeRuns:
+		// The supervisor isn't started just to complete, there are no runs if it
+		// isn't running
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		runs, _ := supervisor.NewClient(supervisor.Socket(cfg)).List(ctx)
+		for _, r := range runs {
+			desc := r.Recipe
+			if r.Environment != "" {
+				desc += " @ " + r.Environment
+			}
+			candidates = append(candidates, candidate{r.ID, desc + ", " + string(r.Status)})
+		}
+	case completeFormats:
+		candidates = plainCandidates("table", "json", "yaml")
+	case completeShells:
+		candidates = plainCandidates("bash", "zsh", "fish")
+	}
+	var matched []candidate
+	for _, c := range candidates {
+		if strings.HasPrefix(c.value, prefix) {
+			matched = append(matched, c)
+		}
+	}
+	return matched
+}
+
+// matching returns the names which start with the prefix, sorted.
+func matching(prefix string, names []string) []candidate {
+	slices.Sort(names)
+	var matched []candidate
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			matched = append(matched, candidate{value: name})
+		}
+	}
+	return matched
+}
+
+func plainCandidates(values ...string) []candidate {
+	candidates := make([]candidate, len(values))
+	for i, v := range values {
+		candidates[i] = candidate{value: v}
+	}
+	return candidates
+}
@@ -0,0 +1,38 @@
+# bash completion for hyperdev.
+#
+# Load it in the current shell with:
+#
+#   source <(hyperdev completion bash)
+#
+# The completions come from `hyperdev __complete`, which is given the words up to
+# the one completed. When it has none, the paths are completed.
+
+_hyperdev()
+{
+  local cur words cword
+  if declare -F _init_completion > /dev/null; then
+    # Keep --flag=value and names with colons as single words
+    _init_completion -n =: || return
+  else
+    words=("${COMP_WORDS[@]}")
+    cword=$COMP_CWORD
+    cur=${COMP_WORDS[COMP_CWORD]}
+  fi
+
+  local IFS=$'\n'
+  COMPREPLY=( $( "${words[0]}" __complete "${words[@]:1:cword}" 2> /dev/null | cut -f1 ) )
+
+  # Bash replaces the part of the word after the last = or : only
+  local prefix
+  if [[ $cur == *[=:]* && $COMP_WORDBREAKS == *=* ]]; then
+    prefix=${cur%[=:]*}
+    COMPREPLY=( "${COMPREPLY[@]#"$prefix"?}" )
+  fi
+
+  # The parameters are completed up to their =, for the value to follow
+  if [[ ${#COMPREPLY[@]} -eq 1 && ${COMPREPLY[0]} == *= ]]; then
+    compopt -o nospace
+  fi
+}
+
+complete -o default -F _hyperdev hyperdev
@@ -0,0 +1,24 @@
+# fish completion for hyperdev.
+#
+# Load it in the current shell with:
+#
+#   hyperdev completion fish | source
+#
+# or write it as hyperdev.fish to ~/.config/fish/completions. The completions come
+# from `hyperdev __complete`, which is given the words up to the one completed.
+# When it has none, the paths are completed.
+
+function __hyperdev_complete
+    set -l words (commandline -opc)
+    set -l bin $words[1]
+    set -e words[1]
+    set -l cur (commandline -ct)
+    set -l completions ($bin __complete $words "$cur" 2>/dev/null)
+    if test (count $completions) -eq 0
+        __fish_complete_path "$cur"
+        return
+    end
+    printf '%s\n' $completions
+end
+
+complete -c hyperdev -f -a '(__hyperdev_complete)'
@@ -0,0 +1,239 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+
+	"github.com/hypershift-community/hyper-console/pkg/api"
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/env"
+	"github.com/hypershift-community/hyper-console/pkg/task"
+	"github.com/hypershift-community/hyper-console/pkg/taskexec"
+)
+
+const recipesUsage = `Usage:
+  hyperdev recipes list [-o table|json|yaml]
+  hyperdev recipes tasks RECIPE [TASK...] [-o table|json|yaml]`
+
+const envUsage = `Usage:
+  hyperdev env list [-o table|json|yaml]
+  hyperdev env show ENV [-o table|json|yaml]`
+
+// environmentDetails is an environment as shown by env show.
+type environmentDetails struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Variables are the variables of the environment, with the values of the
+	// secrets masked.
+	Variables map[string]string `json:"variables"`
+}
+
+// runRecipes lists the recipes, or the tasks of the Taskfile of a recipe in the
+// shape of `task --list --json`, all of them or the ones named. It returns the
+// exit code, 2 if the command failed.
+//
+// Example:
+//
+//	hyperdev recipes list -o json
+//	hyperdev recipes tasks hypershift-ci-mgmt-cluster -o yaml
+func runRecipes(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, recipesUsage)
+		return 2
+	}
+	flags := pflag.NewFlagSet("recipes "+args[0], pflag.ContinueOnError)
+	flags.StringVar(&cfg.RecipesDir, "recipes-dir", cfg.RecipesDir, "directory of the recipes")
+	output := flags.StringP("output", "o", "table", "output format: table, json or yaml")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	var err error
+	switch {
+	case args[0] == "list" && flags.NArg() == 0:
+		var list []api.Recipe
+		if list, err = api.Recipes(cfg.RecipesDir); err == nil {
+			err = printOutput(*output, list, func(w io.Writer) {
+				fmt.Fprintln(w, "NAME\tDISPLAY NAME\tENVIRONMENT\tDESCRIPTION")
+				for _, r := range list {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name, r.DisplayName, r.Environment, oneLine(r.Description))
+				}
+			})
+		}
+	case args[0] == "tasks" && flags.NArg() >= 1:
+		err = printTasks(cfg, flags.Arg(0), flags.Args()[1:], *output)
+	default:
+		fmt.Fprintln(os.Stderr, recipesUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 2
+	}
+	return 0
+}
+
+func printTasks(cfg *config.Config, recipe string, names []string, output string) error {
+	r, err := findRecipe(cfg.RecipesDir, recipe)
+	if err != nil {
+		return err
+	}
+	tf, err := taskexec.ListTasks(r.Dir)
+	if err != nil {
+		return err
+	}
+	if len(names) > 0 {
+		all := tf.Tasks
+		tf.Tasks = tf.Tasks[:0:0]
+		for _, name := range names {
+			i := slices.IndexFunc(all, func(t task.EditorTask) bool {
+				return t.Name == name || slices.Contains(t.Aliases, name)
+			})
+			if i < 0 {
+				return fmt.Errorf("task %s not found in recipe %s", name, recipe)
+			}
+			tf.Tasks = append(tf.Tasks, all[i])
+		}
+	}
+	return printOutput(output, tf, func(w io.Writer) {
+		fmt.Fprintln(w, "TASK\tALIASES\tDESCRIPTION")
+		for _, t := range tf.Tasks {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", t.Name, strings.Join(t.Aliases, ","), oneLine(t.Desc))
+		}
+	})
+}
+
+// runEnv lists the environments, or shows the variables of one with the values
+// of the secrets masked. It returns the exit code, 2 if the command failed.
+//
+// Example:
+//
+//	hyperdev env list
+//	hyperdev env show dev -o json
+func runEnv(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, envUsage)
+		return 2
+	}
+	flags := pflag.NewFlagSet("env "+args[0], pflag.ContinueOnError)
+	flags.StringVar(&cfg.EnvironmentsDir, "environments-dir", cfg.EnvironmentsDir, "directory of the environments")
+	output := flags.StringP("output", "o", "table", "output format: table, json or yaml")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	var err error
+	switch {
+	case args[0] == "list" && flags.NArg() == 0:
+		var list []api.Environment
+		if list, err = api.Environments(cfg.EnvironmentsDir); err == nil {
+			err = printOutput(*output, list, func(w io.Writer) {
+				fmt.Fprintln(w, "NAME\tVARIABLES\tDESCRIPTION")
+				for _, e := range list {
+					fmt.Fprintf(w, "%s\t%d\t%s\n", e.Name, len(e.Variables), oneLine(e.Description))
+				}
+			})
+		}
+	case args[0] == "show" && flags.NArg() == 1:
+		err = printEnvironment(cfg, flags.Arg(0), *output)
+	default:
+		fmt.Fprintln(os.Stderr, envUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 2
+	}
+	return 0
+}
+
+func printEnvironment(cfg *config.Config, name, output string) error {
+	all, err := env.LoadAll(cfg.EnvironmentsDir)
+	if err != nil {
+		return err
+	}
+	e, ok := all[name]
+	if !ok {
+		return fmt.Errorf("environment %s not found", name)
+	}
+	details := environmentDetails{Name: e.Name, Description: e.Description, Variables: e.MaskedVars()}
+	return printOutput(output, details, func(w io.Writer) {
+		names := make([]string, 0, len(details.Variables))
+		for k := range details.Variables {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		fmt.Fprintln(w, "VARIABLE\tVALUE")
+		for _, k := range names {
+			fmt.Fprintf(w, "%s\t%s\n", k, oneLine(details.Variables[k]))
+		}
+	})
+}
+
+// printOutput prints the value as JSON, as YAML in the same shape as its JSON,
+// or as the table written by the function.
+func printOutput(format string, v any, table func(w io.Writer)) error {
+	switch format {
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		table(w)
+		return w.Flush()
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		// Going through JSON keeps its field names and order, the YAML of the
+		// JSON is then written in the block style
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return err
+		}
+		blockStyle(&node)
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(&node); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	return fmt.Errorf("unknown output format %s, expected table, json or yaml", format)
+}
+
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
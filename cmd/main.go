@@ -21,13 +21,31 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/scrollback"
 	"github.com/hypershift-community/hyper-console/pkg/tui"
 )
 
 func main() {
-	cfg := &config.Config{
-		RecipesDir:      "examples/recipes",
-		EnvironmentsDir: "examples/environments",
+	cfg := newConfig()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "batch":
+			os.Exit(runBatch(cfg, os.Args[2:]))
+		case "supervisor":
+			os.Exit(runSupervisor(cfg, os.Args[2:]))
+		case "runs":
+			os.Exit(runRuns(cfg, os.Args[2:]))
+		case "serve":
+			os.Exit(runServe(cfg, os.Args[2:]))
+		case "recipes":
+			os.Exit(runRecipes(cfg, os.Args[2:]))
+		case "env":
+			os.Exit(runEnv(cfg, os.Args[2:]))
+		case "completion":
+			os.Exit(runCompletion(os.Args[2:]))
+		case "__complete":
+			os.Exit(runComplete(cfg, os.Args[2:]))
+		}
 	}
 	p := tea.NewProgram(tui.NewModel(cfg), tea.WithAltScreen())
 
@@ -38,3 +56,16 @@ func main() {
 	}
 
 }
+
+func newConfig() *config.Config {
+	return &config.Config{
+		RecipesDir:       "examples/recipes",
+		EnvironmentsDir:  "examples/environments",
+		WorkflowsDir:     "examples/workflows",
+		HistoryDir:       config.DefaultHistoryDir(),
+		ScrollbackLines:  scrollback.DefaultMaxLines,
+		ScrollbackDir:    os.TempDir(),
+		WorkspacesDir:    config.DefaultWorkspacesDir(),
+		SupervisorSocket: config.DefaultSupervisorSocket(),
+	}
+}
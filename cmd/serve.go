@@ -0,0 +1,97 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/hypershift-community/hyper-console/pkg/api"
+	"github.com/hypershift-community/hyper-console/pkg/config"
+)
+
+// tokenEnvVar is the environment variable holding the token of the API.
+const tokenEnvVar = "HYPERDEV_API_TOKEN"
+
+// runServe serves the HTTP API until it is interrupted or terminated. The runs it
+// starts are those of the supervisor, which keep running after it stops. Without
+// a token one is generated and written to the token file, for the integrations
+// to read it.
+//
+// Example:
+//
+//	hyperdev serve --listen 127.0.0.1:8470
+//	hyperdev serve --listen unix:/run/user/1000/hyperdev/api.sock
+func runServe(cfg *config.Config, args []string) int {
+	flags := pflag.NewFlagSet("serve", pflag.ContinueOnError)
+	flags.StringVar(&cfg.RecipesDir, "recipes-dir", cfg.RecipesDir, "directory of the recipes")
+	flags.StringVar(&cfg.EnvironmentsDir, "environments-dir", cfg.EnvironmentsDir, "directory of the environments")
+	flags.StringVar(&cfg.SupervisorSocket, "socket", cfg.SupervisorSocket, "unix socket of the supervisor")
+	listen := flags.String("listen", "127.0.0.1:8470", `loopback address to listen on, or "unix:" followed by the path of a unix socket`)
+	token := flags.String("token", os.Getenv(tokenEnvVar), "token the requests must carry, generated when empty (defaults to $"+tokenEnvVar+")")
+	tokenFile := flags.String("token-file", config.DefaultAPITokenFile(), "file the token is written to")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *token == "" {
+		var err error
+		if *token, err = api.NewToken(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 1
+		}
+	}
+	if err := api.WriteToken(*tokenFile, *token); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing the token:", err)
+		return 1
+	}
+	l, err := api.Listen(*listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+
+	// The output of the runs is streamed for as long as they run, hence no
+	// write timeout
+	srv := &http.Server{
+		Handler:           api.NewServer(cfg, *token),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		// The streams of output don't end before the runs, they are cut
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			_ = srv.Close()
+		}
+	}()
+	fmt.Printf("API listening on %s, token written to %s\n", *listen, *tokenFile)
+	if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	return 0
+}
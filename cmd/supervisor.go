@@ -0,0 +1,190 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/history"
+	"github.com/hypershift-community/hyper-console/pkg/supervisor"
+)
+
+// runSupervisor runs the supervisor in the foreground until it is interrupted or
+// terminated, which aborts the runs and waits for their deferred commands. The
+// clients start it in the background when it isn't running.
+//
+// Example:
+//
+//	hyperdev supervisor --socket /run/user/1000/hyperdev/supervisor.sock
+func runSupervisor(cfg *config.Config, args []string) int {
+	flags := pflag.NewFlagSet("supervisor", pflag.ContinueOnError)
+	flags.StringVar(&cfg.SupervisorSocket, "socket", cfg.SupervisorSocket, "unix socket to listen on")
+	flags.StringVar(&cfg.RecipesDir, "recipes-dir", cfg.RecipesDir, "directory of the recipes")
+	flags.StringVar(&cfg.EnvironmentsDir, "environments-dir", cfg.EnvironmentsDir, "directory of the environments")
+	flags.StringVar(&cfg.HistoryDir, "history-dir", cfg.HistoryDir, "directory of the run history")
+	flags.StringVar(&cfg.WorkspacesDir, "workspaces-dir", cfg.WorkspacesDir, "directory of the workspaces of the isolated recipes")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	l, err := supervisor.Listen(cfg.SupervisorSocket)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Println("Supervisor listening on", cfg.SupervisorSocket)
+	if err := supervisor.NewServer(cfg).Serve(ctx, l); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	return 0
+}
+
+const runsUsage = `Usage:
+  hyperdev runs start --recipe NAME [--env ENV] [--param KEY=VALUE]... [--detach]
+  hyperdev runs list [--all]
+  hyperdev runs attach ID
+  hyperdev runs abort ID`
+
+// runRuns manages the runs of the supervisor, starting it if it isn't running. It
+// returns the exit code: 1 if the run attached to didn't succeed, 2 if the command
+// failed.
+//
+// Example:
+//
+//	hyperdev runs start --recipe hypershift-ci-mgmt-cluster --env dev --param REPLICAS=2 --detach
+//	hyperdev runs attach 20241105-101500-1a2b3c
+func runRuns(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, runsUsage)
+		return 2
+	}
+	flags := pflag.NewFlagSet("runs "+args[0], pflag.ContinueOnError)
+	flags.StringVar(&cfg.SupervisorSocket, "socket", cfg.SupervisorSocket, "unix socket of the supervisor")
+	flags.StringVar(&cfg.RecipesDir, "recipes-dir", cfg.RecipesDir, "directory of the recipes")
+	flags.StringVar(&cfg.EnvironmentsDir, "environments-dir", cfg.EnvironmentsDir, "directory of the environments")
+	recipe := flags.String("recipe", "", "name of the recipe to start")
+	env := flags.String("env", "", "environment to start the recipe in")
+	params := flags.StringArray("param", nil, "output exposed to the commands of the recipe as KEY=VALUE, overriding its Taskfile env (repeatable)")
+	detach := flags.Bool("detach", false, "start the run without attaching to its output")
+	all := flags.Bool("all", false, "list the runs which ended too")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	inputs := make(map[string]string, len(*params))
+	for _, p := range *params {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok || k == "" {
+			fmt.Fprintf(os.Stderr, "Error: invalid parameter %q, expected KEY=VALUE\n", p)
+			return 2
+		}
+		inputs[k] = v
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	c, err := supervisor.Ensure(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 2
+	}
+	switch {
+	case args[0] == "start" && *recipe != "":
+		info, err := c.Start(ctx, *recipe, *env, inputs)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 2
+		}
+		fmt.Fprintln(os.Stderr, "Started run", info.ID)
+		if *detach {
+			fmt.Println(info.ID)
+			return 0
+		}
+		return attach(c, info.ID)
+	case args[0] == "list":
+		runs, err := c.List(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 2
+		}
+		printRuns(runs, *all)
+		return 0
+	case args[0] == "attach" && flags.NArg() == 1:
+		return attach(c, flags.Arg(0))
+	case args[0] == "abort" && flags.NArg() == 1:
+		info, err := c.Abort(ctx, flags.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 2
+		}
+		fmt.Fprintf(os.Stderr, "Aborting run %s, its deferred commands still run\n", info.ID)
+		return 0
+	}
+	fmt.Fprintln(os.Stderr, runsUsage)
+	return 2
+}
+
+// attach streams the output of the run until it ends. Interrupting detaches from
+// the run, which keeps running.
+func attach(c *supervisor.Client, id string) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	info, err := c.Attach(ctx, id, os.Stdout)
+	switch {
+	case errors.Is(err, context.Canceled):
+		fmt.Fprintf(os.Stderr, "\nDetached, the run keeps running. Attach again with: hyperdev runs attach %s\n", id)
+		return 0
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 2
+	}
+	fmt.Fprintf(os.Stderr, "Run %s %s in %s\n", info.ID, info.Status, info.Duration().Round(time.Second))
+	if info.Error != "" {
+		fmt.Fprintln(os.Stderr, "Error:", info.Error)
+	}
+	if info.Status != history.StatusSucceeded {
+		return 1
+	}
+	return 0
+}
+
+func printRuns(runs []supervisor.RunInfo, all bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tRECIPE\tENVIRONMENT\tSTATUS\tSTEP\tDURATION")
+	for _, r := range runs {
+		if !all && !r.Active() {
+			continue
+		}
+		step := "-"
+		if r.Steps > 0 {
+			step = fmt.Sprintf("%d/%d", r.Step+1, r.Steps)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.ID, r.Recipe, r.Environment, r.Status, step, r.Duration().Round(time.Second))
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,214 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/history"
+	"github.com/hypershift-community/hyper-console/pkg/supervisor"
+)
+
+const token = "secret"
+
+// serve serves the API over a supervisor running a recipe echoing its input, and
+// returns the URL of the API.
+func serve(t *testing.T) string {
+	t.Helper()
+	recipesDir := t.TempDir()
+	dir := filepath.Join(recipesDir, "check")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "info.yaml"),
+		[]byte("name: check\ndisplay-name: Check\ntimeout: 1m\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "taskfile.yaml"), []byte(`version: '3'
+tasks:
+  default:
+    cmds:
+      - echo "hello $GREETING"
+`), 0o644))
+	envDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(envDir, "dev"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(envDir, "dev", "env.hcl"),
+		[]byte("_INFO_DESCRIPTION = \"Development\"\nTOKEN = \"hidden\"\n"), 0o644))
+
+	// The path of a unix socket is limited to about a hundred characters
+	socketDir, err := os.MkdirTemp("", "api")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(socketDir) })
+	cfg := &config.Config{
+		RecipesDir:       recipesDir,
+		EnvironmentsDir:  envDir,
+		HistoryDir:       t.TempDir(),
+		SupervisorSocket: filepath.Join(socketDir, "s.sock"),
+	}
+
+	// The supervisor isn't running yet
+	srv := httptest.NewServer(NewServer(cfg, token))
+	t.Cleanup(srv.Close)
+	var runs []supervisor.RunInfo
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, srv.URL+"/v1/runs", "", &runs))
+	require.Empty(t, runs)
+
+	l, err := supervisor.Listen(cfg.SupervisorSocket)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- supervisor.NewServer(cfg).Serve(ctx, l)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+	return srv.URL
+}
+
+// call sends the request with the token and decodes the response into v.
+func call(t *testing.T, method, url, body string, v any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+	return resp.StatusCode
+}
+
+func TestAPI_Authorization(t *testing.T) {
+	url := serve(t)
+
+	resp, err := http.Get(url + "/v1/recipes")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	req, err := http.NewRequest(http.MethodGet, url+"/v1/recipes", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer wrong")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, err = http.Get(url + "/v1/recipes?token=" + token)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The description of the API needs no token
+	resp, err = http.Get(url + "/v1/openapi.yaml")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	spec, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(spec), "/v1/runs/{id}/output:")
+}
+
+func TestAPI_RecipesAndEnvironments(t *testing.T) {
+	url := serve(t)
+
+	var recipes []Recipe
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, url+"/v1/recipes", "", &recipes))
+	require.Equal(t, []Recipe{{Name: "check", DisplayName: "Check", Timeout: "1m0s"}}, recipes)
+
+	var envs []Environment
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, url+"/v1/environments", "", &envs))
+	require.Equal(t, []Environment{{Name: "dev", Description: "Development", Variables: []string{"TOKEN"}}}, envs)
+}
+
+func TestAPI_Run(t *testing.T) {
+	url := serve(t)
+
+	var e Error
+	require.Equal(t, http.StatusBadRequest, call(t, http.MethodPost, url+"/v1/runs", `{}`, &e))
+	require.Equal(t, http.StatusUnprocessableEntity, call(t, http.MethodPost, url+"/v1/runs", `{"recipe":"missing"}`, &e))
+	require.Contains(t, e.Error, "recipe missing not found")
+	require.Equal(t, http.StatusUnprocessableEntity, call(t, http.MethodPost, url+"/v1/runs", `{"recipe":"check","environment":"missing"}`, &e))
+	require.Contains(t, e.Error, "environment missing not found")
+	require.Equal(t, http.StatusUnprocessableEntity, call(t, http.MethodPost, url+"/v1/runs", `{"recipe":"check","environment":"../dev"}`, &e))
+	require.Equal(t, http.StatusNotFound, call(t, http.MethodGet, url+"/v1/runs/missing", "", &e))
+
+	var info supervisor.RunInfo
+	require.Equal(t, http.StatusCreated, call(t, http.MethodPost, url+"/v1/runs",
+		`{"recipe":"check","inputs":{"GREETING":"world"}}`, &info))
+	require.Equal(t, "check", info.Recipe)
+
+	// The output is streamed until the run ends
+	req, err := http.NewRequest(http.MethodGet, url+"/v1/runs/"+info.ID+"/output", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	var output strings.Builder
+	var final supervisor.RunInfo
+	var event string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data := []byte(strings.TrimPrefix(line, "data: "))
+			switch event {
+			case "output":
+				var chunk map[string]string
+				require.NoError(t, json.Unmarshal(data, &chunk))
+				output.WriteString(chunk["output"])
+			case "done":
+				require.NoError(t, json.Unmarshal(data, &final))
+			default:
+				t.Fatalf("unexpected event %s: %s", event, data)
+			}
+		}
+	}
+	require.NoError(t, scanner.Err())
+	require.Contains(t, output.String(), "hello world\n")
+	require.Equal(t, history.StatusSucceeded, final.Status)
+
+	var got supervisor.RunInfo
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, url+"/v1/runs/"+info.ID, "", &got))
+	require.Equal(t, history.StatusSucceeded, got.Status)
+	var runs []supervisor.RunInfo
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, url+"/v1/runs", "", &runs))
+	require.Len(t, runs, 1)
+}
+
+func TestListen(t *testing.T) {
+	_, err := Listen("0.0.0.0:0")
+	require.ErrorContains(t, err, "only loopback addresses or unix sockets are allowed")
+
+	l, err := Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+}
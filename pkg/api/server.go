@@ -0,0 +1,325 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package api serves a local HTTP API for the editors and scripts integrating
+// with hyperdev: it lists the recipes and the environments, and starts, lists,
+// aborts and streams the output of runs.
+//
+// The runs are those of the supervisor, started if needed, so they outlive the
+// API server like the runs started from the CLI or the TUI, which the API lists
+// too. Their output is streamed as Server-Sent Events. Every request but the
+// one of the OpenAPI description needs the token of the server, and the server
+// only listens on the loopback interface or a unix socket.
+package api
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/env"
+	"github.com/hypershift-community/hyper-console/pkg/recipes"
+	"github.com/hypershift-community/hyper-console/pkg/supervisor"
+)
+
+//go:embed openapi.yaml
+var openAPI []byte
+
+// startTimeout bounds starting the supervisor and a run in it.
+const startTimeout = 15 * time.Second
+
+// Recipe describes a recipe.
+type Recipe struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Environment is the environment the recipe runs in by default.
+	Environment string `json:"environment,omitempty"`
+	// Timeout is the timeout of a run of the recipe, as a Go duration.
+	Timeout string `json:"timeout,omitempty"`
+	// Inputs are the recipes whose outputs the recipe uses.
+	Inputs []string `json:"inputs,omitempty"`
+}
+
+// Environment describes an environment. The values of its variables aren't
+// exposed, they may be credentials.
+type Environment struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Variables   []string `json:"variables"`
+}
+
+// StartRequest is the body of a request starting a run.
+type StartRequest struct {
+	Recipe      string `json:"recipe"`
+	Environment string `json:"environment,omitempty"`
+	// Inputs are outputs exposed to the commands of the recipe, see
+	// runner.Options.
+	Inputs map[string]string `json:"inputs,omitempty"`
+}
+
+// Error is the body of the responses of the failed requests.
+type Error struct {
+	Error string `json:"error"`
+}
+
+// Server serves the API.
+type Server struct {
+	cfg   *config.Config
+	token string
+	mux   *http.ServeMux
+}
+
+// NewServer creates the server of the API, accepting the requests which carry
+// the token.
+func NewServer(cfg *config.Config, token string) *Server {
+	s := &Server{cfg: cfg, token: token, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /v1/openapi.yaml", s.openAPI)
+	s.mux.Handle("GET /v1/recipes", s.authorize(s.listRecipes))
+	s.mux.Handle("GET /v1/environments", s.authorize(s.listEnvironments))
+	s.mux.Handle("GET /v1/runs", s.authorize(s.listRuns))
+	s.mux.Handle("POST /v1/runs", s.authorize(s.startRun))
+	s.mux.Handle("GET /v1/runs/{id}", s.authorize(s.getRun))
+	s.mux.Handle("GET /v1/runs/{id}/output", s.authorize(s.streamOutput))
+	s.mux.Handle("POST /v1/runs/{id}/abort", s.authorize(s.abortRun))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) openAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(openAPI)
+}
+
+func (s *Server) listRecipes(w http.ResponseWriter, _ *http.Request) {
+	list, err := Recipes(s.cfg.RecipesDir)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) listEnvironments(w http.ResponseWriter, _ *http.Request) {
+	list, err := Environments(s.cfg.EnvironmentsDir)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// Recipes returns the recipes of the directory as the API describes them.
+func Recipes(dir string) ([]Recipe, error) {
+	all, err := recipes.GetRecipes(dir)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Recipe, 0, len(all))
+	for _, r := range all {
+		recipe := Recipe{
+			Name:        r.Name,
+			DisplayName: r.DisplayName,
+			Description: r.Description,
+			Environment: r.Environment,
+		}
+		if r.Timeout > 0 {
+			recipe.Timeout = r.Timeout.String()
+		}
+		for _, in := range r.Inputs {
+			recipe.Inputs = append(recipe.Inputs, in.Recipe)
+		}
+		list = append(list, recipe)
+	}
+	return list, nil
+}
+
+// Environments returns the environments of the directory as the API describes
+// them, sorted by name.
+func Environments(dir string) ([]Environment, error) {
+	all, err := env.LoadAll(dir)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Environment, 0, len(all))
+	for name, e := range all {
+		vars := make([]string, 0, len(e.Vars))
+		for k := range e.Vars {
+			vars = append(vars, k)
+		}
+		sort.Strings(vars)
+		list = append(list, Environment{Name: name, Description: e.Description, Variables: vars})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// listRuns lists the runs of the supervisor. It isn't started just to list
+// them, there are no runs if it isn't running.
+func (s *Server) listRuns(w http.ResponseWriter, r *http.Request) {
+	runs, err := s.client().List(r.Context())
+	if err != nil && !errors.Is(err, supervisor.ErrNotRunning) {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	if runs == nil {
+		runs = []supervisor.RunInfo{}
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
+func (s *Server) startRun(w http.ResponseWriter, r *http.Request) {
+	var req StartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	if req.Recipe == "" {
+		writeError(w, http.StatusBadRequest, errors.New("the recipe is missing"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), startTimeout)
+	defer cancel()
+	c, err := supervisor.Ensure(ctx, s.cfg)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	info, err := c.Start(ctx, req.Recipe, req.Environment, req.Inputs)
+	if err != nil {
+		// The supervisor only refuses the runs of unknown recipes or environments
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	w.Header().Set("Location", "/v1/runs/"+info.ID)
+	writeJSON(w, http.StatusCreated, info)
+}
+
+func (s *Server) getRun(w http.ResponseWriter, r *http.Request) {
+	if info, ok := s.findRun(w, r); ok {
+		writeJSON(w, http.StatusOK, info)
+	}
+}
+
+func (s *Server) abortRun(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.findRun(w, r); !ok {
+		return
+	}
+	info, err := s.client().Abort(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, info)
+}
+
+// streamOutput streams the output of the run as Server-Sent Events, from the
+// start of the run until it ends or the client goes away: "output" events carry
+// chunks of the output, then a "done" event carries the run once it ended, or an
+// "error" event the error streaming the output.
+func (s *Server) streamOutput(w http.ResponseWriter, r *http.Request) {
+	info, ok := s.findRun(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming isn't supported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	events := &eventWriter{w: w, flusher: flusher}
+	final, err := s.client().Attach(r.Context(), info.ID, events)
+	switch {
+	case r.Context().Err() != nil:
+		// The client went away, the run keeps running
+	case err != nil:
+		events.send("error", Error{Error: err.Error()})
+	default:
+		events.send("done", final)
+	}
+}
+
+// findRun returns the run whose ID is in the path, writing the error response
+// if there is no such run.
+func (s *Server) findRun(w http.ResponseWriter, r *http.Request) (supervisor.RunInfo, bool) {
+	id := r.PathValue("id")
+	runs, err := s.client().List(r.Context())
+	if err != nil && !errors.Is(err, supervisor.ErrNotRunning) {
+		writeError(w, http.StatusBadGateway, err)
+		return supervisor.RunInfo{}, false
+	}
+	for _, info := range runs {
+		if info.ID == id {
+			return info, true
+		}
+	}
+	writeError(w, http.StatusNotFound, fmt.Errorf("unknown run %s", id))
+	return supervisor.RunInfo{}, false
+}
+
+func (s *Server) client() *supervisor.Client {
+	return supervisor.NewClient(supervisor.Socket(s.cfg))
+}
+
+// eventWriter writes what is written to it as "output" events.
+type eventWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (e *eventWriter) Write(p []byte) (int, error) {
+	if err := e.send("output", map[string]string{"output": string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// send sends an event whose data is the value as JSON, which holds on a single
+// line as the data of an event must.
+func (e *eventWriter) send(event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	e.flusher.Flush()
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, Error{Error: err.Error()})
+}
@@ -0,0 +1,298 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package batch runs a recipe in several environments, one after the other or with
+// bounded parallelism, e.g. to verify a change across dev, stage and prod at once.
+// The runs are unattended, see the runner package.
+package batch
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/history"
+	"github.com/hypershift-community/hyper-console/pkg/logging"
+	"github.com/hypershift-community/hyper-console/pkg/recipes"
+	"github.com/hypershift-community/hyper-console/pkg/runner"
+	"github.com/hypershift-community/hyper-console/pkg/scrollback"
+)
+
+var Logger = logging.Logger
+
+// StatusPending is the status of the runs which haven't started yet.
+const StatusPending history.Status = "pending"
+
+// OutputLines is the number of lines of the output of each step kept in the
+// results, the older ones are dropped.
+const OutputLines = 1000
+
+// Result is the outcome of the run of the recipe in an environment.
+type Result struct {
+	Environment string         `json:"environment"`
+	RunID       string         `json:"runId"`
+	Status      history.Status `json:"status"`
+	StartedAt   time.Time      `json:"startedAt,omitempty"`
+	FinishedAt  time.Time      `json:"finishedAt,omitempty"`
+	Steps       []history.Step `json:"steps"`
+	Error       string         `json:"error,omitempty"`
+	// Output is the output of each step, by index, up to its last OutputLines lines.
+	Output []string `json:"-"`
+}
+
+// Duration returns how long the run took so far.
+func (r Result) Duration() time.Duration {
+	switch {
+	case r.StartedAt.IsZero():
+		return 0
+	case r.FinishedAt.IsZero():
+		return time.Since(r.StartedAt)
+	}
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// Summary is the outcome of a batch, as emitted by the CLI.
+type Summary struct {
+	Recipe      string   `json:"recipe"`
+	Parallelism int      `json:"parallelism"`
+	Succeeded   int      `json:"succeeded"`
+	Failed      int      `json:"failed"`
+	Results     []Result `json:"results"`
+}
+
+// Options configures a batch.
+type Options struct {
+	Config *config.Config
+	// Parallelism is the maximum number of environments the recipe runs in at the
+	// same time. The environments are run one after the other when it is 1 or less.
+	// Runs at the same time are always isolated, each in its own workspace, as
+	// they would otherwise share the files their commands generate.
+	Parallelism int
+	// ApplyEnvWrites applies the changes the recipe makes to each environment once
+	// its run succeeded, see runner.Options.
+	ApplyEnvWrites bool
+}
+
+// Batch runs a recipe in several environments.
+type Batch struct {
+	recipe  recipes.Recipe
+	opts    Options
+	history *history.Store
+
+	mu      sync.Mutex
+	results []Result
+	// outputs are the outputs of the steps of each run, by index.
+	outputs [][]*scrollback.Buffer
+	updates chan string
+	done    chan struct{}
+}
+
+// New creates a batch running the recipe in the given environments, in order.
+func New(recipe recipes.Recipe, environments []string, opts Options) *Batch {
+	b := &Batch{
+		recipe:  recipe,
+		opts:    opts,
+		results: make([]Result, len(environments)),
+		outputs: make([][]*scrollback.Buffer, len(environments)),
+		// Updates are dropped rather than blocking the runs when nobody reads them
+		updates: make(chan string, 64),
+		done:    make(chan struct{}),
+	}
+	if opts.Config != nil && opts.Config.HistoryDir != "" {
+		b.history = history.NewStore(opts.Config.HistoryDir)
+	}
+	for i, e := range environments {
+		b.results[i] = Result{Environment: e, Status: StatusPending}
+	}
+	return b
+}
+
+// Updates returns the channel the name of an environment is sent to whenever the
+// result of its run changes. It is closed once the batch is done.
+func (b *Batch) Updates() <-chan string {
+	return b.updates
+}
+
+// Done returns a channel closed once the batch is done.
+func (b *Batch) Done() <-chan struct{} {
+	return b.done
+}
+
+// Run runs the recipe in every environment and returns the summary of the batch.
+// Cancelling the context stops starting new runs and kills the current step of the
+// running ones, which end once their deferred commands ran.
+func (b *Batch) Run(ctx context.Context) Summary {
+	defer close(b.done)
+	defer close(b.updates)
+	sem := make(chan struct{}, max(b.opts.Parallelism, 1))
+	var wg sync.WaitGroup
+	for i := range b.results {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			b.update(i, func(r *Result) {
+				r.Status = history.StatusAborted
+				r.Error = ctx.Err().Error()
+			})
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			b.run(ctx, i)
+		}()
+	}
+	wg.Wait()
+	return b.Summary()
+}
+
+// Results returns a snapshot of the results of the runs.
+func (b *Batch) Results() []Result {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	results := make([]Result, len(b.results))
+	for i, r := range b.results {
+		r.Steps = append([]history.Step(nil), r.Steps...)
+		if outputs := b.outputs[i]; outputs != nil {
+			r.Output = make([]string, len(outputs))
+			for step, out := range outputs {
+				r.Output[step] = text(out)
+			}
+		}
+		results[i] = r
+	}
+	return results
+}
+
+// Summary returns the summary of the batch so far.
+func (b *Batch) Summary() Summary {
+	s := Summary{
+		Recipe:      b.recipe.Name,
+		Parallelism: max(b.opts.Parallelism, 1),
+		Results:     b.Results(),
+	}
+	for _, r := range s.Results {
+		switch r.Status {
+		case history.StatusSucceeded:
+			s.Succeeded++
+		case history.StatusFailed, history.StatusAborted:
+			s.Failed++
+		}
+	}
+	return s
+}
+
+func (b *Batch) update(i int, f func(r *Result)) {
+	b.mu.Lock()
+	f(&b.results[i])
+	name := b.results[i].Environment
+	b.mu.Unlock()
+	select {
+	case b.updates <- name:
+	default:
+	}
+}
+
+// run runs the recipe in the environment of the i-th result.
+func (b *Batch) run(ctx context.Context, i int) {
+	recipe := b.recipe
+	if b.opts.Parallelism > 1 && len(b.results) > 1 {
+		recipe.Workspace = isolated(recipe.Workspace)
+	}
+	b.mu.Lock()
+	recipe.Environment = b.results[i].Environment
+	b.mu.Unlock()
+	b.update(i, func(r *Result) {
+		r.Status = history.StatusRunning
+		r.StartedAt = time.Now()
+	})
+	record, err := runner.Run(ctx, recipe, runner.Options{
+		Config:         b.opts.Config,
+		History:        b.history,
+		ApplyEnvWrites: b.opts.ApplyEnvWrites,
+		Output: func(step int) io.Writer {
+			return &outputWriter{b: b, i: i, step: step}
+		},
+		OnChange: func(record *history.Run) {
+			b.update(i, func(r *Result) {
+				r.RunID = record.ID
+				r.Steps = append(r.Steps[:0], record.Steps...)
+				if len(b.outputs[i]) != len(record.Steps) {
+					b.outputs[i] = make([]*scrollback.Buffer, len(record.Steps))
+					for step := range b.outputs[i] {
+						b.outputs[i][step] = scrollback.New(scrollback.WithMaxLines(OutputLines))
+					}
+				}
+			})
+		},
+	})
+	b.update(i, func(r *Result) {
+		r.FinishedAt = time.Now()
+		switch {
+		case err != nil:
+			r.Status = history.StatusFailed
+			r.Error = err.Error()
+		case record.Status == history.StatusAborted:
+			r.Status = history.StatusAborted
+			r.Error = ctx.Err().Error()
+		default:
+			r.Status = history.StatusSucceeded
+		}
+	})
+}
+
+// text returns the lines of the buffer, each one terminated by a newline.
+func text(b *scrollback.Buffer) string {
+	lines, err := b.Lines(b.Dropped(), b.Len())
+	if err != nil || len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// isolated returns the workspace of the recipe, isolated. Its retention is kept.
+func isolated(w *recipes.Workspace) *recipes.Workspace {
+	var iso recipes.Workspace
+	if w != nil {
+		iso = *w
+	}
+	iso.Isolated = true
+	return &iso
+}
+
+// outputWriter appends the output of a step to its result.
+type outputWriter struct {
+	b    *Batch
+	i    int
+	step int
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.b.mu.Lock()
+	var out *scrollback.Buffer
+	if outputs := w.b.outputs[w.i]; w.step < len(outputs) {
+		out = outputs[w.step]
+	}
+	w.b.mu.Unlock()
+	if out == nil {
+		return len(p), nil
+	}
+	return out.Write(p)
+}
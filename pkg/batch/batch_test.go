@@ -0,0 +1,123 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package batch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/history"
+	"github.com/hypershift-community/hyper-console/pkg/recipes"
+)
+
+func TestBatch_Run(t *testing.T) {
+	recipeDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(recipeDir, "Taskfile.yml"), []byte(`version: '3'
+env:
+  CLUSTER_NAME: ""
+tasks:
+  default:
+    cmds:
+      - defer: echo cleanup
+      - echo "cluster $CLUSTER_NAME"
+      - test "$CLUSTER_NAME" != prod
+      - echo done
+`), 0o644))
+	envsDir := t.TempDir()
+	for _, name := range []string{"dev", "stage", "prod"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(envsDir, name), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(envsDir, name, "env.hcl"), []byte(`CLUSTER_NAME = "`+name+`"`+"\n"), 0o644))
+	}
+	cfg := &config.Config{EnvironmentsDir: envsDir, HistoryDir: t.TempDir()}
+	recipe := recipes.Recipe{RecipeInfo: recipes.RecipeInfo{Name: "check"}, Dir: recipeDir}
+
+	b := New(recipe, []string{"dev", "prod", "stage"}, Options{Config: cfg, Parallelism: 2})
+	summary := b.Run(context.Background())
+
+	require.Equal(t, "check", summary.Recipe)
+	require.Equal(t, 2, summary.Parallelism)
+	require.Equal(t, 2, summary.Succeeded)
+	require.Equal(t, 1, summary.Failed)
+	require.Len(t, summary.Results, 3)
+
+	dev, prod := summary.Results[0], summary.Results[1]
+	require.Equal(t, "dev", dev.Environment)
+	require.Equal(t, history.StatusSucceeded, dev.Status)
+	require.Contains(t, dev.Output[0], "cluster dev\n")
+	require.Contains(t, dev.Output[3], "cleanup\n")
+	require.Equal(t, "prod", prod.Environment)
+	require.Equal(t, history.StatusFailed, prod.Status)
+	statuses := make([]history.StepStatus, len(prod.Steps))
+	for i, s := range prod.Steps {
+		statuses[i] = s.Status
+	}
+	require.Equal(t, []history.StepStatus{history.StepSucceeded, history.StepFailed, history.StepPending, history.StepSucceeded}, statuses)
+
+	// Every run is recorded in the history
+	runs, err := history.NewStore(cfg.HistoryDir).List()
+	require.NoError(t, err)
+	require.Len(t, runs, 3)
+}
+
+func TestBatch_RunCancelled(t *testing.T) {
+	cfg := &config.Config{EnvironmentsDir: t.TempDir()}
+	b := New(recipes.Recipe{}, []string{"dev", "stage"}, Options{Config: cfg})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	summary := b.Run(ctx)
+	require.Equal(t, 2, summary.Failed)
+	for _, r := range summary.Results {
+		require.Equal(t, history.StatusAborted, r.Status)
+	}
+}
+
+func TestBatch_RunParallelIsolated(t *testing.T) {
+	recipeDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(recipeDir, "Taskfile.yml"), []byte(`version: '3'
+env:
+  CLUSTER_NAME: ""
+tasks:
+  default:
+    cmds:
+      - echo "$CLUSTER_NAME" > name && sleep 0.3 && cat name
+      - seq 1 5000
+`), 0o644))
+	envsDir := t.TempDir()
+	for _, name := range []string{"dev", "stage"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(envsDir, name), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(envsDir, name, "env.hcl"), []byte(`CLUSTER_NAME = "`+name+`"`+"\n"), 0o644))
+	}
+	cfg := &config.Config{EnvironmentsDir: envsDir, WorkspacesDir: t.TempDir()}
+	recipe := recipes.Recipe{RecipeInfo: recipes.RecipeInfo{Name: "check"}, Dir: recipeDir}
+
+	summary := New(recipe, []string{"dev", "stage"}, Options{Config: cfg, Parallelism: 2}).Run(context.Background())
+	require.Equal(t, 2, summary.Succeeded)
+	for _, r := range summary.Results {
+		// Each run wrote its own file, in its own workspace
+		require.True(t, strings.HasSuffix(r.Output[0], "\n"+r.Environment+"\n"), r.Output[0])
+		// Only the last lines of the output are kept
+		lines := strings.Split(strings.TrimSuffix(r.Output[1], "\n"), "\n")
+		require.LessOrEqual(t, len(lines), OutputLines)
+		require.Equal(t, "5000", lines[len(lines)-1])
+	}
+	require.NoFileExists(t, filepath.Join(recipeDir, "name"))
+}
@@ -14,7 +14,63 @@
 
 package config
 
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
 type Config struct {
 	RecipesDir      string
 	EnvironmentsDir string
+	// WorkflowsDir is where the workflows chaining recipes are defined.
+	WorkflowsDir string
+	// HistoryDir is where the records of past recipe runs are kept.
+	HistoryDir string
+	// ScrollbackLines is the number of lines of output of a run kept in memory. The
+	// older lines are spilled to ScrollbackDir, or dropped if it isn't set.
+	ScrollbackLines int
+	ScrollbackDir   string
+	// WorkspacesDir is where the isolated recipes are copied to run.
+	WorkspacesDir string
+	// SupervisorSocket is the unix socket of the supervisor running recipes in the
+	// background.
+	SupervisorSocket string
+}
+
+// DefaultHistoryDir returns the directory used to store the run history when none
+// is configured. It lives under the user's config directory so it survives reboots,
+// falling back to the temp directory when the config directory can't be determined.
+func DefaultHistoryDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "hyperdev", "history")
+}
+
+// DefaultAPITokenFile returns the file the token of the HTTP API is written to
+// when none is given, for the integrations to read it.
+func DefaultAPITokenFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "hyperdev", "api-token")
+}
+
+// DefaultWorkspacesDir returns the directory where the isolated recipes are copied
+// to run when none is configured.
+func DefaultWorkspacesDir() string {
+	return filepath.Join(os.TempDir(), "hyperdev", "workspaces")
+}
+
+// DefaultSupervisorSocket returns the unix socket of the supervisor when none is
+// configured, in the user's runtime directory if there is one or in a directory of
+// the user under the temp directory.
+func DefaultSupervisorSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "hyperdev", "supervisor.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("hyperdev-%d", os.Getuid()), "supervisor.sock")
 }
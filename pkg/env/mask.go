@@ -0,0 +1,64 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package env
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Masked is shown in place of the values of the secrets.
+const Masked = "********"
+
+// secretWords are the words of the names of the variables holding secrets.
+var secretWords = map[string]bool{
+	"TOKEN":       true,
+	"SECRET":      true,
+	"PASSWORD":    true,
+	"PASSWD":      true,
+	"PASS":        true,
+	"CREDENTIAL":  true,
+	"CREDENTIALS": true,
+	"KEY":         true,
+	"APIKEY":      true,
+	"AUTH":        true,
+}
+
+// IsSecret returns whether the variable holds a secret, going by the words of
+// its name: PULL_SECRET or GITHUB_TOKEN do, KUBECONFIG doesn't.
+func IsSecret(name string) bool {
+	words := strings.FieldsFunc(strings.ToUpper(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		if secretWords[w] {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskedVars returns the variables of the environment, with the values of the
+// secrets masked.
+func (e *Env) MaskedVars() map[string]string {
+	vars := make(map[string]string, len(e.Vars))
+	for k, v := range e.Vars {
+		if IsSecret(k) {
+			v = Masked
+		}
+		vars[k] = v
+	}
+	return vars
+}
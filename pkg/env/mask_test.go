@@ -0,0 +1,50 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package env
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaskedVars(t *testing.T) {
+	e := &Env{Vars: map[string]string{
+		"CLUSTER_NAME":     "cluster1",
+		"KUBECONFIG":       "/envs/dev/env.d/KUBECONFIG",
+		"PULL_SECRET":      "{}",
+		"github_token":     "ghp_x",
+		"AWS_ACCESS_KEY":   "AKIA",
+		"DB_PASSWORD":      "hunter2",
+		"AUTHOR":           "someone",
+		"SSO.Credentials":  "x",
+		"API-KEY":          "k",
+		"PASSTHROUGH_MODE": "on",
+	}}
+	require.Equal(t, map[string]string{
+		"CLUSTER_NAME":     "cluster1",
+		"KUBECONFIG":       "/envs/dev/env.d/KUBECONFIG",
+		"PULL_SECRET":      Masked,
+		"github_token":     Masked,
+		"AWS_ACCESS_KEY":   Masked,
+		"DB_PASSWORD":      Masked,
+		"AUTHOR":           "someone",
+		"SSO.Credentials":  Masked,
+		"API-KEY":          Masked,
+		"PASSTHROUGH_MODE": "on",
+	}, e.MaskedVars())
+	require.Equal(t, "cluster1", e.Vars["CLUSTER_NAME"])
+	require.Equal(t, "hunter2", e.Vars["DB_PASSWORD"])
+}
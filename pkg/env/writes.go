@@ -0,0 +1,333 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package env
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsimple"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Recipes can write back into their environment once they succeed, e.g. to set the
+// name of the cluster they created in env.hcl or place its kubeconfig in env.d. The
+// writes are planned as a list of changes, which can be shown as a diff before they
+// are applied, and recorded so that they can be reverted later on.
+
+// ChangeKind is the kind of a change to an environment.
+type ChangeKind string
+
+const (
+	// ChangeVar sets a key of the env.hcl file.
+	ChangeVar ChangeKind = "var"
+	// ChangeFile places a file in the env.d directory.
+	ChangeFile ChangeKind = "file"
+)
+
+// Change is a change to a key of the env.hcl file or to a file of the env.d directory.
+type Change struct {
+	Kind ChangeKind `json:"kind"`
+	Name string     `json:"name"`
+	// Existed is false if the key or the file didn't exist before the change, in
+	// which case reverting the change removes it.
+	Existed bool `json:"existed,omitempty"`
+	// Before and After are the values of the key, or the contents of the file, before
+	// and after the change.
+	Before string `json:"before,omitempty"`
+	After  string `json:"after"`
+}
+
+// Diff describes the change as the lines of a diff. The contents of the files are
+// not shown as they usually hold credentials.
+func (c Change) Diff() []string {
+	if c.Kind == ChangeFile {
+		path := filepath.Join(DefaultEnvDir, c.Name)
+		if !c.Existed {
+			return []string{fmt.Sprintf("+ %s (%d lines)", path, lineCount(c.After))}
+		}
+		return []string{fmt.Sprintf("~ %s (%d → %d lines)", path, lineCount(c.Before), lineCount(c.After))}
+	}
+	var lines []string
+	if c.Existed {
+		lines = append(lines, fmt.Sprintf("- %s = %q", c.Name, c.Before))
+	}
+	return append(lines, fmt.Sprintf("+ %s = %q", c.Name, c.After))
+}
+
+func lineCount(s string) int {
+	if s == "" {
+		return 0
+	}
+	return strings.Count(strings.TrimSuffix(s, "\n"), "\n") + 1
+}
+
+// PlanChanges returns the changes setting the given keys of the env.hcl file and
+// placing files with the given contents, by name, in the env.d directory of the
+// environment at path. The keys and files which already have these values are left
+// out.
+func PlanChanges(path string, vars map[string]string, files map[string]string) ([]Change, error) {
+	current, err := decodeVars(filepath.Join(path, DefaultEnvFile))
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for _, k := range sortedKeys(vars) {
+		if !hclsyntax.ValidIdentifier(k) {
+			return nil, fmt.Errorf("invalid environment key %q", k)
+		}
+		before, existed := current[k]
+		if existed && before == vars[k] {
+			continue
+		}
+		changes = append(changes, Change{Kind: ChangeVar, Name: k, Existed: existed, Before: before, After: vars[k]})
+	}
+	for _, name := range sortedKeys(files) {
+		if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+			return nil, fmt.Errorf("invalid environment file name %q", name)
+		}
+		before, err := os.ReadFile(filepath.Join(path, DefaultEnvDir, name))
+		existed := err == nil
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("error reading environment file %s: %w", name, err)
+		}
+		if existed && string(before) == files[name] {
+			continue
+		}
+		changes = append(changes, Change{Kind: ChangeFile, Name: name, Existed: existed, Before: string(before), After: files[name]})
+	}
+	return changes, nil
+}
+
+// Apply applies the changes to the environment at path. The environment must still
+// be in the state the changes were planned against. All the new contents are written
+// to temporary files first, which then replace the original ones in the order of the
+// changes, the env.hcl file last. If any can't be replaced, the files replaced
+// already are restored, so the changes are applied as a whole or not at all.
+func Apply(path string, changes []Change) error {
+	return apply(path, changes, false)
+}
+
+// Revert reverts changes previously applied to the environment at path, which must
+// not have been modified since. It is as atomic as Apply.
+func Revert(path string, changes []Change) error {
+	return apply(path, changes, true)
+}
+
+// rename is os.Rename, replaced by the tests to make it fail.
+var rename = os.Rename
+
+// replacement replaces a file of the environment with the temporary file holding
+// its new content, or removes it when there is none.
+type replacement struct {
+	file string
+	tmp  string
+	// original is the content of the file before it is replaced, existed is false
+	// if the file didn't exist.
+	original []byte
+	existed  bool
+	mode     os.FileMode
+}
+
+func (r replacement) do() error {
+	if r.tmp == "" {
+		if err := os.Remove(r.file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing %s: %w", r.file, err)
+		}
+		return nil
+	}
+	if err := rename(r.tmp, r.file); err != nil {
+		return fmt.Errorf("error writing %s: %w", r.file, err)
+	}
+	return nil
+}
+
+// undo restores the file as it was before the replacement.
+func (r replacement) undo() error {
+	if !r.existed {
+		if err := os.Remove(r.file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing %s: %w", r.file, err)
+		}
+		return nil
+	}
+	tmp, err := writeTemp(r.file, r.original, r.mode)
+	if err != nil {
+		return err
+	}
+	if err := rename(tmp, r.file); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("error restoring %s: %w", r.file, err)
+	}
+	return nil
+}
+
+func apply(path string, changes []Change, revert bool) error {
+	envFile := filepath.Join(path, DefaultEnvFile)
+	current, err := decodeVars(envFile)
+	if err != nil {
+		return err
+	}
+	src, err := os.ReadFile(envFile)
+	if err != nil {
+		return fmt.Errorf("error reading environment configuration: %w", err)
+	}
+	f, diags := hclwrite.ParseConfig(src, envFile, hcl.InitialPos)
+	if diags.HasErrors() {
+		return fmt.Errorf("error parsing environment configuration: %w", diags)
+	}
+
+	var replacements []replacement
+	defer func() {
+		for _, r := range replacements {
+			if r.tmp != "" {
+				_ = os.Remove(r.tmp)
+			}
+		}
+	}()
+	varsChanged := false
+	for _, c := range changes {
+		expected, existed, target, keep := c.After, true, c.Before, c.Existed
+		if !revert {
+			expected, existed, target, keep = c.Before, c.Existed, c.After, true
+		}
+		switch c.Kind {
+		case ChangeVar:
+			value, ok := current[c.Name]
+			if ok != existed || value != expected {
+				return &ConflictError{Change: c}
+			}
+			if keep {
+				f.Body().SetAttributeValue(c.Name, cty.StringVal(target))
+			} else {
+				f.Body().RemoveAttribute(c.Name)
+			}
+			varsChanged = true
+		case ChangeFile:
+			file := filepath.Join(path, DefaultEnvDir, c.Name)
+			content, err := os.ReadFile(file)
+			if (err == nil) != existed || string(content) != expected {
+				return &ConflictError{Change: c}
+			}
+			r := replacement{file: file, original: content, existed: existed, mode: fileMode(file, 0o600)}
+			if keep {
+				if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+					return fmt.Errorf("error creating %s directory: %w", DefaultEnvDir, err)
+				}
+				if r.tmp, err = writeTemp(file, []byte(target), 0o600); err != nil {
+					return err
+				}
+			}
+			replacements = append(replacements, r)
+		default:
+			return fmt.Errorf("unknown environment change kind %q", c.Kind)
+		}
+	}
+	if varsChanged {
+		r := replacement{file: envFile, original: src, existed: true, mode: fileMode(envFile, 0o644)}
+		if r.tmp, err = writeTemp(envFile, f.Bytes(), r.mode); err != nil {
+			return err
+		}
+		replacements = append(replacements, r)
+	}
+	for i, r := range replacements {
+		if err := r.do(); err != nil {
+			return errors.Join(err, rollback(replacements[:i]))
+		}
+	}
+	return nil
+}
+
+// rollback undoes the replacements done, in reverse order.
+func rollback(done []replacement) error {
+	var errs []error
+	for i := len(done) - 1; i >= 0; i-- {
+		if err := done[i].undo(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fileMode returns the permissions of the file, or def if it doesn't exist.
+func fileMode(file string, def os.FileMode) os.FileMode {
+	if info, err := os.Stat(file); err == nil {
+		return info.Mode().Perm()
+	}
+	return def
+}
+
+// ConflictError is returned when a change can't be applied, or reverted, because
+// the environment was modified since it was planned, or applied.
+type ConflictError struct {
+	Change Change
+}
+
+func (e *ConflictError) Error() string {
+	if e.Change.Kind == ChangeFile {
+		return fmt.Sprintf("environment file %s was modified", filepath.Join(DefaultEnvDir, e.Change.Name))
+	}
+	return fmt.Sprintf("environment key %s was modified", e.Change.Name)
+}
+
+// IsConflict returns true if the error is a ConflictError.
+func IsConflict(err error) bool {
+	var conflict *ConflictError
+	return errors.As(err, &conflict)
+}
+
+func decodeVars(envFile string) (map[string]string, error) {
+	vars := make(map[string]string)
+	if err := hclsimple.DecodeFile(envFile, nil, &vars); err != nil {
+		return nil, fmt.Errorf("error loading environment configuration: %w", err)
+	}
+	return vars, nil
+}
+
+// writeTemp writes data to a temporary file next to the given file and returns its
+// path.
+func writeTemp(file string, data []byte, mode os.FileMode) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("error writing %s: %w", file, err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), mode)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("error writing %s: %w", file, err)
+	}
+	return tmp.Name(), nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
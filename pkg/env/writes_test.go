@@ -0,0 +1,125 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package env
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const envFile = `# The dev environment
+_INFO_DESCRIPTION = "Dev"
+CLUSTER_NAME = "dev"
+REPLICAS = 3
+`
+
+func TestApplyRevert(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, createFile(filepath.Join(dir, DefaultEnvFile), envFile))
+
+	changes, err := PlanChanges(dir, map[string]string{
+		"CLUSTER_NAME": "hc-1",
+		"INFRA_ID":     "infra-1",
+		"REPLICAS":     "3",
+	}, map[string]string{
+		"KUBECONFIG": "apiVersion: v1\nkind: Config\n",
+	})
+	require.NoError(t, err)
+	require.Equal(t, []Change{
+		{Kind: ChangeVar, Name: "CLUSTER_NAME", Existed: true, Before: "dev", After: "hc-1"},
+		{Kind: ChangeVar, Name: "INFRA_ID", After: "infra-1"},
+		{Kind: ChangeFile, Name: "KUBECONFIG", After: "apiVersion: v1\nkind: Config\n"},
+	}, changes)
+	require.Equal(t, []string{`- CLUSTER_NAME = "dev"`, `+ CLUSTER_NAME = "hc-1"`}, changes[0].Diff())
+	require.Equal(t, []string{"+ env.d/KUBECONFIG (2 lines)"}, changes[2].Diff())
+
+	require.NoError(t, Apply(dir, changes))
+	env, err := Load(dir)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"CLUSTER_NAME": "hc-1",
+		"INFRA_ID":     "infra-1",
+		"REPLICAS":     "3",
+		"KUBECONFIG":   filepath.Join(dir, DefaultEnvDir, "KUBECONFIG"),
+	}, env.Vars)
+	src, err := os.ReadFile(filepath.Join(dir, DefaultEnvFile))
+	require.NoError(t, err)
+	require.Contains(t, string(src), "# The dev environment")
+
+	// Applying them again conflicts, the environment isn't in the planned state anymore
+	require.True(t, IsConflict(Apply(dir, changes)))
+
+	require.NoError(t, Revert(dir, changes))
+	vars, err := decodeVars(filepath.Join(dir, DefaultEnvFile))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"_INFO_DESCRIPTION": "Dev", "CLUSTER_NAME": "dev", "REPLICAS": "3"}, vars)
+	require.NoFileExists(t, filepath.Join(dir, DefaultEnvDir, "KUBECONFIG"))
+}
+
+func TestRevert_Conflict(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, createFile(filepath.Join(dir, DefaultEnvFile), envFile))
+	changes, err := PlanChanges(dir, map[string]string{"CLUSTER_NAME": "hc-1"}, nil)
+	require.NoError(t, err)
+	require.NoError(t, Apply(dir, changes))
+
+	require.NoError(t, createFile(filepath.Join(dir, DefaultEnvFile), `CLUSTER_NAME = "edited"`))
+	err = Revert(dir, changes)
+	require.True(t, IsConflict(err))
+	require.EqualError(t, err, "environment key CLUSTER_NAME was modified")
+}
+
+func TestApply_RollsBack(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, createFile(filepath.Join(dir, DefaultEnvFile), envFile))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, DefaultEnvDir), 0o755))
+	require.NoError(t, createFile(filepath.Join(dir, DefaultEnvDir, "CA"), "old ca\n"))
+	changes, err := PlanChanges(dir, map[string]string{"CLUSTER_NAME": "hc-1"}, map[string]string{
+		"CA":         "new ca\n",
+		"KUBECONFIG": "apiVersion: v1\n",
+	})
+	require.NoError(t, err)
+
+	// The second file can't be replaced
+	renames := 0
+	rename = func(oldpath, newpath string) error {
+		if renames++; renames == 2 {
+			return errors.New("disk full")
+		}
+		return os.Rename(oldpath, newpath)
+	}
+	defer func() { rename = os.Rename }()
+	require.ErrorContains(t, Apply(dir, changes), "disk full")
+
+	// The first file is restored and nothing else changed
+	content, err := os.ReadFile(filepath.Join(dir, DefaultEnvDir, "CA"))
+	require.NoError(t, err)
+	require.Equal(t, "old ca\n", string(content))
+	require.NoFileExists(t, filepath.Join(dir, DefaultEnvDir, "KUBECONFIG"))
+	src, err := os.ReadFile(filepath.Join(dir, DefaultEnvFile))
+	require.NoError(t, err)
+	require.Equal(t, envFile, string(src))
+	entries, err := os.ReadDir(filepath.Join(dir, DefaultEnvDir))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	// The changes apply once the failure is gone
+	rename = os.Rename
+	require.NoError(t, Apply(dir, changes))
+}
@@ -0,0 +1,392 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package history
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hypershift-community/hyper-console/pkg/env"
+	"github.com/hypershift-community/hyper-console/pkg/logging"
+	"github.com/hypershift-community/hyper-console/pkg/recipes"
+)
+
+// Run history is stored as one JSON file per run in the history directory. The file
+// name is the run ID which starts with the run start time, so listing the directory
+// gives us the runs in chronological order.
+//
+// Example:
+// ─── history
+//     ├── 20250301-101500-3fa2.json
+//     └── 20250301-114210-9c01.json
+
+var Logger = logging.Logger
+
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusAborted   Status = "aborted"
+)
+
+type StepStatus string
+
+const (
+	StepPending   StepStatus = "pending"
+	StepRunning   StepStatus = "running"
+	StepSucceeded StepStatus = "succeeded"
+	StepFailed    StepStatus = "failed"
+	StepTimedOut  StepStatus = "timed-out"
+	StepSkipped   StepStatus = "skipped"
+)
+
+type Step struct {
+	Index      int        `json:"index"`
+	Cmd        string     `json:"cmd"`
+	Status     StepStatus `json:"status"`
+	StartedAt  time.Time  `json:"startedAt,omitempty"`
+	FinishedAt time.Time  `json:"finishedAt,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	// Attempts are the attempts of the steps which were retried, the last one included.
+	Attempts []Attempt `json:"attempts,omitempty"`
+}
+
+// Attempt is an attempt of a step which was retried.
+type Attempt struct {
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	ExitCode   int       `json:"exitCode"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type Run struct {
+	ID          string         `json:"id"`
+	Recipe      recipes.Recipe `json:"recipe"`
+	Environment string         `json:"environment,omitempty"`
+	Status      Status         `json:"status"`
+	StartedAt   time.Time      `json:"startedAt"`
+	FinishedAt  time.Time      `json:"finishedAt,omitempty"`
+	ResumedFrom string         `json:"resumedFrom,omitempty"`
+	Steps       []Step         `json:"steps"`
+	Error       string         `json:"error,omitempty"`
+	// Outputs are the KEY=value outputs the commands of the run wrote.
+	Outputs map[string]string `json:"outputs,omitempty"`
+	// EnvChanges are the changes applied to the environment once the run succeeded.
+	// EnvReverted is set once they have been reverted.
+	EnvChanges  []env.Change `json:"envChanges,omitempty"`
+	EnvReverted bool         `json:"envReverted,omitempty"`
+	// EnvPending are the changes planned once the run succeeded which are still to
+	// be confirmed and applied to the environment.
+	EnvPending []env.Change `json:"envPending,omitempty"`
+	// ArtifactsDir is the directory of the files the commands of the run generated.
+	// Resumed runs share the directory of the run they resume.
+	ArtifactsDir string `json:"artifactsDir,omitempty"`
+	// Workspace is the copy of the recipe the run ran in when the recipe is isolated.
+	// It may have been removed since, depending on the retention of the recipe.
+	Workspace string `json:"workspace,omitempty"`
+}
+
+// Artifact is a file generated by the commands of a run.
+type Artifact struct {
+	// Path is the path of the file relative to the artifacts directory.
+	Path string
+	Size int64
+}
+
+// NewRun creates a new run record for the given recipe with every step pending.
+func NewRun(recipe recipes.Recipe, cmds []string) *Run {
+	now := time.Now()
+	r := &Run{
+		ID:          newID(now),
+		Recipe:      recipe,
+		Environment: recipe.Environment,
+		Status:      StatusRunning,
+		StartedAt:   now,
+		Steps:       make([]Step, len(cmds)),
+	}
+	for i, c := range cmds {
+		r.Steps[i] = Step{Index: i, Cmd: c, Status: StepPending}
+	}
+	return r
+}
+
+// NewRunID returns a new run ID. IDs can be allocated before the record of the run
+// is created, e.g. to name its artifacts directory.
+func NewRunID() string {
+	return newID(time.Now())
+}
+
+func newID(t time.Time) string {
+	b := make([]byte, 2)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s-%s", t.Format("20060102-150405"), hex.EncodeToString(b))
+}
+
+// FailedStep returns the index of the step that failed the run or -1 if no step failed.
+func (r *Run) FailedStep() int {
+	for _, s := range r.Steps {
+		if s.Status == StepFailed || s.Status == StepTimedOut {
+			return s.Index
+		}
+	}
+	return -1
+}
+
+// ResumableStep returns the index of the first step that neither succeeded nor was
+// skipped. This is where a failed or aborted run should be resumed from.
+func (r *Run) ResumableStep() int {
+	for _, s := range r.Steps {
+		if s.Status != StepSucceeded && s.Status != StepSkipped {
+			return s.Index
+		}
+	}
+	return -1
+}
+
+// StartStep marks the step at the given index as running.
+func (r *Run) StartStep(index int) {
+	if index < 0 || index >= len(r.Steps) {
+		return
+	}
+	r.Steps[index].Status = StepRunning
+	r.Steps[index].StartedAt = time.Now()
+}
+
+// FinishStep records the outcome of the step at the given index.
+func (r *Run) FinishStep(index int, status StepStatus, err error) {
+	if index < 0 || index >= len(r.Steps) {
+		return
+	}
+	r.Steps[index].Status = status
+	r.Steps[index].FinishedAt = time.Now()
+	if err != nil {
+		r.Steps[index].Error = err.Error()
+	}
+}
+
+// AddAttempt records an attempt of the step at the given index.
+func (r *Run) AddAttempt(index int, a Attempt) {
+	if index < 0 || index >= len(r.Steps) {
+		return
+	}
+	r.Steps[index].Attempts = append(r.Steps[index].Attempts, a)
+}
+
+// Finish records the final status of the run.
+func (r *Run) Finish(status Status, err error) {
+	r.Status = status
+	r.FinishedAt = time.Now()
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// Duration returns how long the step ran, or 0 if it didn't run to completion.
+func (s Step) Duration() time.Duration {
+	if s.StartedAt.IsZero() || s.FinishedAt.Before(s.StartedAt) {
+		return 0
+	}
+	return s.FinishedAt.Sub(s.StartedAt)
+}
+
+// estimateSamples is the maximum number of past runs of a step used to estimate its
+// duration, so the estimate follows recent changes.
+const estimateSamples = 5
+
+// EstimateDurations estimates the duration of the steps of a recipe, by command, from
+// its past runs in the given environment. Runs are expected newest first, as returned
+// by [Store.List]. Only the steps that succeeded are taken into account.
+func EstimateDurations(runs []*Run, recipe, environment string) map[string]time.Duration {
+	totals := make(map[string]time.Duration)
+	samples := make(map[string]int)
+	for _, r := range runs {
+		if r.Recipe.Name != recipe || r.Environment != environment {
+			continue
+		}
+		for _, s := range r.Steps {
+			d := s.Duration()
+			if s.Status != StepSucceeded || d == 0 || samples[s.Cmd] >= estimateSamples {
+				continue
+			}
+			totals[s.Cmd] += d
+			samples[s.Cmd]++
+		}
+	}
+	estimates := make(map[string]time.Duration, len(totals))
+	for cmd, total := range totals {
+		estimates[cmd] = total / time.Duration(samples[cmd])
+	}
+	return estimates
+}
+
+// LatestOutputs returns the outputs of the last successful run of a recipe in the
+// given environment, or nil if there is none. Runs are expected newest first, as
+// returned by [Store.List].
+func LatestOutputs(runs []*Run, recipe, environment string) map[string]string {
+	for _, r := range runs {
+		if r.Recipe.Name == recipe && r.Environment == environment && r.Status == StatusSucceeded {
+			return r.Outputs
+		}
+	}
+	return nil
+}
+
+// StaleSteps returns the steps before the given index that ran successfully in this
+// run. When resuming from that index, whatever state these steps produced (buckets,
+// clusters, kubeconfigs, ...) is reused as-is and might not be valid anymore.
+func (r *Run) StaleSteps(index int) []Step {
+	var steps []Step
+	for _, s := range r.Steps {
+		if s.Index < index && s.Status == StepSucceeded {
+			steps = append(steps, s)
+		}
+	}
+	return steps
+}
+
+// ChangedSteps returns the indices of the steps before the given index whose command
+// differs from the given commands, i.e. the recipe was modified since this run.
+func (r *Run) ChangedSteps(index int, cmds []string) []int {
+	var changed []int
+	for _, s := range r.Steps {
+		if s.Index >= index {
+			break
+		}
+		if s.Index >= len(cmds) || cmds[s.Index] != s.Cmd {
+			changed = append(changed, s.Index)
+		}
+	}
+	return changed
+}
+
+type Store struct {
+	dir string
+}
+
+// NewStore creates a run history store that keeps its records in the given directory.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Save writes the run record to the store, replacing any previous version of it.
+func (s *Store) Save(r *Run) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("error creating history directory: %w", err)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling run %s: %w", r.ID, err)
+	}
+	// Write to a temporary file first so a crash never leaves a truncated record behind.
+	path := s.path(r.ID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("error writing run %s: %w", r.ID, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error writing run %s: %w", r.ID, err)
+	}
+	return nil
+}
+
+// Get loads the run with the given ID.
+func (s *Store) Get(id string) (*Run, error) {
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		return nil, fmt.Errorf("error reading run %s: %w", id, err)
+	}
+	var r Run
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("error unmarshalling run %s: %w", id, err)
+	}
+	return &r, nil
+}
+
+// List returns all the runs in the store, most recent first.
+func (s *Store) List() ([]*Run, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading history directory: %w", err)
+	}
+	var runs []*Run
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		r, err := s.Get(strings.TrimSuffix(file.Name(), ".json"))
+		if err != nil {
+			Logger.Warn("Skipping unreadable run record", "file", file.Name(), "error", err)
+			continue
+		}
+		runs = append(runs, r)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+	return runs, nil
+}
+
+// ArtifactsDir returns the directory where the artifacts of the run with the given
+// ID are kept along with its record.
+func (s *Store) ArtifactsDir(id string) string {
+	return filepath.Join(s.dir, "artifacts", id)
+}
+
+// ListArtifacts lists the files in the given artifacts directory and its
+// subdirectories, sorted by path.
+func ListArtifacts(dir string) ([]Artifact, error) {
+	var artifacts []Artifact
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		artifacts = append(artifacts, Artifact{Path: rel, Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing artifacts: %w", err)
+	}
+	return artifacts, nil
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
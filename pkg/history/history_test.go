@@ -0,0 +1,154 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package history
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/hypershift-community/hyper-console/pkg/recipes"
+)
+
+func TestStore(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	runs, err := store.List()
+	require.NoError(t, err)
+	require.Empty(t, runs)
+
+	first := NewRun(recipes.Recipe{RecipeInfo: recipes.RecipeInfo{Name: "first"}}, []string{"echo 1"})
+	first.StartedAt = time.Now().Add(-time.Hour)
+	require.NoError(t, store.Save(first))
+
+	second := NewRun(recipes.Recipe{RecipeInfo: recipes.RecipeInfo{Name: "second", Environment: "dev"}}, []string{"echo 1", "echo 2"})
+	require.NoError(t, store.Save(second))
+
+	runs, err = store.List()
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	require.Equal(t, "second", runs[0].Recipe.Name)
+	require.Equal(t, "dev", runs[0].Environment)
+	require.Equal(t, "first", runs[1].Recipe.Name)
+
+	got, err := store.Get(second.ID)
+	require.NoError(t, err)
+	require.Len(t, got.Steps, 2)
+	require.Equal(t, StepPending, got.Steps[1].Status)
+}
+
+func TestRun_Resume(t *testing.T) {
+	cmds := []string{"create bucket", "create cluster", "wait", "install"}
+	r := NewRun(recipes.Recipe{}, cmds)
+	require.Equal(t, 0, r.ResumableStep())
+	require.Equal(t, -1, r.FailedStep())
+
+	r.StartStep(0)
+	r.FinishStep(0, StepSucceeded, nil)
+	r.StartStep(1)
+	r.FinishStep(1, StepSucceeded, nil)
+	r.StartStep(2)
+	r.FinishStep(2, StepFailed, errors.New("timed out"))
+	r.Finish(StatusFailed, errors.New("timed out"))
+
+	require.Equal(t, 2, r.FailedStep())
+	require.Equal(t, 2, r.ResumableStep())
+
+	resumed := NewRun(recipes.Recipe{}, cmds)
+	resumed.FinishStep(0, StepSkipped, nil)
+	require.Equal(t, 1, resumed.ResumableStep())
+	require.Equal(t, "timed out", r.Steps[2].Error)
+	require.Len(t, r.StaleSteps(2), 2)
+	require.Len(t, r.StaleSteps(1), 1)
+	require.Empty(t, r.ChangedSteps(2, cmds))
+	require.Equal(t, []int{1}, r.ChangedSteps(2, []string{"create bucket", "create cluster --replicas 2", "wait", "install"}))
+	require.Equal(t, []int{1, 2}, r.ChangedSteps(3, []string{"create bucket"}))
+}
+
+func TestEstimateDurations(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	run := func(name, env string, status StepStatus, durations ...time.Duration) *Run {
+		r := &Run{Recipe: recipes.Recipe{RecipeInfo: recipes.RecipeInfo{Name: name}}, Environment: env}
+		for i, d := range durations {
+			r.Steps = append(r.Steps, Step{
+				Index:      i,
+				Cmd:        fmt.Sprintf("step %d", i),
+				Status:     status,
+				StartedAt:  start,
+				FinishedAt: start.Add(d),
+			})
+		}
+		return r
+	}
+	runs := []*Run{
+		run("create", "aws", StepSucceeded, time.Minute, 10*time.Second),
+		run("create", "aws", StepSucceeded, 3*time.Minute),
+		run("create", "aws", StepFailed, time.Hour),
+		run("create", "azure", StepSucceeded, time.Hour),
+		run("delete", "aws", StepSucceeded, time.Hour),
+	}
+
+	estimates := EstimateDurations(runs, "create", "aws")
+	require.Equal(t, map[string]time.Duration{
+		"step 0": 2 * time.Minute,
+		"step 1": 10 * time.Second,
+	}, estimates)
+	require.Empty(t, EstimateDurations(runs, "create", "gcp"))
+}
+
+func TestLatestOutputs(t *testing.T) {
+	run := func(name, env string, status Status, outputs map[string]string) *Run {
+		return &Run{Recipe: recipes.Recipe{RecipeInfo: recipes.RecipeInfo{Name: name}}, Environment: env, Status: status, Outputs: outputs}
+	}
+	runs := []*Run{
+		run("create", "aws", StatusFailed, map[string]string{"INFRA_ID": "failed"}),
+		run("create", "aws", StatusSucceeded, map[string]string{"INFRA_ID": "latest"}),
+		run("create", "aws", StatusSucceeded, map[string]string{"INFRA_ID": "older"}),
+		run("create", "azure", StatusSucceeded, map[string]string{"INFRA_ID": "azure"}),
+	}
+
+	require.Equal(t, map[string]string{"INFRA_ID": "latest"}, LatestOutputs(runs, "create", "aws"))
+	require.Equal(t, map[string]string{"INFRA_ID": "azure"}, LatestOutputs(runs, "create", "azure"))
+	require.Nil(t, LatestOutputs(runs, "delete", "aws"))
+}
+
+func TestListArtifacts(t *testing.T) {
+	store := NewStore(t.TempDir())
+	dir := store.ArtifactsDir(NewRunID())
+
+	artifacts, err := ListArtifacts(dir)
+	require.NoError(t, err)
+	require.Empty(t, artifacts)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "manifests"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "kubeconfig"), []byte("apiVersion: v1\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifests", "hc.yaml"), []byte("kind: HostedCluster\n"), 0o644))
+	artifacts, err = ListArtifacts(dir)
+	require.NoError(t, err)
+	require.Equal(t, []Artifact{
+		{Path: "kubeconfig", Size: 15},
+		{Path: filepath.Join("manifests", "hc.yaml"), Size: 20},
+	}, artifacts)
+
+	// The artifacts aren't mistaken for run records
+	runs, err := store.List()
+	require.NoError(t, err)
+	require.Empty(t, runs)
+}
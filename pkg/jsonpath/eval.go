@@ -0,0 +1,242 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// eval applies the selectors in order. Missing fields and out of range indexes
+// select nothing rather than failing, so a template can be evaluated against
+// documents which don't have the selected values yet.
+func eval(sels []selector, data any) []any {
+	values := []any{data}
+	for _, sel := range sels {
+		var next []any
+		for _, v := range values {
+			next = append(next, sel.selectFrom(v)...)
+		}
+		values = next
+	}
+	return values
+}
+
+func (f field) selectFrom(v any) []any {
+	if f.name == "*" {
+		return children(v)
+	}
+	if e, ok := lookup(v, f.name); ok {
+		return []any{e}
+	}
+	return nil
+}
+
+func (d descendant) selectFrom(v any) []any {
+	var values []any
+	var walk func(v any)
+	walk = func(v any) {
+		if d.name == "*" {
+			values = append(values, children(v)...)
+		} else if e, ok := lookup(v, d.name); ok {
+			values = append(values, e)
+		}
+		for _, c := range children(v) {
+			walk(c)
+		}
+	}
+	walk(v)
+	return values
+}
+
+func (i index) selectFrom(v any) []any {
+	a, ok := v.([]any)
+	if !ok {
+		return nil
+	}
+	n := i.i
+	if n < 0 {
+		n += len(a)
+	}
+	if n < 0 || n >= len(a) {
+		return nil
+	}
+	return []any{a[n]}
+}
+
+func (s slice) selectFrom(v any) []any {
+	a, ok := v.([]any)
+	if !ok {
+		return nil
+	}
+	bound := func(b *int, def int) int {
+		if b == nil {
+			return def
+		}
+		n := *b
+		if n < 0 {
+			n += len(a)
+		}
+		return min(max(n, 0), len(a))
+	}
+	start, end := bound(s.start, 0), bound(s.end, len(a))
+	if start >= end {
+		return nil
+	}
+	return a[start:end]
+}
+
+func (f filter) selectFrom(v any) []any {
+	a, ok := v.([]any)
+	if !ok {
+		return nil
+	}
+	var values []any
+	for _, e := range a {
+		if f.matches(e) {
+			values = append(values, e)
+		}
+	}
+	return values
+}
+
+func (f filter) matches(e any) bool {
+	lhs := eval(f.path, e)
+	if f.op == "" {
+		// Existence test
+		return len(lhs) > 0 && lhs[0] != nil
+	}
+	if len(lhs) == 0 {
+		return false
+	}
+	rhs := f.value
+	if path, ok := rhs.([]selector); ok {
+		values := eval(path, e)
+		if len(values) == 0 {
+			return false
+		}
+		rhs = values[0]
+	}
+	return compare(lhs[0], f.op, rhs)
+}
+
+// compare compares numbers as numbers and the other values by their rendering.
+func compare(a any, op string, b any) bool {
+	x, aNum := number(a)
+	y, bNum := number(b)
+	if aNum && bNum {
+		switch op {
+		case "==":
+			return x == y
+		case "!=":
+			return x != y
+		case "<":
+			return x < y
+		case "<=":
+			return x <= y
+		case ">":
+			return x > y
+		case ">=":
+			return x >= y
+		}
+		return false
+	}
+	s, t := Format(a), Format(b)
+	switch op {
+	case "==":
+		return s == t
+	case "!=":
+		return s != t
+	case "<":
+		return s < t
+	case "<=":
+		return s <= t
+	case ">":
+		return s > t
+	case ">=":
+		return s >= t
+	}
+	return false
+}
+
+func number(v any) (float64, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// lookup returns the value of a key of a map.
+func lookup(v any, name string) (any, bool) {
+	switch m := v.(type) {
+	case map[string]any:
+		e, ok := m[name]
+		return e, ok
+	case map[any]any:
+		for k, e := range m {
+			if fmt.Sprint(k) == name {
+				return e, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// children returns the elements of an array or the values of a map, sorted by key.
+func children(v any) []any {
+	switch v := v.(type) {
+	case []any:
+		return v
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		values := make([]any, len(keys))
+		for i, k := range keys {
+			values[i] = v[k]
+		}
+		return values
+	case map[any]any:
+		keys := make([]string, 0, len(v))
+		byKey := make(map[string]any, len(v))
+		for k, e := range v {
+			s := fmt.Sprint(k)
+			keys = append(keys, s)
+			byKey[s] = e
+		}
+		sort.Strings(keys)
+		values := make([]any, len(keys))
+		for i, k := range keys {
+			values[i] = byKey[k]
+		}
+		return values
+	}
+	return nil
+}
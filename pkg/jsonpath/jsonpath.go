@@ -0,0 +1,204 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package jsonpath evaluates the JSONPath templates understood by kubectl, such as
+// {.status.conditions[?(@.type=="Available")].status}, against decoded JSON or YAML
+// documents.
+//
+// The supported subset covers the field and recursive descent selectors, indexes,
+// slices, wildcards and filters with comparisons. Ranges and functions aren't.
+package jsonpath
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Template is a compiled JSONPath template: text mixed with expressions in braces.
+type Template struct {
+	text  string
+	parts []part
+}
+
+// part is either some literal text or an expression.
+type part struct {
+	text string
+	expr []selector
+}
+
+// Parse compiles a template. A template without braces is parsed as a single
+// expression, so ".status.phase" and "{.status.phase}" are equivalent.
+func Parse(text string) (*Template, error) {
+	t := &Template{text: text}
+	if !strings.Contains(text, "{") {
+		text = "{" + text + "}"
+	}
+	for len(text) > 0 {
+		start := strings.IndexByte(text, '{')
+		if start < 0 {
+			t.parts = append(t.parts, part{text: text})
+			break
+		}
+		if start > 0 {
+			t.parts = append(t.parts, part{text: text[:start]})
+		}
+		end := closingBrace(text, start)
+		if end < 0 {
+			return nil, fmt.Errorf("jsonpath: unclosed expression in %q", t.text)
+		}
+		expr, err := parseExpr(text[start+1 : end])
+		if err != nil {
+			return nil, fmt.Errorf("jsonpath: invalid expression %q: %w", text[start+1:end], err)
+		}
+		t.parts = append(t.parts, part{expr: expr})
+		text = text[end+1:]
+	}
+	return t, nil
+}
+
+// MustParse is like Parse but panics if the template can't be parsed.
+func MustParse(text string) *Template {
+	t, err := Parse(text)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// closingBrace returns the index of the brace closing the one at start, skipping the
+// quoted strings, or -1 if there is none.
+func closingBrace(s string, start int) int {
+	var quote byte
+	for i := start + 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '}':
+			return i
+		}
+	}
+	return -1
+}
+
+// String returns the source of the template.
+func (t *Template) String() string {
+	return t.text
+}
+
+// Find returns the values selected by the expressions of the template, in order.
+func (t *Template) Find(data any) []any {
+	var values []any
+	for _, p := range t.parts {
+		if p.expr != nil {
+			values = append(values, eval(p.expr, data)...)
+		}
+	}
+	return values
+}
+
+// Execute renders the template against the given data. The values selected by an
+// expression are separated by spaces; strings are rendered as is and other values
+// as JSON, like kubectl does.
+func (t *Template) Execute(data any) string {
+	var sb strings.Builder
+	for _, p := range t.parts {
+		if p.expr == nil {
+			sb.WriteString(p.text)
+			continue
+		}
+		for i, v := range eval(p.expr, data) {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(Format(v))
+		}
+	}
+	return sb.String()
+}
+
+// ExecuteJSON decodes the given JSON document and renders the template against it.
+func (t *Template) ExecuteJSON(doc []byte) (string, error) {
+	data, err := DecodeJSON(doc)
+	if err != nil {
+		return "", err
+	}
+	return t.Execute(data), nil
+}
+
+// DecodeJSON decodes a JSON document, keeping the numbers as json.Number so that
+// integers are rendered as they were written.
+func DecodeJSON(doc []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var data any
+	if err := dec.Decode(&data); err != nil {
+		return nil, fmt.Errorf("jsonpath: invalid JSON: %w", err)
+	}
+	return data, nil
+}
+
+// Format renders a value selected by a template: strings as is, nil as an empty
+// string and other values as JSON.
+func Format(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	b, err := json.Marshal(normalize(v))
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// normalize converts the maps decoded from YAML, which may have keys of any type, so
+// that they can be encoded to JSON.
+func normalize(v any) any {
+	switch v := v.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = normalize(e)
+		}
+		return m
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[k] = normalize(e)
+		}
+		return m
+	case []any:
+		s := make([]any, len(v))
+		for i, e := range v {
+			s[i] = normalize(e)
+		}
+		return s
+	}
+	return v
+}
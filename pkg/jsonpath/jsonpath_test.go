@@ -0,0 +1,91 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jsonpath
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const hostedCluster = `{
+  "metadata": {"name": "demo", "labels": {"app.kubernetes.io/name": "hc"}},
+  "spec": {"replicas": 3, "platform": {"type": "AWS"}},
+  "status": {
+    "conditions": [
+      {"type": "Available", "status": "True", "observedGeneration": 2},
+      {"type": "Degraded", "status": "False", "observedGeneration": 1}
+    ],
+    "version": {"history": [{"version": "4.16.1"}, {"version": "4.15.3"}]}
+  }
+}`
+
+func TestTemplate_ExecuteJSON(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{`{.metadata.name}`, "demo"},
+		{`.metadata.name`, "demo"},
+		{`metadata.name`, "demo"},
+		{`{.spec.replicas}`, "3"},
+		{`{.spec.platform}`, `{"type":"AWS"}`},
+		{`{.metadata.labels.app\.kubernetes\.io/name}`, "hc"},
+		{`{.metadata.labels['app.kubernetes.io/name']}`, "hc"},
+		{`{.status.conditions[?(@.type=="Available")].status}`, "True"},
+		{`{.status.conditions[?(@.type!='Available')].type}`, "Degraded"},
+		{`{.status.conditions[?(@.observedGeneration > 1)].type}`, "Available"},
+		{`{.status.conditions[?(@.observedGeneration)].type}`, "Available Degraded"},
+		{`{.status.conditions[*].type}`, "Available Degraded"},
+		{`{.status.conditions[-1].type}`, "Degraded"},
+		{`{.status.conditions[0:1].type}`, "Available"},
+		{`{..version}`, `{"history":[{"version":"4.16.1"},{"version":"4.15.3"}]} 4.16.1 4.15.3`},
+		{`{.status.version.history[0].version}`, "4.16.1"},
+		{`{.metadata.name} is {.status.conditions[0].type}`, "demo is Available"},
+		{`{.status.missing}`, ""},
+		{`{.status.conditions[5].type}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			tmpl, err := Parse(tt.template)
+			require.NoError(t, err)
+			got, err := tmpl.ExecuteJSON([]byte(hostedCluster))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTemplate_YAML(t *testing.T) {
+	var data any
+	require.NoError(t, yaml.Unmarshal([]byte("items:\n- name: a\n  ready: true\n- name: b\n  ready: false\n"), &data))
+	require.Equal(t, "a", MustParse(`{.items[?(@.ready==true)].name}`).Execute(data))
+	require.Equal(t, `[{"name":"a","ready":true},{"name":"b","ready":false}]`, MustParse(`{.items}`).Execute(data))
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, template := range []string{
+		`{.status`,
+		`{.status[0}`,
+		`{.status[x]}`,
+		`{.items[?(.name=="a")]}`,
+		`{.items[?(@.name==a)]}`,
+		`{.a..}`,
+	} {
+		_, err := Parse(template)
+		require.Error(t, err, template)
+	}
+}
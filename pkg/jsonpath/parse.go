@@ -0,0 +1,247 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// selector selects values out of the values selected by the previous selector.
+type selector interface {
+	selectFrom(v any) []any
+}
+
+type (
+	// field selects the value of a key of a map, or all of them for "*".
+	field struct{ name string }
+	// descendant selects the values of a key in a value and all its descendants, or
+	// all the descendants for "*".
+	descendant struct{ name string }
+	// index selects an element of an array, counting from the end when negative.
+	index struct{ i int }
+	// slice selects the elements of an array in [start, end).
+	slice struct{ start, end *int }
+	// filter selects the elements of an array matching a condition.
+	filter struct {
+		path []selector
+		op   string
+		// value is either a literal or a path relative to the element.
+		value any
+	}
+)
+
+// operators are the comparison operators of the filters, the longest first.
+var operators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func parseExpr(s string) ([]selector, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "$")
+	if s == "." {
+		// The root itself
+		return []selector{}, nil
+	}
+	sels := []selector{}
+	if s != "" && s[0] != '.' && s[0] != '[' {
+		// "status.phase" is the same as ".status.phase"
+		s = "." + s
+	}
+	for len(s) > 0 {
+		switch {
+		case strings.HasPrefix(s, ".."):
+			name, rest := readName(s[2:])
+			if name == "" {
+				return nil, fmt.Errorf("missing name after ..")
+			}
+			sels = append(sels, descendant{name: name})
+			s = rest
+		case s[0] == '.':
+			name, rest := readName(s[1:])
+			if name == "" {
+				return nil, fmt.Errorf("missing name after .")
+			}
+			sels = append(sels, field{name: name})
+			s = rest
+		case s[0] == '[':
+			end := closingBracket(s)
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed bracket")
+			}
+			sel, err := parseBracket(strings.TrimSpace(s[1:end]))
+			if err != nil {
+				return nil, err
+			}
+			sels = append(sels, sel)
+			s = s[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q", s)
+		}
+	}
+	return sels, nil
+}
+
+// readName reads a field name, where dots can be escaped with a backslash, up to the
+// next selector.
+func readName(s string) (string, string) {
+	var sb strings.Builder
+	i := 0
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) {
+			i++
+			sb.WriteByte(s[i])
+			continue
+		}
+		if c == '.' || c == '[' {
+			break
+		}
+		sb.WriteByte(c)
+	}
+	return strings.TrimSpace(sb.String()), s[i:]
+}
+
+// closingBracket returns the index of the bracket closing the one s starts with,
+// skipping quoted strings and nested brackets, or -1 if there is none.
+func closingBracket(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func parseBracket(s string) (selector, error) {
+	switch {
+	case s == "*":
+		return field{name: "*"}, nil
+	case strings.HasPrefix(s, "?(") && strings.HasSuffix(s, ")"):
+		return parseFilter(strings.TrimSpace(s[2 : len(s)-1]))
+	case strings.HasPrefix(s, "'") || strings.HasPrefix(s, `"`):
+		name, err := unquote(s)
+		if err != nil {
+			return nil, err
+		}
+		return field{name: name}, nil
+	case strings.Contains(s, ":"):
+		bounds := strings.SplitN(s, ":", 3)
+		var sl slice
+		for i, b := range bounds[:2] {
+			if b = strings.TrimSpace(b); b == "" {
+				continue
+			}
+			n, err := strconv.Atoi(b)
+			if err != nil {
+				return nil, fmt.Errorf("invalid slice %q", s)
+			}
+			if i == 0 {
+				sl.start = &n
+			} else {
+				sl.end = &n
+			}
+		}
+		return sl, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid index %q", s)
+	}
+	return index{i: n}, nil
+}
+
+func parseFilter(s string) (selector, error) {
+	lhs, op, rhs := s, "", ""
+	for i := 0; i < len(s) && op == ""; i++ {
+		if s[i] == '"' || s[i] == '\'' {
+			// Operators can't be quoted
+			end := strings.IndexByte(s[i+1:], s[i])
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed string in filter %q", s)
+			}
+			i += end + 1
+			continue
+		}
+		for _, o := range operators {
+			if strings.HasPrefix(s[i:], o) {
+				lhs, op, rhs = strings.TrimSpace(s[:i]), o, strings.TrimSpace(s[i+len(o):])
+				break
+			}
+		}
+	}
+	path, err := parseRelative(lhs)
+	if err != nil {
+		return nil, err
+	}
+	f := filter{path: path, op: op}
+	if op == "" {
+		return f, nil
+	}
+	switch {
+	case strings.HasPrefix(rhs, "@"):
+		f.value, err = parseRelative(rhs)
+	case strings.HasPrefix(rhs, "'") || strings.HasPrefix(rhs, `"`):
+		f.value, err = unquote(rhs)
+	case rhs == "true" || rhs == "false":
+		f.value = rhs == "true"
+	case rhs == "null":
+		f.value = nil
+	default:
+		if _, err = strconv.ParseFloat(rhs, 64); err == nil {
+			f.value = json.Number(rhs)
+		} else {
+			err = fmt.Errorf("invalid value %q in filter", rhs)
+		}
+	}
+	return f, err
+}
+
+// parseRelative parses a path relative to the current element of a filter.
+func parseRelative(s string) ([]selector, error) {
+	if !strings.HasPrefix(s, "@") {
+		return nil, fmt.Errorf("filter paths must start with @, got %q", s)
+	}
+	if s == "@" {
+		return []selector{}, nil
+	}
+	return parseExpr(s[1:])
+}
+
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[len(s)-1] != s[0] {
+		return "", fmt.Errorf("invalid string %s", s)
+	}
+	if s[0] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], `\'`, `'`), nil
+	}
+	return strconv.Unquote(s)
+}
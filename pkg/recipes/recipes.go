@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -28,6 +29,78 @@ type RecipeInfo struct {
 	DisplayName string `yaml:"display-name"`
 	Description string `yaml:"description"`
 	Environment string `yaml:"environment,omitempty"`
+	// Timeout is the default timeout of the commands of the recipe.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Inputs are the recipes whose outputs, from their last successful run in the
+	// same environment, are passed to the commands of this recipe.
+	Inputs []RecipeInput `yaml:"inputs,omitempty"`
+	// EnvWrites are the changes the recipe makes to its environment once a run
+	// succeeds, applied once the user reviewed and confirmed them.
+	EnvWrites *EnvWrites `yaml:"env-writes,omitempty"`
+	// Workspace declares whether the recipe runs in a copy of its directory.
+	Workspace *Workspace `yaml:"workspace,omitempty"`
+}
+
+// RecipeInput takes the outputs of the last successful run of another recipe. The
+// environment variables set by the environment take precedence over them.
+type RecipeInput struct {
+	Recipe string `yaml:"recipe"`
+	// Outputs are the names of the outputs taken, all of them when empty.
+	Outputs []string `yaml:"outputs,omitempty"`
+}
+
+// EnvWrites declares the keys a recipe sets in the env.hcl file of its environment
+// and the files it places in its env.d directory. The values and paths can refer to
+// the outputs of the run as $NAME or ${NAME}.
+type EnvWrites struct {
+	Vars map[string]string `yaml:"vars,omitempty"`
+	// Files are the paths of the files copied into env.d, by name. Relative paths
+	// are relative to the directory the recipe ran in, see Workspace.
+	Files map[string]string `yaml:"files,omitempty"`
+}
+
+// Retention is when the workspace of a run is kept once the run is over.
+type Retention string
+
+const (
+	// RetainOnFailure keeps the workspaces of the runs that didn't succeed, so they
+	// can be inspected or resumed.
+	RetainOnFailure Retention = "on-failure"
+	RetainAlways    Retention = "always"
+	RetainNever     Retention = "never"
+)
+
+// DefaultKeptWorkspaces is the number of workspaces of a recipe kept by default.
+const DefaultKeptWorkspaces = 5
+
+// Workspace isolates the runs of a recipe: each run gets its own copy of the recipe
+// directory, so runs don't share the files their commands generate.
+type Workspace struct {
+	Isolated bool `yaml:"isolated"`
+	// Retain is when the workspace of a run is kept, on-failure by default.
+	Retain Retention `yaml:"retain,omitempty"`
+	// Keep is the maximum number of workspaces of the recipe kept, the oldest are
+	// removed first.
+	Keep int `yaml:"keep,omitempty"`
+}
+
+// Retains returns true if the workspace of a run that succeeded, or not, is kept.
+func (w *Workspace) Retains(succeeded bool) bool {
+	switch w.Retain {
+	case RetainAlways:
+		return true
+	case RetainNever:
+		return false
+	}
+	return !succeeded
+}
+
+// MaxKept returns the maximum number of workspaces of the recipe kept.
+func (w *Workspace) MaxKept() int {
+	if w.Keep > 0 {
+		return w.Keep
+	}
+	return DefaultKeptWorkspaces
 }
 
 type Recipe struct {
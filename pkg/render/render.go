@@ -0,0 +1,228 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package render renders the structured output of commands, JSON or YAML, as
+// declared by their render block in a Taskfile: as a table, a highlighted YAML
+// document or a key/value summary.
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2/quick"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"gopkg.in/yaml.v3"
+
+	"github.com/hypershift-community/hyper-console/pkg/jsonpath"
+	"github.com/hypershift-community/hyper-console/pkg/task/taskfile/ast"
+)
+
+var (
+	headerStyle = lipgloss.NewStyle().Bold(true).Padding(0, 1)
+	cellStyle   = lipgloss.NewStyle().Padding(0, 1)
+	keyStyle    = lipgloss.NewStyle().Bold(true)
+)
+
+// Render renders the output of a command as declared by r.
+func Render(r *ast.Render, output []byte) (string, error) {
+	data, err := Decode(output)
+	if err != nil {
+		return "", err
+	}
+	if r.Path != "" {
+		values := jsonpath.MustParse(r.Path).Find(data)
+		switch {
+		case len(values) == 1:
+			data = values[0]
+		default:
+			data = values
+		}
+	}
+	switch r.Format {
+	case ast.RenderTable:
+		return Table(data, r.Fields), nil
+	case ast.RenderYAML:
+		return YAML(data)
+	case ast.RenderSummary:
+		return Summary(data, r.Fields), nil
+	}
+	return "", fmt.Errorf("unknown render format %q", r.Format)
+}
+
+// Decode decodes the output of a command, either JSON or YAML.
+func Decode(output []byte) (any, error) {
+	if trimmed := bytes.TrimSpace(output); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		if data, err := jsonpath.DecodeJSON(trimmed); err == nil {
+			return data, nil
+		}
+	}
+	var data any
+	if err := yaml.Unmarshal(output, &data); err != nil {
+		return nil, fmt.Errorf("output is neither JSON nor YAML: %w", err)
+	}
+	if data == nil {
+		return nil, fmt.Errorf("no output to render")
+	}
+	return data, nil
+}
+
+// Table renders the data as a table with a row per item: the elements of the data
+// if it is a list, of its items if it is a Kubernetes list, or the data itself.
+// Without fields, the columns are the scalar values of the first item.
+func Table(data any, fields []*ast.RenderField) string {
+	items := rowsOf(data)
+	if len(fields) == 0 && len(items) > 0 {
+		fields = scalarFields(items[0])
+	}
+	headers := make([]string, len(fields))
+	paths := make([]*jsonpath.Template, len(fields))
+	for i, f := range fields {
+		headers[i] = strings.ToUpper(f.Name)
+		paths[i] = jsonpath.MustParse(f.Path)
+	}
+	t := table.New().
+		Border(lipgloss.HiddenBorder()).
+		BorderTop(false).
+		BorderBottom(false).
+		BorderLeft(false).
+		BorderRight(false).
+		BorderColumn(false).
+		BorderHeader(false).
+		Headers(headers...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == table.HeaderRow {
+				return headerStyle
+			}
+			return cellStyle
+		})
+	for _, item := range items {
+		row := make([]string, len(paths))
+		for i, p := range paths {
+			row[i] = p.Execute(item)
+		}
+		t.Row(row...)
+	}
+	if len(items) == 0 {
+		return t.String() + "\nNo items."
+	}
+	return t.String()
+}
+
+func rowsOf(data any) []any {
+	switch d := data.(type) {
+	case []any:
+		return d
+	case map[string]any:
+		if items, ok := d["items"].([]any); ok {
+			return items
+		}
+	}
+	return []any{data}
+}
+
+// scalarFields returns a field for each key of the given map with a scalar value.
+func scalarFields(item any) []*ast.RenderField {
+	m, ok := item.(map[string]any)
+	if !ok {
+		return []*ast.RenderField{{Name: "value", Path: "{.}"}}
+	}
+	var fields []*ast.RenderField
+	for _, k := range sortedKeys(m) {
+		switch m[k].(type) {
+		case map[string]any, []any:
+			continue
+		}
+		fields = append(fields, &ast.RenderField{Name: k, Path: fmt.Sprintf("{['%s']}", k)})
+	}
+	return fields
+}
+
+// YAML renders the data as a YAML document highlighted for the terminal.
+func YAML(data any) (string, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(yamlValue(data)); err != nil {
+		return "", err
+	}
+	doc := strings.TrimSuffix(buf.String(), "\n")
+	var out bytes.Buffer
+	if err := quick.Highlight(&out, doc, "yaml", "terminal256", "monokai"); err != nil {
+		return doc, nil
+	}
+	return out.String(), nil
+}
+
+// yamlValue converts the JSON numbers so that they are encoded as numbers rather
+// than strings.
+func yamlValue(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[k] = yamlValue(e)
+		}
+		return m
+	case []any:
+		s := make([]any, len(v))
+		for i, e := range v {
+			s[i] = yamlValue(e)
+		}
+		return s
+	}
+	return v
+}
+
+// Summary renders the given fields of the data as aligned key/value pairs. Without
+// fields, the scalar values of the data are summarized.
+func Summary(data any, fields []*ast.RenderField) string {
+	if len(fields) == 0 {
+		fields = scalarFields(data)
+	}
+	width := 0
+	for _, f := range fields {
+		width = max(width, lipgloss.Width(f.Name)+1)
+	}
+	lines := make([]string, len(fields))
+	for i, f := range fields {
+		value := jsonpath.MustParse(f.Path).Execute(data)
+		if value == "" {
+			value = "-"
+		}
+		lines[i] = fmt.Sprintf("%s  %s", keyStyle.Render(fmt.Sprintf("%-*s", width, f.Name+":")), value)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
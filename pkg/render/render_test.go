@@ -0,0 +1,88 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hypershift-community/hyper-console/pkg/task/taskfile/ast"
+)
+
+const hostedClusters = `{
+  "kind": "HostedClusterList",
+  "items": [
+    {"metadata": {"name": "alpha"}, "spec": {"release": {"image": "4.16"}}, "status": {"conditions": [{"type": "Available", "status": "True"}]}},
+    {"metadata": {"name": "beta"}, "spec": {"release": {"image": "4.15"}}, "status": {"conditions": [{"type": "Available", "status": "False"}]}}
+  ]
+}`
+
+func render(t *testing.T, r *ast.Render, output string) []string {
+	t.Helper()
+	out, err := Render(r, []byte(output))
+	require.NoError(t, err)
+	lines := strings.Split(ansi.Strip(out), "\n")
+	for i, l := range lines {
+		lines[i] = strings.Join(strings.Fields(l), " ")
+	}
+	return lines
+}
+
+func TestRender_Table(t *testing.T) {
+	lines := render(t, &ast.Render{
+		Format: ast.RenderTable,
+		Fields: []*ast.RenderField{
+			{Name: "name", Path: "{.metadata.name}"},
+			{Name: "release", Path: "{.spec.release.image}"},
+			{Name: "available", Path: `{.status.conditions[?(@.type=="Available")].status}`},
+		},
+	}, hostedClusters)
+	require.Equal(t, []string{"NAME RELEASE AVAILABLE", "alpha 4.16 True", "beta 4.15 False"}, lines)
+
+	// The scalar values of the items are the default columns
+	lines = render(t, &ast.Render{Format: ast.RenderTable}, "- name: a\n  size: 1\n  tags: [x]\n- name: b\n  size: 2\n")
+	require.Equal(t, []string{"NAME SIZE", "a 1", "b 2"}, lines)
+}
+
+func TestRender_YAML(t *testing.T) {
+	lines := render(t, &ast.Render{Format: ast.RenderYAML, Path: "{.items[0].metadata}"}, hostedClusters)
+	require.Equal(t, []string{"name: alpha"}, lines)
+
+	lines = render(t, &ast.Render{Format: ast.RenderYAML}, `{"replicas": 2, "ratio": 0.5}`)
+	require.Equal(t, []string{"ratio: 0.5", "replicas: 2"}, lines)
+}
+
+func TestRender_Summary(t *testing.T) {
+	lines := render(t, &ast.Render{
+		Format: ast.RenderSummary,
+		Path:   "{.items[1]}",
+		Fields: []*ast.RenderField{
+			{Name: "Cluster", Path: "{.metadata.name}"},
+			{Name: "Version", Path: "{.spec.release.image}"},
+			{Name: "Endpoint", Path: "{.status.endpoint}"},
+		},
+	}, hostedClusters)
+	require.Equal(t, []string{"Cluster: beta", "Version: 4.15", "Endpoint: -"}, lines)
+}
+
+func TestRender_InvalidOutput(t *testing.T) {
+	_, err := Render(&ast.Render{Format: ast.RenderYAML}, []byte("{not: [valid"))
+	require.Error(t, err)
+	_, err = Render(&ast.Render{Format: ast.RenderYAML}, nil)
+	require.Error(t, err)
+}
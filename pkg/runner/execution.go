@@ -0,0 +1,273 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/env"
+	"github.com/hypershift-community/hyper-console/pkg/history"
+	"github.com/hypershift-community/hyper-console/pkg/recipes"
+	taskerrors "github.com/hypershift-community/hyper-console/pkg/task/errors"
+	"github.com/hypershift-community/hyper-console/pkg/taskexec"
+	"github.com/hypershift-community/hyper-console/pkg/workspace"
+)
+
+// Execution is a run of a recipe set up to execute, whether unattended, see Run,
+// or in the run view.
+type Execution struct {
+	Iterator taskexec.ExecutorIterator
+	// Steps is the number of steps of the run.
+	Steps int
+	RunID string
+	// Dir is the directory the commands run in: the workspace of the run when the
+	// recipe is isolated, else the recipe directory.
+	Dir string
+	// Workspace is the workspace of the run, empty when the recipe isn't isolated.
+	Workspace    string
+	ArtifactsDir string
+
+	cfg    *config.Config
+	recipe recipes.Recipe
+}
+
+// Prepare sets up the run of the recipe in its environment: the outputs it starts
+// with, see inputs, its workspace, its artifacts directory and the iterator over its
+// steps. A resumed run is not moved to the step it resumes from.
+func Prepare(recipe recipes.Recipe, opts Options) (*Execution, error) {
+	cfg := opts.Config
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	x := &Execution{RunID: opts.RunID, cfg: cfg, recipe: recipe}
+	if x.RunID == "" {
+		x.RunID = history.NewRunID()
+	}
+
+	var options []taskexec.TaskOption
+	var environment *env.Env
+	if recipe.Environment != "" {
+		e, err := env.Load(filepath.Join(cfg.EnvironmentsDir, recipe.Environment))
+		if err != nil {
+			return nil, fmt.Errorf("error loading environment: %w", err)
+		}
+		environment = e
+		options = append(options, taskexec.WithEnv(e))
+	}
+	if in := inputs(recipe, opts, environment); len(in) > 0 {
+		options = append(options, taskexec.WithOutputs(in))
+	}
+	if err := x.prepareWorkspace(opts.ResumeFrom); err != nil {
+		return nil, fmt.Errorf("error creating workspace: %w", err)
+	}
+	if err := x.allocateArtifactsDir(opts.History, opts.ResumeFrom); err != nil {
+		x.Finish(false)
+		return nil, fmt.Errorf("error creating artifacts directory: %w", err)
+	}
+	options = append(options, taskexec.WithSpecialVars(map[string]string{
+		taskexec.EnvVar:       recipe.Environment,
+		taskexec.RecipeVar:    recipe.Name,
+		taskexec.RunIDVar:     x.RunID,
+		taskexec.ArtifactsVar: x.ArtifactsDir,
+	}))
+	if recipe.Timeout > 0 {
+		options = append(options, taskexec.WithTimeout(recipe.Timeout))
+	}
+	iter, n, err := taskexec.NewExecutorIterator(x.Dir, options...)
+	if err != nil {
+		x.Finish(false)
+		return nil, fmt.Errorf("error setting up recipe executor: %w", err)
+	}
+	x.Iterator, x.Steps = iter, n
+	return x, nil
+}
+
+// inputs returns the outputs the run starts with: the ones of the last successful
+// runs of the recipes it takes inputs from, then the ones of the run being resumed,
+// unless the environment sets them, then the inputs given explicitly.
+func inputs(recipe recipes.Recipe, opts Options, environment *env.Env) map[string]string {
+	in := make(map[string]string)
+	if len(recipe.Inputs) > 0 && opts.History != nil {
+		runs, err := opts.History.List()
+		if err != nil {
+			Logger.Error("Error reading run history", "error", err)
+		}
+		for _, input := range recipe.Inputs {
+			outputs := history.LatestOutputs(runs, input.Recipe, recipe.Environment)
+			if outputs == nil {
+				Logger.Warn("No successful run to take the outputs of", "recipe", input.Recipe, "environment", recipe.Environment)
+				continue
+			}
+			for k, v := range outputs {
+				if len(input.Outputs) == 0 || slices.Contains(input.Outputs, k) {
+					in[k] = v
+				}
+			}
+		}
+	}
+	if opts.ResumeFrom != nil {
+		maps.Copy(in, opts.ResumeFrom.Outputs)
+	}
+	if environment != nil {
+		for k := range environment.Vars {
+			delete(in, k)
+		}
+	}
+	maps.Copy(in, opts.Inputs)
+	return in
+}
+
+// prepareWorkspace sets the directory the commands of the run run in: the recipe
+// directory, or a copy of it when the recipe is isolated. A resumed run continues
+// in the workspace of the run it resumes if it was retained.
+func (x *Execution) prepareWorkspace(prev *history.Run) error {
+	x.Dir = x.recipe.Dir
+	w := x.recipe.Workspace
+	if w == nil || !w.Isolated {
+		return nil
+	}
+	if prev != nil && prev.Workspace != "" && workspace.Exists(prev.Workspace) {
+		x.Workspace = prev.Workspace
+	} else {
+		dir, err := workspace.Create(x.workspacesRoot(), x.recipe.Dir, x.RunID)
+		if err != nil {
+			return err
+		}
+		x.Workspace = dir
+	}
+	x.Dir = x.Workspace
+	return nil
+}
+
+// workspacesRoot returns the directory where the workspaces of the recipe are kept.
+func (x *Execution) workspacesRoot() string {
+	root := x.cfg.WorkspacesDir
+	if root == "" {
+		root = config.DefaultWorkspacesDir()
+	}
+	return filepath.Join(root, filepath.Base(x.recipe.Dir))
+}
+
+// allocateArtifactsDir creates the directory the commands of the run write their
+// artifacts to. It is kept with the run history, and shared with the run resumed.
+// Without history, the artifacts go to a temporary directory.
+func (x *Execution) allocateArtifactsDir(store *history.Store, prev *history.Run) error {
+	switch {
+	case prev != nil && prev.ArtifactsDir != "":
+		x.ArtifactsDir = prev.ArtifactsDir
+	case store != nil:
+		x.ArtifactsDir = store.ArtifactsDir(x.RunID)
+	default:
+		dir, err := os.MkdirTemp("", "hyperdev-artifacts-")
+		if err != nil {
+			return err
+		}
+		x.ArtifactsDir = dir
+		return nil
+	}
+	return os.MkdirAll(x.ArtifactsDir, 0o755)
+}
+
+// Finish removes the workspace of the run once it is over unless the retention of
+// the recipe keeps it, and prunes the oldest workspaces of the recipe. It returns
+// true if the workspace was kept.
+func (x *Execution) Finish(succeeded bool) bool {
+	if x.Workspace == "" {
+		return false
+	}
+	w := x.recipe.Workspace
+	kept := w.Retains(succeeded)
+	if !kept {
+		if err := workspace.Remove(x.Workspace); err != nil {
+			Logger.Error("Error removing workspace", "dir", x.Workspace, "error", err)
+		}
+	}
+	removed, err := workspace.Prune(x.workspacesRoot(), w.MaxKept())
+	if err != nil {
+		Logger.Error("Error pruning workspaces", "recipe", x.recipe.Name, "error", err)
+	}
+	for _, dir := range removed {
+		Logger.Debug("Removed workspace", "dir", dir)
+	}
+	return kept
+}
+
+// EnvWrites plans the changes the recipe makes to its environment once the run
+// succeeded, given the outputs of the run the keys and paths refer to. There are
+// none when the recipe doesn't declare any or runs without environment.
+func (x *Execution) EnvWrites(outputs map[string]string) ([]env.Change, error) {
+	w := x.recipe.EnvWrites
+	if w == nil || x.recipe.Environment == "" {
+		return nil, nil
+	}
+	vars := make(map[string]string, len(w.Vars))
+	for k, v := range w.Vars {
+		value, err := expandOutputs(v, outputs)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		vars[k] = value
+	}
+	contents := make(map[string]string, len(w.Files))
+	for name, p := range w.Files {
+		path, err := expandOutputs(p, outputs)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(x.Dir, path)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		contents[name] = string(content)
+	}
+	return env.PlanChanges(x.EnvDir(), vars, contents)
+}
+
+// EnvDir returns the directory of the environment of the run.
+func (x *Execution) EnvDir() string {
+	return filepath.Join(x.cfg.EnvironmentsDir, x.recipe.Environment)
+}
+
+// expandOutputs replaces the references to the outputs of the run in s.
+func expandOutputs(s string, outputs map[string]string) (string, error) {
+	var missing []string
+	expanded := os.Expand(s, func(name string) string {
+		v, ok := outputs[name]
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("unknown outputs %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// TimedOut returns true if the error is the timeout of a command.
+func TimedOut(err error) bool {
+	var timeoutErr *taskerrors.TaskTimeoutError
+	return errors.As(err, &timeoutErr)
+}
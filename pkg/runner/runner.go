@@ -0,0 +1,202 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package runner runs recipes unattended, outside of the run view: the steps run in
+// order until one fails, then the deferred commands run, as they do in the run view
+// when nothing is paused. Batches and workflows are made of such runs. The run view
+// sets up its runs the same way, see Prepare.
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/env"
+	"github.com/hypershift-community/hyper-console/pkg/history"
+	"github.com/hypershift-community/hyper-console/pkg/logging"
+	"github.com/hypershift-community/hyper-console/pkg/recipes"
+	"github.com/hypershift-community/hyper-console/pkg/taskexec"
+)
+
+var Logger = logging.Logger
+
+// Options configures a run.
+type Options struct {
+	Config *config.Config
+	// History records the run when set.
+	History *history.Store
+	// RunID is the ID of the run, a new one is allocated when empty.
+	RunID string
+	// Inputs are outputs exposed to the commands from the start, on top of the
+	// ones taken from the runs of the recipe inputs, see taskexec.WithOutputs.
+	Inputs map[string]string
+	// ResumeFrom is a previous run of the recipe which is resumed from its failed
+	// step. The steps which succeeded before it are skipped, and the outputs, the
+	// artifacts and the workspace of the run are carried over.
+	ResumeFrom *history.Run
+	// Output returns the writer the output of the step at the given index goes to.
+	// The output is discarded when it is nil.
+	Output func(step int) io.Writer
+	// OnChange is called whenever the record of the run changes, e.g. a step
+	// started or finished. The record must not be modified.
+	OnChange func(r *history.Run)
+	// ApplyEnvWrites applies the changes the recipe makes to its environment once
+	// the run succeeded. They are otherwise recorded as pending, for the user to
+	// review and apply them from the run history.
+	ApplyEnvWrites bool
+}
+
+// Run runs the recipe in its environment and returns the record of the run, if its
+// execution could be set up, and the error which failed it. Cancelling the context
+// kills the current step and ends the run once its deferred commands ran, in which
+// case it is recorded as aborted. The changes the recipe makes to its environment
+// are planned once the run succeeded, and only applied with Options.ApplyEnvWrites.
+func Run(ctx context.Context, recipe recipes.Recipe, opts Options) (*history.Run, error) {
+	x, err := Prepare(recipe, opts)
+	if err != nil {
+		return nil, err
+	}
+	iter := x.Iterator
+	defer iter.Close()
+	prev := opts.ResumeFrom
+
+	steps := iter.Steps()
+	labels := make([]string, len(steps))
+	for i, s := range steps {
+		labels[i] = s.String()
+	}
+	record := history.NewRun(recipe, labels)
+	record.ID = x.RunID
+	record.ArtifactsDir = x.ArtifactsDir
+	record.Workspace = x.Workspace
+	if prev != nil {
+		record.ResumedFrom = prev.ID
+		record.Outputs = maps.Clone(prev.Outputs)
+		if start := prev.ResumableStep(); start > 0 {
+			if err := iter.Seek(start); err != nil {
+				x.Finish(false)
+				return nil, fmt.Errorf("error resuming recipe: %w", err)
+			}
+			for i := 0; i < start; i++ {
+				record.Steps[i].Status = history.StepSkipped
+			}
+			for _, s := range prev.StaleSteps(start) {
+				record.Steps[s.Index] = s
+			}
+		}
+	}
+	changed := func() {
+		if opts.OnChange != nil {
+			opts.OnChange(record)
+		}
+		save(opts.History, record)
+	}
+	changed()
+
+	var failure error
+	cancelled := false
+	for iter.HasNext() {
+		if failure == nil && !cancelled && ctx.Err() != nil {
+			// Stop after the current step, the deferred commands still run
+			cancelled = true
+			iter.Cleanup(nil)
+			continue
+		}
+		ex, err := iter.Next()
+		if err != nil {
+			failure = err
+			break
+		}
+		step := ex.Step()
+		out := io.Discard
+		if opts.Output != nil {
+			out = opts.Output(step.Index)
+		}
+		ex.SetIO(nil, out, out)
+		record.StartStep(step.Index)
+		changed()
+		err = ex.Execute(ctx)
+		status := history.StepSucceeded
+		switch {
+		case ex.Skipped():
+			status = history.StepSkipped
+		case TimedOut(err):
+			status = history.StepTimedOut
+		case err != nil:
+			status = history.StepFailed
+		}
+		record.FinishStep(step.Index, status, err)
+		if outputs := ex.Outputs(); len(outputs) > 0 {
+			record.Outputs = outputs
+		}
+		changed()
+		if err != nil && step.Phase == taskexec.PhaseMain && failure == nil && !cancelled {
+			if ctx.Err() != nil {
+				// The step was killed, the deferred commands still run
+				cancelled = true
+				iter.Cleanup(nil)
+				continue
+			}
+			failure = err
+			iter.Cleanup(err)
+		}
+	}
+	if failure == nil && !cancelled {
+		if err := planEnvWrites(x, record, opts.ApplyEnvWrites); err != nil {
+			failure = fmt.Errorf("error writing to environment %s: %w", recipe.Environment, err)
+		}
+	}
+	switch {
+	case failure != nil:
+		record.Finish(history.StatusFailed, failure)
+	case cancelled:
+		record.Finish(history.StatusAborted, nil)
+	default:
+		record.Finish(history.StatusSucceeded, nil)
+	}
+	changed()
+	x.Finish(record.Status == history.StatusSucceeded)
+	return record, failure
+}
+
+// planEnvWrites plans the changes the recipe makes to its environment and records
+// them as pending, or applies them and records them so they can be reverted from
+// the history.
+func planEnvWrites(x *Execution, record *history.Run, apply bool) error {
+	changes, err := x.EnvWrites(record.Outputs)
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+	if !apply {
+		record.EnvPending = changes
+		return nil
+	}
+	if err := env.Apply(x.EnvDir(), changes); err != nil {
+		return err
+	}
+	record.EnvChanges = changes
+	return nil
+}
+
+func save(store *history.Store, record *history.Run) {
+	if store == nil {
+		return
+	}
+	if err := store.Save(record); err != nil {
+		Logger.Error("Error saving run history", "run", record.ID, "error", err)
+	}
+}
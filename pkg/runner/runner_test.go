@@ -0,0 +1,143 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/env"
+	"github.com/hypershift-community/hyper-console/pkg/history"
+	"github.com/hypershift-community/hyper-console/pkg/recipes"
+)
+
+// newRecipe writes a recipe running the given commands.
+func newRecipe(t *testing.T, name, cmds string) recipes.Recipe {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Taskfile.yml"), []byte(`version: '3'
+env:
+  REGION: ""
+tasks:
+  default:
+    silent: true
+    cmds:
+`+cmds), 0o644))
+	return recipes.Recipe{RecipeInfo: recipes.RecipeInfo{Name: name, Environment: "dev"}, Dir: dir}
+}
+
+// newConfig creates the dev environment, setting REGION.
+func newConfig(t *testing.T) *config.Config {
+	envsDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(envsDir, "dev"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(envsDir, "dev", "env.hcl"), []byte(`REGION = "us-east-1"`+"\n"), 0o644))
+	return &config.Config{EnvironmentsDir: envsDir, HistoryDir: t.TempDir(), WorkspacesDir: t.TempDir()}
+}
+
+func TestRun_Inputs(t *testing.T) {
+	cfg := newConfig(t)
+	store := history.NewStore(cfg.HistoryDir)
+
+	create := newRecipe(t, "create", `      - |
+        echo "INFRA_ID=infra-1" >> "$HYPERDEV_OUTPUT"
+        echo "REGION=eu-west-1" >> "$HYPERDEV_OUTPUT"
+        echo "SECRET=s3cr3t" >> "$HYPERDEV_OUTPUT"
+`)
+	_, err := Run(context.Background(), create, Options{Config: cfg, History: store})
+	require.NoError(t, err)
+
+	use := newRecipe(t, "use", `      - echo "$INFRA_ID $REGION ${SECRET:-none} $NAME"
+`)
+	use.Inputs = []recipes.RecipeInput{{Recipe: "create", Outputs: []string{"INFRA_ID", "REGION"}}}
+	var out bytes.Buffer
+	_, err = Run(context.Background(), use, Options{
+		Config:  cfg,
+		History: store,
+		Inputs:  map[string]string{"NAME": "cluster1"},
+		Output:  func(int) io.Writer { return &out },
+	})
+	require.NoError(t, err)
+	// The environment takes precedence over the outputs of the recipes taken as
+	// inputs, and only the outputs listed are taken
+	require.Equal(t, "infra-1 us-east-1 none cluster1\n", out.String())
+}
+
+func TestRun_EnvWrites(t *testing.T) {
+	cfg := newConfig(t)
+	recipe := newRecipe(t, "create", `      - |
+        echo "INFRA_ID=infra-1" >> "$HYPERDEV_OUTPUT"
+        echo "kubeconfig of infra-1" > kubeconfig
+`)
+	recipe.Workspace = &recipes.Workspace{Isolated: true}
+	recipe.EnvWrites = &recipes.EnvWrites{
+		Vars:  map[string]string{"INFRA_ID": "$INFRA_ID"},
+		Files: map[string]string{"KUBECONFIG": "kubeconfig"},
+	}
+
+	// The changes are left pending for the user to confirm them
+	record, err := Run(context.Background(), recipe, Options{Config: cfg})
+	require.NoError(t, err)
+	require.Equal(t, history.StatusSucceeded, record.Status)
+	require.Len(t, record.EnvPending, 2)
+	require.Empty(t, record.EnvChanges)
+	e, err := env.Load(filepath.Join(cfg.EnvironmentsDir, "dev"))
+	require.NoError(t, err)
+	require.NotContains(t, e.Vars, "INFRA_ID")
+
+	record, err = Run(context.Background(), recipe, Options{Config: cfg, ApplyEnvWrites: true})
+	require.NoError(t, err)
+	require.Equal(t, history.StatusSucceeded, record.Status)
+	require.Len(t, record.EnvChanges, 2)
+	require.Empty(t, record.EnvPending)
+
+	e, err = env.Load(filepath.Join(cfg.EnvironmentsDir, "dev"))
+	require.NoError(t, err)
+	require.Equal(t, "infra-1", e.Vars["INFRA_ID"])
+	require.Equal(t, "us-east-1", e.Vars["REGION"])
+	content, err := os.ReadFile(e.Vars["KUBECONFIG"])
+	require.NoError(t, err)
+	require.Equal(t, "kubeconfig of infra-1\n", string(content))
+
+	// Nothing is written when the outputs referred to are missing
+	recipe.EnvWrites.Vars["CLUSTER"] = "$CLUSTER_NAME"
+	record, err = Run(context.Background(), recipe, Options{Config: cfg, ApplyEnvWrites: true})
+	require.ErrorContains(t, err, "unknown outputs CLUSTER_NAME")
+	require.Equal(t, history.StatusFailed, record.Status)
+	require.Empty(t, record.EnvChanges)
+}
+
+func TestRun_Abort(t *testing.T) {
+	cfg := newConfig(t)
+	recipe := newRecipe(t, "create", `      - defer: echo cleanup
+      - sleep 60
+`)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	var out bytes.Buffer
+	start := time.Now()
+	record, err := Run(ctx, recipe, Options{Config: cfg, Output: func(int) io.Writer { return &out }})
+	require.NoError(t, err)
+	// The running command is killed, and the deferred commands still run
+	require.Less(t, time.Since(start), 10*time.Second)
+	require.Equal(t, history.StatusAborted, record.Status)
+	require.Equal(t, "cleanup\n", out.String())
+}
@@ -0,0 +1,299 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package scrollback provides a line-indexed buffer for the output of commands. It
+// keeps the most recent lines in memory, up to a configurable cap, and spills the
+// older ones to disk so that any window of lines can be read back without keeping
+// the whole output in memory or re-rendering it.
+package scrollback
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxLines is the number of lines kept in memory when none is configured.
+const DefaultMaxLines = 10000
+
+// Buffer is a line-indexed scrollback buffer. It is safe for concurrent use.
+type Buffer struct {
+	mu       sync.Mutex
+	maxLines int
+	spillDir string
+
+	// lines are the most recent complete lines, kept in memory.
+	lines []string
+	// partial is the last line, until it is terminated by a newline.
+	partial []byte
+
+	spill     *os.File
+	spillSize int64
+	// offsets are the offsets of the lines spilled to disk.
+	offsets []int64
+	// dropped is the number of lines evicted that couldn't be spilled to disk. They
+	// are always the first ones, before the spilled ones.
+	dropped int
+}
+
+// Option configures a Buffer.
+type Option func(*Buffer)
+
+// WithMaxLines sets the number of lines kept in memory.
+func WithMaxLines(n int) Option {
+	return func(b *Buffer) {
+		if n > 0 {
+			b.maxLines = n
+		}
+	}
+}
+
+// WithSpillDir sets the directory where the lines evicted from memory are written.
+// Without one, the evicted lines are dropped.
+func WithSpillDir(dir string) Option {
+	return func(b *Buffer) {
+		b.spillDir = dir
+	}
+}
+
+// New creates an empty buffer.
+func New(opts ...Option) *Buffer {
+	b := &Buffer{maxLines: DefaultMaxLines}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Write appends p to the buffer, splitting it in lines.
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := len(p)
+	for {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			b.partial = append(b.partial, p...)
+			break
+		}
+		if len(b.partial) > 0 {
+			b.lines = append(b.lines, string(append(b.partial, p[:i]...)))
+			b.partial = b.partial[:0]
+		} else {
+			b.lines = append(b.lines, string(p[:i]))
+		}
+		p = p[i+1:]
+	}
+	if len(b.lines) > b.maxLines {
+		b.evict()
+	}
+	return n, nil
+}
+
+// WriteString appends s to the buffer, splitting it in lines.
+func (b *Buffer) WriteString(s string) (int, error) {
+	return b.Write([]byte(s))
+}
+
+// evict moves the oldest lines out of memory. A quarter of the cap is evicted at
+// once so that the cost of moving the remaining lines is amortized.
+func (b *Buffer) evict() {
+	n := len(b.lines) - b.maxLines + b.maxLines/4
+	n = min(n, len(b.lines))
+	if err := b.spillLines(b.lines[:n]); err != nil {
+		// The lines spilled before are dropped too, so the lines dropped stay the
+		// first ones
+		_ = b.closeSpill()
+		b.dropped += n
+	}
+	b.lines = append(b.lines[:0:0], b.lines[n:]...)
+}
+
+func (b *Buffer) spillLines(lines []string) error {
+	if b.spillDir == "" {
+		return fmt.Errorf("no spill directory")
+	}
+	if b.spill == nil {
+		if b.dropped > 0 {
+			// The spilled lines must be contiguous
+			return fmt.Errorf("spill file unavailable")
+		}
+		f, err := os.CreateTemp(b.spillDir, "scrollback-*.log")
+		if err != nil {
+			return err
+		}
+		b.spill = f
+	}
+	var buf bytes.Buffer
+	offsets := make([]int64, len(lines))
+	for i, l := range lines {
+		offsets[i] = b.spillSize + int64(buf.Len())
+		buf.WriteString(l)
+		buf.WriteByte('\n')
+	}
+	if _, err := b.spill.WriteAt(buf.Bytes(), b.spillSize); err != nil {
+		return err
+	}
+	b.spillSize += int64(buf.Len())
+	b.offsets = append(b.offsets, offsets...)
+	return nil
+}
+
+// Len returns the number of lines written to the buffer, including the last one if
+// it isn't terminated yet and the ones dropped, see Dropped.
+func (b *Buffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.len()
+}
+
+func (b *Buffer) len() int {
+	n := b.dropped + len(b.offsets) + len(b.lines)
+	if len(b.partial) > 0 {
+		n++
+	}
+	return n
+}
+
+// Dropped returns the number of lines that were evicted from memory and couldn't be
+// spilled to disk. They are the first lines of the buffer, which keep their index
+// but read as empty lines.
+func (b *Buffer) Dropped() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+// Lines returns at most n lines starting at the given line. The lines dropped read
+// as empty lines.
+func (b *Buffer) Lines(from, n int) ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	from = max(0, from)
+	to := min(from+n, b.len())
+	if from >= to {
+		return nil, nil
+	}
+	lines := make([]string, 0, to-from)
+	for ; from < min(to, b.dropped); from++ {
+		lines = append(lines, "")
+	}
+	// The indexes of the lines kept, spilled ones first
+	from, to = from-b.dropped, to-b.dropped
+	if spilled := len(b.offsets); from < spilled {
+		end := min(to, spilled)
+		read, err := b.readSpilled(from, end)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, read...)
+		from = end
+	}
+	for i := from; i < to; i++ {
+		switch j := i - len(b.offsets); {
+		case j < len(b.lines):
+			lines = append(lines, b.lines[j])
+		default:
+			lines = append(lines, string(b.partial))
+		}
+	}
+	return lines, nil
+}
+
+// findChunk is the number of lines read at once when searching the buffer.
+const findChunk = 1024
+
+// Find returns the index of the first matching line, starting at the given line and
+// going forward, or backward if requested. It returns -1 if no line matches. The
+// lines dropped are not searched.
+func (b *Buffer) Find(from int, backward bool, match func(line string) bool) (int, error) {
+	dropped := b.Dropped()
+	if backward {
+		for from >= dropped {
+			start := max(dropped, from-findChunk+1)
+			lines, err := b.Lines(start, from-start+1)
+			if err != nil {
+				return -1, err
+			}
+			for i := len(lines) - 1; i >= 0; i-- {
+				if match(lines[i]) {
+					return start + i, nil
+				}
+			}
+			from = start - 1
+		}
+		return -1, nil
+	}
+	for from = max(dropped, from); ; from += findChunk {
+		lines, err := b.Lines(from, findChunk)
+		if err != nil || len(lines) == 0 {
+			return -1, err
+		}
+		for i, l := range lines {
+			if match(l) {
+				return from + i, nil
+			}
+		}
+	}
+}
+
+// readSpilled reads the spilled lines in [from, to) with a single read.
+func (b *Buffer) readSpilled(from, to int) ([]string, error) {
+	start := b.offsets[from]
+	end := b.spillSize
+	if to < len(b.offsets) {
+		end = b.offsets[to]
+	}
+	buf := make([]byte, end-start)
+	if _, err := b.spill.ReadAt(buf, start); err != nil {
+		return nil, fmt.Errorf("error reading scrollback: %w", err)
+	}
+	return strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n"), nil
+}
+
+// String returns the content of the buffer. It reads the whole buffer, spilled lines
+// included, so it is meant for small buffers and tests.
+func (b *Buffer) String() string {
+	lines, err := b.Lines(0, b.Len())
+	if err != nil {
+		return ""
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Close releases the spill file, if any. The lines spilled are dropped.
+func (b *Buffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closeSpill()
+}
+
+// closeSpill removes the spill file and drops the lines spilled, the lock is held.
+func (b *Buffer) closeSpill() error {
+	if b.spill == nil {
+		return nil
+	}
+	name := b.spill.Name()
+	err := b.spill.Close()
+	b.spill = nil
+	b.spillSize = 0
+	b.dropped += len(b.offsets)
+	b.offsets = nil
+	if rmErr := os.Remove(name); err == nil {
+		err = rmErr
+	}
+	return err
+}
@@ -0,0 +1,197 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scrollback
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_Lines(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxLines      int
+		spill         bool
+		writes        []string
+		from, n       int
+		expectedLen   int
+		expectedLines []string
+		expectedDrops int
+	}{
+		{
+			name:          "lines should be split across writes",
+			writes:        []string{"one\ntw", "o\nthr", "ee"},
+			from:          0,
+			n:             10,
+			expectedLen:   3,
+			expectedLines: []string{"one", "two", "three"},
+		},
+		{
+			name:          "a window should return only the requested lines",
+			writes:        []string{"1\n2\n3\n4\n5\n"},
+			from:          1,
+			n:             2,
+			expectedLen:   5,
+			expectedLines: []string{"2", "3"},
+		},
+		{
+			name:          "lines over the cap should be dropped without a spill directory",
+			maxLines:      4,
+			writes:        []string{"1\n2\n3\n4\n5\n6\n"},
+			from:          0,
+			n:             10,
+			expectedLen:   6,
+			expectedLines: []string{"", "", "", "4", "5", "6"},
+			expectedDrops: 3,
+		},
+		{
+			name:          "lines over the cap should be read back from the spill file",
+			maxLines:      4,
+			spill:         true,
+			writes:        []string{"1\n2\n3\n4\n", "5\n6\n7\n8\n9\n", "10"},
+			from:          1,
+			n:             8,
+			expectedLen:   10,
+			expectedLines: []string{"2", "3", "4", "5", "6", "7", "8", "9"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []Option{WithMaxLines(tt.maxLines)}
+			if tt.spill {
+				opts = append(opts, WithSpillDir(t.TempDir()))
+			}
+			b := New(opts...)
+			defer b.Close()
+			for _, w := range tt.writes {
+				_, err := b.WriteString(w)
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.expectedLen, b.Len())
+			lines, err := b.Lines(tt.from, tt.n)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedLines, lines)
+			require.Equal(t, tt.expectedDrops, b.Dropped())
+		})
+	}
+}
+
+func TestBuffer_DroppedKeepIndexes(t *testing.T) {
+	b := New(WithMaxLines(4))
+	for i := 0; i < 10; i++ {
+		_, err := fmt.Fprintf(b, "line %d\n", i)
+		require.NoError(t, err)
+	}
+	require.Positive(t, b.Dropped())
+
+	// The lines kept are still found at the index they were written at
+	require.Equal(t, 10, b.Len())
+	lines, err := b.Lines(8, 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"line 8", "line 9"}, lines)
+	i, err := b.Find(0, false, func(l string) bool { return l == "line 9" })
+	require.NoError(t, err)
+	require.Equal(t, 9, i)
+	i, err = b.Find(9, true, func(l string) bool { return l == "" })
+	require.NoError(t, err)
+	require.Equal(t, -1, i)
+}
+
+func TestBuffer_Close(t *testing.T) {
+	dir := t.TempDir()
+	b := New(WithMaxLines(2), WithSpillDir(dir))
+	_, err := b.WriteString("1\n2\n3\n4\n")
+	require.NoError(t, err)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	require.NoError(t, b.Close())
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// The lines spilled are dropped, the others keep their index
+	require.Equal(t, 4, b.Len())
+	lines, err := b.Lines(3, 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"4"}, lines)
+}
+
+// BenchmarkBuffer_Window measures reading the window of lines shown on screen. It
+// should stay flat as the output grows, spilled to disk or not.
+func BenchmarkBuffer_Window(b *testing.B) {
+	const height = 50
+	line := strings.Repeat("x", 120) + "\n"
+	for _, size := range []int{1_000, 100_000, 1_000_000} {
+		buf := New(WithSpillDir(b.TempDir()))
+		for i := 0; i < size; i++ {
+			_, _ = buf.WriteString(line)
+		}
+		b.Run(fmt.Sprintf("tail/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := buf.Lines(buf.Len()-height, height); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("head/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := buf.Lines(0, height); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		_ = buf.Close()
+	}
+}
+
+func TestBuffer_Find(t *testing.T) {
+	b := New(WithMaxLines(4), WithSpillDir(t.TempDir()))
+	defer b.Close()
+	for i := 0; i < 3000; i++ {
+		_, err := fmt.Fprintf(b, "line %d\n", i)
+		require.NoError(t, err)
+	}
+	match := func(s string) func(string) bool {
+		return func(l string) bool { return strings.HasSuffix(l, s) }
+	}
+
+	tests := []struct {
+		name     string
+		from     int
+		backward bool
+		suffix   string
+		expected int
+	}{
+		{name: "forward from the start", from: 0, suffix: " 7", expected: 7},
+		{name: "forward across chunks", from: 10, suffix: " 2500", expected: 2500},
+		{name: "forward past the match", from: 8, suffix: " 7", expected: -1},
+		{name: "backward across chunks", from: 2999, backward: true, suffix: " 3", expected: 3},
+		{name: "backward past the match", from: 2, backward: true, suffix: " 3", expected: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, err := b.Find(tt.from, tt.backward, match(tt.suffix))
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, i)
+		})
+	}
+}
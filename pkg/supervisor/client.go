@@ -0,0 +1,193 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hypershift-community/hyper-console/pkg/config"
+)
+
+// ErrNotRunning is returned when the supervisor can't be reached.
+var ErrNotRunning = errors.New("the supervisor isn't running")
+
+// startTimeout is how long the supervisor started in the background has to listen.
+const startTimeout = 10 * time.Second
+
+// Client is a client of the supervisor listening on a unix socket.
+type Client struct {
+	socket string
+}
+
+func NewClient(socket string) *Client {
+	return &Client{socket: socket}
+}
+
+// Start starts running the recipe in the environment, the one of the recipe if
+// empty, with the given inputs.
+func (c *Client) Start(ctx context.Context, recipe, environment string, inputs map[string]string) (RunInfo, error) {
+	resp, err := c.call(ctx, Request{Op: OpStart, Recipe: recipe, Environment: environment, Inputs: inputs})
+	if err != nil {
+		return RunInfo{}, err
+	}
+	return *resp.Run, nil
+}
+
+// List returns the runs of the supervisor, in the order they were started.
+func (c *Client) List(ctx context.Context) ([]RunInfo, error) {
+	resp, err := c.call(ctx, Request{Op: OpList})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Runs, nil
+}
+
+// Abort aborts the run: its current step is killed and it ends once its deferred
+// commands ran.
+func (c *Client) Abort(ctx context.Context, id string) (RunInfo, error) {
+	resp, err := c.call(ctx, Request{Op: OpAbort, ID: id})
+	if err != nil {
+		return RunInfo{}, err
+	}
+	return *resp.Run, nil
+}
+
+// Attach writes the output of the run to w, from its start and then as it is
+// written, and returns the run once it ended. Cancelling the context detaches from
+// the run, which keeps running, and returns the error of the context.
+func (c *Client) Attach(ctx context.Context, id string, w io.Writer) (RunInfo, error) {
+	conn, dec, err := c.send(ctx, Request{Op: OpAttach, ID: id})
+	if err != nil {
+		return RunInfo{}, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	defer stop()
+	for {
+		var resp Response
+		if err := dec.Decode(&resp); err != nil {
+			if ctx.Err() != nil {
+				return RunInfo{}, ctx.Err()
+			}
+			return RunInfo{}, fmt.Errorf("error reading from the supervisor: %w", err)
+		}
+		switch {
+		case resp.Error != "":
+			return RunInfo{}, errors.New(resp.Error)
+		case resp.Done:
+			return *resp.Run, nil
+		}
+		if _, err := io.WriteString(w, resp.Output); err != nil {
+			return RunInfo{}, err
+		}
+	}
+}
+
+// call sends the request and reads its single response.
+func (c *Client) call(ctx context.Context, req Request) (Response, error) {
+	conn, dec, err := c.send(ctx, req)
+	if err != nil {
+		return Response{}, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	var resp Response
+	if err := dec.Decode(&resp); err != nil {
+		return Response{}, fmt.Errorf("error reading from the supervisor: %w", err)
+	}
+	if resp.Error != "" {
+		return Response{}, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+func (c *Client) send(ctx context.Context, req Request) (net.Conn, *json.Decoder, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", c.socket)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrNotRunning, err)
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("error writing to the supervisor: %w", err)
+	}
+	return conn, json.NewDecoder(conn), nil
+}
+
+// Socket returns the socket of the supervisor of the configuration.
+func Socket(cfg *config.Config) string {
+	if cfg.SupervisorSocket != "" {
+		return cfg.SupervisorSocket
+	}
+	return config.DefaultSupervisorSocket()
+}
+
+// Ensure returns a client of the supervisor of the configuration, starting it in
+// the background if it isn't running. It is started as the supervisor command of
+// the current executable, with the directories of the configuration.
+func Ensure(ctx context.Context, cfg *config.Config) (*Client, error) {
+	socket := Socket(cfg)
+	c := NewClient(socket)
+	if _, err := c.List(ctx); err == nil {
+		return c, nil
+	}
+	args := []string{"supervisor", "--socket", socket}
+	for flag, dir := range map[string]string{
+		"--recipes-dir":      cfg.RecipesDir,
+		"--environments-dir": cfg.EnvironmentsDir,
+		"--history-dir":      cfg.HistoryDir,
+		"--workspaces-dir":   cfg.WorkspacesDir,
+	} {
+		if dir == "" {
+			continue
+		}
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, flag, abs)
+	}
+	if err := os.MkdirAll(filepath.Dir(socket), 0o700); err != nil {
+		return nil, fmt.Errorf("error creating socket directory: %w", err)
+	}
+	if err := spawn(filepath.Join(filepath.Dir(socket), "supervisor.log"), args); err != nil {
+		return nil, fmt.Errorf("error starting the supervisor: %w", err)
+	}
+	// Wait for the supervisor to listen
+	ctx, cancel := context.WithTimeout(ctx, startTimeout)
+	defer cancel()
+	for {
+		if _, err := c.List(ctx); err == nil {
+			return c, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("the supervisor didn't start, see %s: %w", filepath.Join(filepath.Dir(socket), "supervisor.log"), ctx.Err())
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
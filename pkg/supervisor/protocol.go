@@ -0,0 +1,91 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package supervisor runs recipes in a background process so they survive the TUI
+// or the CLI which started them. The supervisor listens on a unix socket where
+// clients start runs, list them, the active ones and the last ones which ended,
+// abort them and attach to their output, replayed from the start of the run and
+// then streamed live until it ends or the client detaches.
+//
+// Each connection carries a single request, as a line of JSON, answered by one or
+// more lines of JSON: a single response, or the output of the run followed by a
+// final response once it is done when attaching.
+package supervisor
+
+import (
+	"time"
+
+	"github.com/hypershift-community/hyper-console/pkg/history"
+)
+
+// Op is an operation of the supervisor.
+type Op string
+
+const (
+	OpStart  Op = "start"
+	OpList   Op = "list"
+	OpAttach Op = "attach"
+	OpAbort  Op = "abort"
+)
+
+// Request is a request to the supervisor.
+type Request struct {
+	Op Op `json:"op"`
+	// Recipe, Environment and Inputs configure the run to start.
+	Recipe      string            `json:"recipe,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Inputs      map[string]string `json:"inputs,omitempty"`
+	// ID is the ID of the run to attach to or abort.
+	ID string `json:"id,omitempty"`
+}
+
+// Response is a response of the supervisor.
+type Response struct {
+	Error string    `json:"error,omitempty"`
+	Run   *RunInfo  `json:"run,omitempty"`
+	Runs  []RunInfo `json:"runs,omitempty"`
+	// Output is a chunk of the output of the run attached to.
+	Output string `json:"output,omitempty"`
+	// Done is set on the last response when attached to a run, once it ended.
+	Done bool `json:"done,omitempty"`
+}
+
+// RunInfo describes a run of the supervisor.
+type RunInfo struct {
+	// ID is the ID of the run, also its ID in the run history.
+	ID          string         `json:"id"`
+	Recipe      string         `json:"recipe"`
+	Environment string         `json:"environment,omitempty"`
+	Status      history.Status `json:"status"`
+	StartedAt   time.Time      `json:"startedAt"`
+	FinishedAt  time.Time      `json:"finishedAt,omitempty"`
+	// Steps and Step are the number of steps of the recipe and the index of the
+	// step running, or which ran last.
+	Steps int    `json:"steps,omitempty"`
+	Step  int    `json:"step,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Active returns whether the run is still running.
+func (r RunInfo) Active() bool {
+	return r.Status == history.StatusRunning
+}
+
+// Duration returns how long the run ran so far.
+func (r RunInfo) Duration() time.Duration {
+	if r.FinishedAt.IsZero() {
+		return time.Since(r.StartedAt)
+	}
+	return r.FinishedAt.Sub(r.StartedAt)
+}
@@ -0,0 +1,414 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/env"
+	"github.com/hypershift-community/hyper-console/pkg/history"
+	"github.com/hypershift-community/hyper-console/pkg/logging"
+	"github.com/hypershift-community/hyper-console/pkg/recipes"
+	"github.com/hypershift-community/hyper-console/pkg/runner"
+)
+
+var Logger = logging.Logger
+
+// maxOutput is the number of bytes of output kept for each run. The start of the
+// output of the runs writing more is dropped, and can't be replayed anymore.
+const maxOutput = 4 << 20
+
+// maxEnded is the number of runs which ended kept to be listed and attached to. The
+// oldest are forgotten by the supervisor, they remain in the run history.
+const maxEnded = 20
+
+// Server runs recipes on behalf of its clients.
+type Server struct {
+	cfg     *config.Config
+	history *history.Store
+	// ctx ends the runs when the server shuts down.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	runs []*run
+}
+
+// NewServer creates a server running the recipes of the configuration.
+func NewServer(cfg *config.Config) *Server {
+	s := &Server{cfg: cfg}
+	if cfg.HistoryDir != "" {
+		s.history = history.NewStore(cfg.HistoryDir)
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+// Listen listens on the unix socket, only reachable by the current user. A socket
+// left behind by a supervisor which didn't shut down cleanly is replaced, it fails
+// if a supervisor is listening on it.
+func Listen(socket string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(socket), 0o700); err != nil {
+		return nil, fmt.Errorf("error creating socket directory: %w", err)
+	}
+	if conn, err := net.Dial("unix", socket); err == nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("a supervisor is already listening on %s", socket)
+	}
+	_ = os.Remove(socket)
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on %s: %w", socket, err)
+	}
+	if err := os.Chmod(socket, 0o600); err != nil {
+		_ = l.Close()
+		return nil, fmt.Errorf("error restricting access to %s: %w", socket, err)
+	}
+	return l, nil
+}
+
+// Serve serves the clients until the context is cancelled or the listener fails.
+// The runs are then aborted, their current step is killed and they end once their
+// deferred commands ran, and Serve returns once they all ended.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	defer func() {
+		s.cancel()
+		s.wg.Wait()
+	}()
+	go func() {
+		<-ctx.Done()
+		_ = l.Close()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go s.handle(ctx, conn)
+	}
+}
+
+func (s *Server) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		Logger.Warn("Invalid supervisor request", "error", err)
+		return
+	}
+	enc := json.NewEncoder(conn)
+	respond := func(resp Response) {
+		if err := enc.Encode(resp); err != nil {
+			Logger.Warn("Error answering supervisor request", "op", req.Op, "error", err)
+		}
+	}
+	switch req.Op {
+	case OpStart:
+		info, err := s.start(req)
+		if err != nil {
+			respond(Response{Error: err.Error()})
+			return
+		}
+		respond(Response{Run: &info})
+	case OpList:
+		respond(Response{Runs: s.list()})
+	case OpAbort:
+		r := s.run(req.ID)
+		if r == nil {
+			respond(Response{Error: "unknown run " + req.ID})
+			return
+		}
+		r.cancel()
+		info := r.snapshot()
+		respond(Response{Run: &info})
+	case OpAttach:
+		r := s.run(req.ID)
+		if r == nil {
+			respond(Response{Error: "unknown run " + req.ID})
+			return
+		}
+		// The client detaches by closing the connection
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			_, _ = io.Copy(io.Discard, conn)
+			cancel()
+		}()
+		r.stream(ctx, enc)
+	default:
+		respond(Response{Error: fmt.Sprintf("unknown operation %q", req.Op)})
+	}
+}
+
+// start starts running the recipe in the background.
+func (s *Server) start(req Request) (RunInfo, error) {
+	if s.ctx.Err() != nil {
+		return RunInfo{}, errors.New("the supervisor is shutting down")
+	}
+	all, err := recipes.GetRecipes(s.cfg.RecipesDir)
+	if err != nil {
+		return RunInfo{}, err
+	}
+	var recipe *recipes.Recipe
+	for i := range all {
+		if all[i].Name == req.Recipe {
+			recipe = &all[i]
+			break
+		}
+	}
+	if recipe == nil {
+		return RunInfo{}, fmt.Errorf("recipe %s not found in %s", req.Recipe, s.cfg.RecipesDir)
+	}
+	if req.Environment != "" {
+		recipe.Environment = req.Environment
+	}
+	// The name of the environment is joined to the environments directory, it
+	// must be one of the environments found there
+	if recipe.Environment != "" {
+		environments, err := env.LoadAll(s.cfg.EnvironmentsDir)
+		if err != nil {
+			return RunInfo{}, err
+		}
+		if _, ok := environments[recipe.Environment]; !ok {
+			return RunInfo{}, fmt.Errorf("environment %s not found in %s", recipe.Environment, s.cfg.EnvironmentsDir)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	r := &run{
+		info: RunInfo{
+			ID:          history.NewRunID(),
+			Recipe:      recipe.Name,
+			Environment: recipe.Environment,
+			Status:      history.StatusRunning,
+			StartedAt:   time.Now(),
+		},
+		cancel:  cancel,
+		changed: make(chan struct{}),
+		header:  -1,
+	}
+	s.mu.Lock()
+	s.runs = append(s.runs, r)
+	s.mu.Unlock()
+	Logger.Info("Run started", "run", r.info.ID, "recipe", recipe.Name, "environment", recipe.Environment)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+		record, err := runner.Run(ctx, *recipe, runner.Options{
+			Config:   s.cfg,
+			History:  s.history,
+			RunID:    r.info.ID,
+			Inputs:   req.Inputs,
+			Output:   func(int) io.Writer { return r },
+			OnChange: r.update,
+		})
+		r.finish(record, err)
+		Logger.Info("Run ended", "run", r.info.ID, "status", r.snapshot().Status)
+		s.mu.Lock()
+		s.prune()
+		s.mu.Unlock()
+	}()
+	return r.snapshot(), nil
+}
+
+func (s *Server) run(id string) *run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.runs {
+		if r.info.ID == id {
+			return r
+		}
+	}
+	return nil
+}
+
+// prune forgets the oldest runs which ended beyond the maxEnded kept, the lock is
+// held.
+func (s *Server) prune() {
+	ended := 0
+	for _, r := range s.runs {
+		if !r.snapshot().Active() {
+			ended++
+		}
+	}
+	extra := ended - maxEnded
+	s.runs = slices.DeleteFunc(s.runs, func(r *run) bool {
+		if extra <= 0 || r.snapshot().Active() {
+			return false
+		}
+		extra--
+		return true
+	})
+}
+
+// list returns the runs, in the order they were started.
+func (s *Server) list() []RunInfo {
+	s.mu.Lock()
+	runs := append([]*run(nil), s.runs...)
+	s.mu.Unlock()
+	infos := make([]RunInfo, len(runs))
+	for i, r := range runs {
+		infos[i] = r.snapshot()
+	}
+	return infos
+}
+
+// run is a run of the supervisor and the output it wrote so far.
+type run struct {
+	cancel context.CancelFunc
+
+	mu   sync.Mutex
+	info RunInfo
+	done bool
+	// output is the output kept, dropped is the number of bytes dropped before it.
+	output  []byte
+	dropped int
+	// changed is closed, and replaced, whenever the run changes.
+	changed chan struct{}
+	// header is the index of the last step whose header was written to the output.
+	header int
+}
+
+func (r *run) snapshot() RunInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.info
+}
+
+func (r *run) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.write(p)
+	return len(p), nil
+}
+
+// write appends to the output, the lock is held.
+func (r *run) write(p []byte) {
+	r.output = append(r.output, p...)
+	if extra := len(r.output) - maxOutput; extra > 0 {
+		// The output kept starts with a whole rune
+		for extra < len(r.output) && !utf8.RuneStart(r.output[extra]) {
+			extra++
+		}
+		r.output = append([]byte(nil), r.output[extra:]...)
+		r.dropped += extra
+	}
+	r.notify()
+}
+
+// notify wakes up the clients attached, the lock is held.
+func (r *run) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// update follows the progress of the run, writing a header to the output when a
+// step starts.
+func (r *run) update(record *history.Run) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.info.Steps = len(record.Steps)
+	for _, step := range record.Steps {
+		if step.Status == history.StepPending {
+			continue
+		}
+		r.info.Step = step.Index
+		if step.Status == history.StepRunning && step.Index > r.header {
+			r.header = step.Index
+			r.write([]byte(fmt.Sprintf("── [%d/%d] %s\n", step.Index+1, len(record.Steps), step.Cmd)))
+		}
+	}
+	r.notify()
+}
+
+func (r *run) finish(record *history.Run, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.done = true
+	r.info.FinishedAt = time.Now()
+	switch {
+	case record != nil:
+		r.info.Status = record.Status
+	case err != nil:
+		r.info.Status = history.StatusFailed
+	}
+	if err != nil {
+		r.info.Error = err.Error()
+	}
+	r.notify()
+}
+
+// stream sends the output of the run from its start, then as it is written, and
+// the final state of the run once it is done. The chunks of output end with a whole
+// rune, the rest is sent once written, so that they remain valid UTF-8.
+func (r *run) stream(ctx context.Context, enc *json.Encoder) {
+	offset := 0
+	for {
+		r.mu.Lock()
+		start := max(offset-r.dropped, 0)
+		out := r.output[start:]
+		if !r.done {
+			out = out[:wholeRunes(out)]
+		}
+		chunk := string(out)
+		offset = r.dropped + start + len(out)
+		info, done, changed := r.info, r.done, r.changed
+		r.mu.Unlock()
+		if chunk != "" {
+			if err := enc.Encode(Response{Output: chunk}); err != nil {
+				return
+			}
+		}
+		if done {
+			_ = enc.Encode(Response{Run: &info, Done: true})
+			return
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// wholeRunes returns the length of p without the rune it ends in the middle of.
+func wholeRunes(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if utf8.FullRune(p[i:]) {
+				return len(p)
+			}
+			return i
+		}
+	}
+	return len(p)
+}
@@ -0,0 +1,195 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/history"
+)
+
+// serve starts a supervisor running the recipe with the given Taskfile, and
+// returns a client of it.
+func serve(t *testing.T, taskfile string) *Client {
+	t.Helper()
+	recipesDir := t.TempDir()
+	dir := filepath.Join(recipesDir, "check")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "info.yaml"), []byte("name: check\ndisplay-name: Check\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "taskfile.yaml"), []byte(taskfile), 0o644))
+	cfg := &config.Config{RecipesDir: recipesDir, EnvironmentsDir: t.TempDir(), HistoryDir: t.TempDir()}
+
+	// The path of a unix socket is limited to about a hundred characters
+	socketDir, err := os.MkdirTemp("", "sv")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(socketDir) })
+	socket := filepath.Join(socketDir, "s.sock")
+	l, err := Listen(socket)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- NewServer(cfg).Serve(ctx, l)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+
+	_, err = Listen(socket)
+	require.ErrorContains(t, err, "already listening")
+	return NewClient(socket)
+}
+
+func TestSupervisor_Run(t *testing.T) {
+	c := serve(t, `version: '3'
+tasks:
+  default:
+    cmds:
+      - echo "hello $GREETING"
+      - echo done
+`)
+	ctx := context.Background()
+
+	info, err := c.Start(ctx, "check", "", map[string]string{"GREETING": "world"})
+	require.NoError(t, err)
+	require.Equal(t, "check", info.Recipe)
+	require.Equal(t, history.StatusRunning, info.Status)
+
+	var out strings.Builder
+	final, err := c.Attach(ctx, info.ID, &out)
+	require.NoError(t, err)
+	require.Equal(t, history.StatusSucceeded, final.Status)
+	require.Equal(t, 2, final.Steps)
+	require.Contains(t, out.String(), "── [1/2]")
+	require.Contains(t, out.String(), "hello world\n")
+	require.Contains(t, out.String(), "done\n")
+
+	// Attaching again replays the output of the run
+	var replay strings.Builder
+	_, err = c.Attach(ctx, info.ID, &replay)
+	require.NoError(t, err)
+	require.Equal(t, out.String(), replay.String())
+
+	runs, err := c.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.Equal(t, info.ID, runs[0].ID)
+	require.False(t, runs[0].Active())
+
+	_, err = c.Start(ctx, "missing", "", nil)
+	require.ErrorContains(t, err, "recipe missing not found")
+	_, err = c.Start(ctx, "check", "../../x", nil)
+	require.ErrorContains(t, err, "environment ../../x not found")
+	_, err = c.Attach(ctx, "missing", &out)
+	require.ErrorContains(t, err, "unknown run missing")
+
+	_, err = NewClient(filepath.Join(t.TempDir(), "none.sock")).List(ctx)
+	require.ErrorIs(t, err, ErrNotRunning)
+}
+
+func TestSupervisor_DetachAndAbort(t *testing.T) {
+	c := serve(t, `version: '3'
+tasks:
+  default:
+    cmds:
+      - defer: echo cleanup
+      - echo started
+      - sleep 0.5
+      - echo never
+`)
+	ctx := context.Background()
+	info, err := c.Start(ctx, "check", "", nil)
+	require.NoError(t, err)
+
+	// Detaching leaves the run running
+	attachCtx, detach := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer detach()
+	_, err = c.Attach(attachCtx, info.ID, &strings.Builder{})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	runs, err := c.List(ctx)
+	require.NoError(t, err)
+	require.True(t, runs[0].Active())
+
+	_, err = c.Abort(ctx, info.ID)
+	require.NoError(t, err)
+	var out strings.Builder
+	final, err := c.Attach(ctx, info.ID, &out)
+	require.NoError(t, err)
+	require.Equal(t, history.StatusAborted, final.Status)
+	require.Contains(t, out.String(), "cleanup\n")
+	require.NotContains(t, out.String(), "never")
+}
+
+func TestRun_StreamWholeRunes(t *testing.T) {
+	r := &run{changed: make(chan struct{}), header: -1}
+	// The output of the command ends in the middle of "é"
+	_, _ = r.Write([]byte("h\xc3"))
+	time.AfterFunc(50*time.Millisecond, func() {
+		_, _ = r.Write([]byte("\xa9"))
+		r.finish(nil, nil)
+	})
+
+	var buf bytes.Buffer
+	r.stream(context.Background(), json.NewEncoder(&buf))
+	var out strings.Builder
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var resp Response
+		require.NoError(t, dec.Decode(&resp))
+		require.True(t, utf8.ValidString(resp.Output))
+		require.NotContains(t, resp.Output, string(utf8.RuneError))
+		out.WriteString(resp.Output)
+	}
+	require.Equal(t, "hé", out.String())
+}
+
+func TestServer_Prune(t *testing.T) {
+	s := &Server{}
+	for i := range maxEnded + 5 {
+		status := history.StatusSucceeded
+		if i%10 == 0 {
+			status = history.StatusRunning
+		}
+		s.runs = append(s.runs, &run{info: RunInfo{ID: strconv.Itoa(i), Status: status}})
+	}
+	s.prune()
+
+	// The oldest runs which ended are forgotten, the active ones are kept
+	var ids []string
+	ended := 0
+	for _, r := range s.runs {
+		ids = append(ids, r.info.ID)
+		if !r.info.Active() {
+			ended++
+		}
+	}
+	require.Equal(t, maxEnded, ended)
+	require.Equal(t, []string{"0", "3", "4"}, ids[:3])
+	require.Contains(t, ids, "20")
+}
@@ -25,6 +25,8 @@ type Compiler struct {
 
 	TaskfileEnv  *ast.Vars
 	TaskfileVars *ast.Vars
+	// SpecialVars are special variables set on top of the ones of Task.
+	SpecialVars map[string]string
 
 	Logger *logger.Logger
 
@@ -220,6 +222,11 @@ func (c *Compiler) getSpecialVars(t *ast.Task, call *Call) (map[string]string, e
 	} else {
 		allVars["ALIAS"] = ""
 	}
+	for k, v := range c.SpecialVars {
+		if _, ok := allVars[k]; !ok {
+			allVars[k] = v
+		}
+	}
 
 	return allVars, nil
 }
@@ -14,7 +14,10 @@
 
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 const CodeTaskCmdIndex = 600
 
@@ -30,3 +33,21 @@ func (e *TaskCmdIndexError) Error() string {
 func (e *TaskCmdIndexError) Code() int {
 	return CodeTaskCmdIndex
 }
+
+const CodeTaskTimeout = 601
+
+// TaskTimeoutError is returned when a command, or a task called by a command, runs
+// longer than its timeout.
+type TaskTimeoutError struct {
+	TaskName string
+	Cmd      string
+	Timeout  time.Duration
+}
+
+func (e *TaskTimeoutError) Error() string {
+	return fmt.Sprintf("task: [%s] %s timed out after %s", e.TaskName, e.Cmd, e.Timeout)
+}
+
+func (e *TaskTimeoutError) Code() int {
+	return CodeTaskTimeout
+}
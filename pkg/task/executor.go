@@ -31,6 +31,9 @@ type (
 		Download    bool
 		Offline     bool
 		Timeout     time.Duration
+		CmdTimeout  time.Duration
+		OutputsFile string
+		SpecialVars map[string]string
 		Watch       bool
 		Verbose     bool
 		Silent      bool
@@ -65,6 +68,8 @@ type (
 		mkdirMutexMap        map[string]*sync.Mutex
 		executionHashes      map[string]context.Context
 		executionHashesMutex sync.Mutex
+		outputs              map[string]string
+		outputsMutex         sync.Mutex
 	}
 	TempDir struct {
 		Remote      string
@@ -183,6 +188,34 @@ func ExecutorWithTimeout(timeout time.Duration) ExecutorOption {
 	}
 }
 
+// ExecutorWithCmdTimeout sets the default timeout of the commands run by the
+// [Executor], used when the command doesn't set one. By default, the commands
+// have no timeout but the one of their task, and the deferred ones
+// [DefaultDeferTimeout].
+func ExecutorWithCmdTimeout(timeout time.Duration) ExecutorOption {
+	return func(e *Executor) {
+		e.CmdTimeout = timeout
+	}
+}
+
+// ExecutorWithOutputsFile sets the file the commands run by the [Executor] write
+// their outputs to, as KEY=value lines. Its path is exposed to the commands as
+// HYPERDEV_OUTPUT and the outputs to the following commands as environment
+// variables. By default, the commands have no outputs file.
+func ExecutorWithOutputsFile(path string) ExecutorOption {
+	return func(e *Executor) {
+		e.OutputsFile = path
+	}
+}
+
+// ExecutorWithSpecialVars sets special variables, on top of the ones of Task, which
+// are available to the templates and to the commands as environment variables.
+func ExecutorWithSpecialVars(vars map[string]string) ExecutorOption {
+	return func(e *Executor) {
+		e.SpecialVars = vars
+	}
+}
+
 // ExecutorWithWatch tells the [Executor] to keep running in the background and
 // watch for changes to the fingerprint of the tasks that are run. When changes
 // are detected, a new task run is triggered.
@@ -0,0 +1,78 @@
+package execext
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/interp"
+)
+
+// killTimeout is how long the process group of a command is given to exit once
+// interrupted before it is killed.
+const killTimeout = 2 * time.Second
+
+// execHandler runs the programs like interp.DefaultExecHandler does. When the
+// context can end, on a timeout or when the run is aborted, the program runs in its
+// own process group, which is interrupted then killed as a whole once the context
+// is done, so that the processes it spawned don't outlive it.
+func execHandler(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
+	return func(ctx context.Context, args []string) error {
+		hc := interp.HandlerCtx(ctx)
+		path, err := interp.LookPathDir(hc.Dir, hc.Env, args[0])
+		if err != nil {
+			fmt.Fprintln(hc.Stderr, err)
+			return interp.NewExitStatus(127)
+		}
+		cmd := &exec.Cmd{
+			Path:   path,
+			Args:   args,
+			Dir:    hc.Dir,
+			Stdin:  hc.Stdin,
+			Stdout: hc.Stdout,
+			Stderr: hc.Stderr,
+		}
+		for name, vr := range hc.Env.Each {
+			if vr.Exported && vr.IsSet() && vr.Kind == expand.String {
+				cmd.Env = append(cmd.Env, name+"="+vr.String())
+			}
+		}
+		group := ctx.Done() != nil
+		if group {
+			setProcessGroup(cmd)
+		}
+
+		err = cmd.Start()
+		if err == nil {
+			if group {
+				stop := context.AfterFunc(ctx, func() {
+					if interrupt(cmd) != nil {
+						return
+					}
+					// The processes left in the group may hold the output open
+					// even if the command exits
+					time.Sleep(killTimeout)
+					_ = kill(cmd)
+				})
+				defer stop()
+			}
+			err = cmd.Wait()
+		}
+
+		switch err := err.(type) {
+		case *exec.ExitError:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return interp.NewExitStatus(uint8(exitCode(err)))
+		case *exec.Error:
+			// did not start
+			fmt.Fprintf(hc.Stderr, "%v\n", err)
+			return interp.NewExitStatus(127)
+		default:
+			return err
+		}
+	}
+}
@@ -0,0 +1,25 @@
+//go:build windows
+
+package execext
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// Process groups aren't supported on Windows, the command is killed on its own.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func interrupt(cmd *exec.Cmd) error {
+	// Windows can't interrupt a process, kill it right away
+	_ = cmd.Process.Kill()
+	return errors.ErrUnsupported
+}
+
+func kill(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+func exitCode(err *exec.ExitError) int {
+	return err.ExitCode()
+}
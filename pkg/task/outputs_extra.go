@@ -0,0 +1,134 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package task
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/hypershift-community/hyper-console/pkg/task/internal/env"
+	"github.com/hypershift-community/hyper-console/pkg/task/internal/logger"
+	"github.com/hypershift-community/hyper-console/pkg/task/taskfile/ast"
+)
+
+// OutputsEnvVar is the environment variable holding the path of the outputs file,
+// which the commands append KEY=value lines to, e.g.
+//
+//	echo "INFRA_ID=$(jq -r .infraID < infra.json)" >> "$HYPERDEV_OUTPUT"
+//
+// The outputs reach the following commands as environment variables only, as
+// "$INFRA_ID". They aren't template variables: the commands are templated when
+// the plan is compiled, before any of them ran, so {{.INFRA_ID}} renders empty.
+const OutputsEnvVar = "HYPERDEV_OUTPUT"
+
+var outputKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Outputs returns the outputs collected so far, the ones set with SetOutputs included.
+func (e *Executor) Outputs() map[string]string {
+	e.outputsMutex.Lock()
+	defer e.outputsMutex.Unlock()
+	return maps.Clone(e.outputs)
+}
+
+// SetOutputs sets outputs as if the commands had written them, e.g. the ones of the
+// run being resumed, so that they are exposed to the commands from the start.
+func (e *Executor) SetOutputs(outputs map[string]string) {
+	e.outputsMutex.Lock()
+	defer e.outputsMutex.Unlock()
+	if e.outputs == nil {
+		e.outputs = make(map[string]string, len(outputs))
+	}
+	maps.Copy(e.outputs, outputs)
+}
+
+// cmdEnv returns the environment of the commands of the task: the one of the task,
+// the special variables, the path of the outputs file and the outputs collected so
+// far, which take precedence.
+func (e *Executor) cmdEnv(t *ast.Task) []string {
+	environ := env.Get(t)
+	if e.OutputsFile == "" && len(e.SpecialVars) == 0 {
+		return environ
+	}
+	if environ == nil {
+		environ = os.Environ()
+	}
+	for k, v := range e.SpecialVars {
+		environ = append(environ, fmt.Sprintf("%s=%s", k, v))
+	}
+	if e.OutputsFile == "" {
+		return environ
+	}
+	environ = append(environ, fmt.Sprintf("%s=%s", OutputsEnvVar, e.OutputsFile))
+	e.outputsMutex.Lock()
+	defer e.outputsMutex.Unlock()
+	for k, v := range e.outputs {
+		environ = append(environ, fmt.Sprintf("%s=%s", k, v))
+	}
+	return environ
+}
+
+// collectOutputs reads the outputs written to the outputs file since the last call
+// and empties it. Later values of a key replace the earlier ones.
+func (e *Executor) collectOutputs() {
+	if e.OutputsFile == "" {
+		return
+	}
+	e.outputsMutex.Lock()
+	defer e.outputsMutex.Unlock()
+	data, err := os.ReadFile(e.OutputsFile)
+	if err != nil || len(data) == 0 {
+		return
+	}
+	if err := os.Truncate(e.OutputsFile, 0); err != nil {
+		e.Logger.Errf(logger.Red, "task: unable to empty the outputs file: %v\n", err)
+	}
+	outputs, err := ParseOutputs(string(data))
+	if err != nil {
+		e.Logger.Errf(logger.Yellow, "task: %v\n", err)
+	}
+	if e.outputs == nil {
+		e.outputs = make(map[string]string, len(outputs))
+	}
+	maps.Copy(e.outputs, outputs)
+}
+
+// ParseOutputs parses KEY=value lines. Empty lines and comments are skipped. The
+// values are taken as is, up to the end of the line. The valid lines are returned
+// even when some are not.
+func ParseOutputs(s string) (map[string]string, error) {
+	outputs := make(map[string]string)
+	var invalid []string
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if key = strings.TrimSpace(key); !ok || !outputKey.MatchString(key) {
+			invalid = append(invalid, line)
+			continue
+		}
+		outputs[key] = value
+	}
+	if len(invalid) > 0 {
+		return outputs, fmt.Errorf("ignored invalid output lines, expected KEY=value: %q", invalid)
+	}
+	return outputs, nil
+}
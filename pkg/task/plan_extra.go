@@ -0,0 +1,702 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package task
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"mvdan.cc/sh/v3/interp"
+
+	"github.com/hypershift-community/hyper-console/pkg/task/errors"
+	"github.com/hypershift-community/hyper-console/pkg/task/internal/execext"
+	"github.com/hypershift-community/hyper-console/pkg/task/internal/fingerprint"
+	"github.com/hypershift-community/hyper-console/pkg/task/internal/logger"
+	"github.com/hypershift-community/hyper-console/pkg/task/internal/output"
+	"github.com/hypershift-community/hyper-console/pkg/task/internal/slicesext"
+	"github.com/hypershift-community/hyper-console/pkg/task/internal/templater"
+	"github.com/hypershift-community/hyper-console/pkg/task/taskfile/ast"
+)
+
+// StepKind is the kind of a step of an execution [Plan].
+type StepKind int
+
+const (
+	// StepDeps runs all the dependencies of the task, in parallel.
+	StepDeps StepKind = iota
+	// StepPreconditions checks the preconditions of the task.
+	StepPreconditions
+	// StepStatus checks whether the task is up-to-date. When it is, the remaining
+	// steps of the plan are skipped.
+	StepStatus
+	// StepPrompt asks the user to confirm running the task.
+	StepPrompt
+	// StepCmd runs a shell command.
+	StepCmd
+	// StepTask calls another task.
+	StepTask
+	// StepDefer runs a deferred command or task call.
+	StepDefer
+	// StepWait runs a check command at an interval until its condition is met.
+	StepWait
+)
+
+func (k StepKind) String() string {
+	return [...]string{"deps", "preconditions", "status", "prompt", "cmd", "task", "defer", "wait"}[k]
+}
+
+// PlanStep is a single step of an execution [Plan].
+type PlanStep struct {
+	Kind StepKind
+	// CmdIndex is the index of the command in the task for StepCmd, StepTask,
+	// StepDefer and StepWait steps and -1 for the other kinds.
+	CmdIndex int
+	Cmd      *ast.Cmd
+	Deps     []*ast.Dep
+	// Prompt is the message shown to the user for StepPrompt steps.
+	Prompt string
+	// Sub is the plan of the task called by a StepTask step and Subs the plans of
+	// the dependencies run by a StepDeps step. They are nil when the call couldn't
+	// be compiled ahead of time, in which case the call runs as a single step.
+	Sub  *Plan
+	Subs []*Plan
+}
+
+// PlanObserver is notified when the steps of a plan, and of the plans nested in it,
+// start and finish. The writers returned by StepStarted receive the output of the
+// step; when nil, the output goes to the executor's Stdout and Stderr. Steps of
+// dependencies run in parallel so the observer must be safe for concurrent use.
+type PlanObserver interface {
+	StepStarted(p *Plan, s *PlanStep) (stdout, stderr io.Writer)
+	StepFinished(p *Plan, s *PlanStep, err error)
+}
+
+// maxPlanDepth limits how deep nested task calls are expanded when compiling a plan.
+// Deeper calls, e.g. recursive ones, still run but as a single step.
+const maxPlanDepth = 16
+
+// String returns a human-readable description of the step.
+func (s *PlanStep) String() string {
+	switch s.Kind {
+	case StepDeps:
+		names := make([]string, len(s.Deps))
+		for i, d := range s.Deps {
+			names[i] = d.Task
+		}
+		return "deps: " + strings.Join(names, ", ")
+	case StepPreconditions:
+		return "check preconditions"
+	case StepStatus:
+		return "check status"
+	case StepPrompt:
+		return "prompt: " + s.Prompt
+	case StepTask:
+		return "task: " + s.Cmd.Task
+	case StepDefer:
+		if s.Cmd.Task != "" {
+			return "defer: task: " + s.Cmd.Task
+		}
+		return "defer: " + s.Cmd.Cmd
+	case StepWait:
+		return "wait: " + s.Cmd.Cmd
+	default:
+		return s.Cmd.Cmd
+	}
+}
+
+// Plan is the compiled execution plan of a task call. Where [Executor.RunTask] runs
+// a task in one go, a plan breaks it down in ordered, typed steps that can be run one
+// at a time while keeping the semantics of Task: the dependencies run once, the
+// preconditions and fingerprint are checked once, the call counts once against
+// MaximumTaskCall and the deferred commands come last, in LIFO order.
+//
+// The plan doesn't decide which steps run. Callers are expected to run the steps in
+// order, and to jump to the deferred steps when a step fails, running only the ones
+// that were declared before the failed command (see [Plan.Registered]).
+type Plan struct {
+	Call  *Call
+	Task  *ast.Task
+	Steps []*PlanStep
+
+	e         *Executor
+	observer  PlanObserver
+	begin     sync.Once
+	beginErr  error
+	mkdir     sync.Once
+	upToDate  atomic.Bool
+	exitCode  atomic.Uint32
+	skipCheck bool
+	// spent is the time the steps of the task ran for, counted against its timeout.
+	spent atomic.Int64
+}
+
+// CompilePlan compiles the given call into an execution plan. The calls to other
+// tasks, as commands or dependencies, are compiled into nested plans.
+func (e *Executor) CompilePlan(call *Call) (*Plan, error) {
+	return e.compilePlan(call, 0)
+}
+
+func (e *Executor) compilePlan(call *Call, depth int) (*Plan, error) {
+	t, err := e.PrepareTask(call)
+	if err != nil {
+		return nil, err
+	}
+	p := &Plan{Call: call, Task: t, e: e}
+	if t == nil {
+		// Not for the current platform, nothing to run
+		p.Task = &ast.Task{Task: call.Task}
+		return p, nil
+	}
+
+	p.skipCheck = e.ForceAll || (!call.Indirect && e.Force)
+
+	if len(t.Deps) > 0 {
+		s := &PlanStep{Kind: StepDeps, CmdIndex: -1, Deps: t.Deps}
+		if depth < maxPlanDepth {
+			s.Subs = make([]*Plan, len(t.Deps))
+			for i, d := range t.Deps {
+				s.Subs[i] = e.compileSubPlan(&Call{Task: d.Task, Vars: d.Vars, Silent: d.Silent, Indirect: true}, depth)
+				if s.Subs[i] == nil {
+					s.Subs = nil
+					break
+				}
+			}
+		}
+		p.Steps = append(p.Steps, s)
+	}
+	if !p.skipCheck {
+		if len(t.Preconditions) > 0 {
+			p.Steps = append(p.Steps, &PlanStep{Kind: StepPreconditions, CmdIndex: -1})
+		}
+		if len(t.Status) > 0 || len(t.Sources) > 0 {
+			p.Steps = append(p.Steps, &PlanStep{Kind: StepStatus, CmdIndex: -1})
+		}
+	}
+	if !e.Dry {
+		for _, prompt := range t.Prompt {
+			if prompt != "" {
+				p.Steps = append(p.Steps, &PlanStep{Kind: StepPrompt, CmdIndex: -1, Prompt: prompt})
+			}
+		}
+	}
+
+	var deferred []*PlanStep
+	for i, cmd := range t.Cmds {
+		switch {
+		case cmd.Defer:
+			deferred = append(deferred, &PlanStep{Kind: StepDefer, CmdIndex: i, Cmd: cmd})
+		case cmd.Task != "":
+			s := &PlanStep{Kind: StepTask, CmdIndex: i, Cmd: cmd}
+			if depth < maxPlanDepth {
+				s.Sub = e.compileSubPlan(&Call{Task: cmd.Task, Vars: cmd.Vars, Silent: cmd.Silent, Indirect: true}, depth)
+			}
+			p.Steps = append(p.Steps, s)
+		case cmd.Wait != nil:
+			p.Steps = append(p.Steps, &PlanStep{Kind: StepWait, CmdIndex: i, Cmd: cmd})
+		default:
+			p.Steps = append(p.Steps, &PlanStep{Kind: StepCmd, CmdIndex: i, Cmd: cmd})
+		}
+	}
+	for i := len(deferred) - 1; i >= 0; i-- {
+		p.Steps = append(p.Steps, deferred[i])
+	}
+	return p, nil
+}
+
+// compileSubPlan compiles the plan of a nested call. Errors are not fatal: they are
+// reported again, with the right context, when the call runs as a single step.
+func (e *Executor) compileSubPlan(call *Call, depth int) *Plan {
+	sub, err := e.compilePlan(call, depth+1)
+	if err != nil {
+		e.Logger.VerboseErrf(logger.Yellow, "task: unable to compile plan of %q: %v\n", call.Task, err)
+		return nil
+	}
+	return sub
+}
+
+// SetObserver sets the observer of the plan and of all the plans nested in it.
+func (p *Plan) SetObserver(o PlanObserver) {
+	p.observer = o
+	for _, s := range p.Steps {
+		if s.Sub != nil {
+			s.Sub.SetObserver(o)
+		}
+		for _, sub := range s.Subs {
+			sub.SetObserver(o)
+		}
+	}
+}
+
+// Run runs all the steps of the plan. When a step fails, the remaining steps are
+// skipped and the deferred steps registered so far run before returning the error.
+func (p *Plan) Run(ctx context.Context) error {
+	return p.e.startExecution(ctx, p.Task, func(ctx context.Context) error {
+		reached := -1
+		var runErr error
+		for _, s := range p.Steps {
+			if s.Kind == StepDefer {
+				continue
+			}
+			if s.CmdIndex >= 0 {
+				reached = s.CmdIndex
+			}
+			if runErr = p.RunStep(ctx, s); runErr != nil {
+				p.Fail(runErr)
+				break
+			}
+		}
+		if runErr == nil {
+			reached = len(p.Task.Cmds)
+		}
+		for _, s := range p.Steps {
+			if p.Registered(s, reached) {
+				if err := p.RunStep(ctx, s); err != nil {
+					p.e.Logger.VerboseErrf(logger.Yellow, "task: ignored error in deferred cmd: %s\n", err.Error())
+				}
+			}
+		}
+		if runErr == nil {
+			p.e.Logger.VerboseErrf(logger.Magenta, "task: %q finished\n", p.Call.Task)
+		}
+		return runErr
+	})
+}
+
+// UpToDate returns true once the status step found the task to be up-to-date.
+func (p *Plan) UpToDate() bool {
+	return p.upToDate.Load()
+}
+
+// Skip returns true if the step must not run because the task is up-to-date.
+func (p *Plan) Skip(s *PlanStep) bool {
+	return p.UpToDate() && s.Kind >= StepPrompt
+}
+
+// Registered returns true if the given deferred step has to run at the end of the
+// task, given the index of the last command that was reached. Like in Go, a deferred
+// command only runs if the execution went past its declaration.
+func (p *Plan) Registered(s *PlanStep, reached int) bool {
+	return s.Kind == StepDefer && s.CmdIndex < reached
+}
+
+// Fail records the error that failed the task so that the deferred steps can expose
+// its exit code as EXIT_CODE.
+func (p *Plan) Fail(err error) {
+	if err == nil {
+		return
+	}
+	p.exitCode.Store(uint32(ExitCode(err)))
+}
+
+// ExitCode returns the exit code of the command that caused the given error: 0 if
+// there is no error, errors.CodeTaskTimeout if the command timed out and 1 if the
+// error isn't an exit status.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var runErr *errors.TaskRunError
+	if errors.As(err, &runErr) {
+		return runErr.TaskExitCode()
+	}
+	var timeoutErr *errors.TaskTimeoutError
+	if errors.As(err, &timeoutErr) {
+		return timeoutErr.Code()
+	}
+	if code, ok := interp.IsExitStatus(err); ok {
+		return int(code)
+	}
+	return 1
+}
+
+// RunStep runs a single step of the plan, then collects the outputs written by its
+// commands. The timeout of the task covers all its steps but the deferred ones,
+// the dependencies and the nested calls included; the time spent between the
+// steps, e.g. paused before one, doesn't count.
+func (p *Plan) RunStep(ctx context.Context, s *PlanStep) (err error) {
+	if s.Kind != StepDefer && p.Task.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = p.withTaskTimeout(ctx, s)
+		defer cancel()
+		started := time.Now()
+		defer func() {
+			p.spent.Add(int64(time.Since(started)))
+			err = timedOut(ctx, err)
+		}()
+	}
+	stdout, stderr := p.e.Stdout, p.e.Stderr
+	if p.observer != nil {
+		if o, e := p.observer.StepStarted(p, s); o != nil && e != nil {
+			stdout, stderr = o, e
+		}
+		defer func() {
+			p.observer.StepFinished(p, s, err)
+		}()
+	}
+	err = p.runStep(ctx, s, stdout, stderr)
+	p.e.collectOutputs()
+	return err
+}
+
+func (p *Plan) runStep(ctx context.Context, s *PlanStep, stdout, stderr io.Writer) error {
+	e, t, call := p.e, p.Task, p.Call
+
+	if p.Skip(s) {
+		return nil
+	}
+	if s.Kind == StepDefer {
+		return e.RunDeferredTaskCmd(ctx, call, t, s.CmdIndex, uint8(p.exitCode.Load()))
+	}
+
+	p.begin.Do(func() {
+		if !e.Watch && atomic.AddInt32(e.taskCallCount[t.Task], 1) >= MaximumTaskCall {
+			p.beginErr = &errors.TaskCalledTooManyTimesError{
+				TaskName:        t.Task,
+				MaximumTaskCall: MaximumTaskCall,
+			}
+			return
+		}
+		e.Logger.VerboseErrf(logger.Magenta, "task: %q started\n", call.Task)
+	})
+	if p.beginErr != nil {
+		return p.beginErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	release := e.acquireConcurrencyLimit()
+	defer release()
+
+	switch s.Kind {
+	case StepDeps:
+		if s.Subs == nil {
+			return e.runDeps(ctx, t)
+		}
+		return p.runSubPlans(ctx, s.Subs)
+	case StepPreconditions:
+		_, err := e.areTaskPreconditionsMet(ctx, t)
+		return err
+	case StepStatus:
+		return p.checkStatus(ctx)
+	case StepPrompt:
+		if err := e.Logger.Prompt(logger.Yellow, s.Prompt, "n", "y", "yes"); errors.Is(err, logger.ErrNoTerminal) {
+			return &errors.TaskCancelledNoTerminalError{TaskName: call.Task}
+		} else if errors.Is(err, logger.ErrPromptCancelled) {
+			return &errors.TaskCancelledByUserError{TaskName: call.Task}
+		} else if err != nil {
+			return err
+		}
+		return nil
+	case StepCmd, StepTask, StepWait:
+		return p.runCmd(ctx, s, stdout, stderr)
+	}
+	return fmt.Errorf("task: unknown step kind %d", s.Kind)
+}
+
+func (p *Plan) checkStatus(ctx context.Context) error {
+	e, t := p.e, p.Task
+
+	// Get the fingerprinting method to use
+	method := e.Taskfile.Method
+	if t.Method != "" {
+		method = t.Method
+	}
+
+	upToDate, err := fingerprint.IsTaskUpToDate(ctx, t,
+		fingerprint.WithMethod(method),
+		fingerprint.WithTempDir(e.TempDir.Fingerprint),
+		fingerprint.WithDry(e.Dry),
+		fingerprint.WithLogger(e.Logger),
+	)
+	if err != nil {
+		return err
+	}
+	if upToDate {
+		if e.Verbose || (!p.Call.Silent && !t.Silent && !e.Taskfile.Silent && !e.Silent) {
+			e.Logger.Errf(logger.Magenta, "task: Task %q is up to date\n", t.Name())
+		}
+		p.upToDate.Store(true)
+	}
+	return nil
+}
+
+// runSubPlans runs the plans of the dependencies of a task in parallel.
+func (p *Plan) runSubPlans(ctx context.Context, subs []*Plan) error {
+	g, ctx := errgroup.WithContext(ctx)
+
+	reacquire := p.e.releaseConcurrencyLimit()
+	defer reacquire()
+
+	for _, sub := range subs {
+		sub := sub
+		g.Go(func() error {
+			return sub.Run(ctx)
+		})
+	}
+	return g.Wait()
+}
+
+func (p *Plan) runCmd(ctx context.Context, s *PlanStep, stdout, stderr io.Writer) error {
+	e, t, call := p.e, p.Task, p.Call
+
+	p.mkdir.Do(func() {
+		if err := e.mkdir(t); err != nil {
+			e.Logger.Errf(logger.Red, "task: cannot make directory %q: %v\n", t.Dir, err)
+		}
+	})
+
+	var err error
+	switch {
+	case s.Kind == StepTask && s.Sub != nil:
+		ctx, cancel := e.withCmdTimeout(ctx, t, s.CmdIndex)
+		defer cancel()
+		reacquire := e.releaseConcurrencyLimit()
+		err = timedOut(ctx, s.Sub.Run(ctx))
+		reacquire()
+	case s.Kind == StepCmd:
+		err = e.runShellCmd(ctx, t, call, s.CmdIndex, stdout, stderr, func(a Attempt) {
+			if o, ok := p.observer.(RetryObserver); ok {
+				o.StepRetrying(p, s, a)
+			}
+		})
+	case s.Kind == StepWait:
+		err = e.runWait(ctx, t, call, s.CmdIndex, stdout, func(poll Poll) {
+			if o, ok := p.observer.(WaitObserver); ok {
+				o.StepPolled(p, s, poll)
+			}
+		})
+	default:
+		err = e.runCommand(ctx, t, call, s.CmdIndex)
+	}
+	if err != nil {
+		if err2 := e.statusOnError(t); err2 != nil {
+			e.Logger.VerboseErrf(logger.Yellow, "task: error cleaning status on error: %v\n", err2)
+		}
+
+		if _, isExitError := interp.IsExitStatus(err); isExitError && t.IgnoreError {
+			e.Logger.VerboseErrf(logger.Yellow, "task: task error ignored: %v\n", err)
+			return nil
+		}
+
+		var timeoutErr *errors.TaskTimeoutError
+		if call.Indirect || errors.As(err, &timeoutErr) {
+			// Timeouts keep their own exit code
+			return err
+		}
+
+		return &errors.TaskRunError{TaskName: t.Task, Err: err}
+	}
+	return nil
+}
+
+// withTaskTimeout returns a context which times out once the steps of the task ran
+// for longer than its timeout. The cause of the timeout is a TaskTimeoutError
+// naming the given step.
+func (p *Plan) withTaskTimeout(ctx context.Context, s *PlanStep) (context.Context, context.CancelFunc) {
+	label := s.String()
+	if s.Cmd != nil {
+		label = cmdLabel(s.Cmd)
+	}
+	return context.WithTimeoutCause(ctx, p.Task.Timeout-time.Duration(p.spent.Load()), &errors.TaskTimeoutError{
+		TaskName: p.Task.Name(),
+		Cmd:      label,
+		Timeout:  p.Task.Timeout,
+	})
+}
+
+// withCmdTimeout returns a context which times out after the timeout of the command
+// at index i of the task: the one of the command, else the default of the executor.
+// The cause of the timeout is a TaskTimeoutError.
+func (e *Executor) withCmdTimeout(ctx context.Context, t *ast.Task, i int) (context.Context, context.CancelFunc) {
+	cmd := t.Cmds[i]
+	timeout := cmd.Timeout
+	if timeout == 0 {
+		timeout = e.CmdTimeout
+	}
+	return withTimeout(ctx, t, cmd, timeout)
+}
+
+// withDeferTimeout returns a context which times out after the timeout of the
+// deferred command at index i of the task: the one of the command, else the
+// default of the executor, else the one of the task, else DefaultDeferTimeout.
+// Deferred commands always have one, a cleanup that hangs mustn't block forever.
+func (e *Executor) withDeferTimeout(ctx context.Context, t *ast.Task, i int) (context.Context, context.CancelFunc) {
+	cmd := t.Cmds[i]
+	timeout := cmd.Timeout
+	if timeout == 0 {
+		timeout = e.CmdTimeout
+	}
+	if timeout == 0 {
+		timeout = t.Timeout
+	}
+	if timeout <= 0 {
+		timeout = DefaultDeferTimeout
+	}
+	return withTimeout(ctx, t, cmd, timeout)
+}
+
+// withWaitTimeout returns a context which times out after the timeout of the wait
+// step at index i of the task: the one of the step, else the default of the
+// executor, else DefaultWaitTimeout. The timeout of the task still applies when it
+// ends earlier.
+func (e *Executor) withWaitTimeout(ctx context.Context, t *ast.Task, i int) (context.Context, context.CancelFunc) {
+	cmd := t.Cmds[i]
+	timeout := cmd.Timeout
+	if timeout == 0 {
+		timeout = e.CmdTimeout
+	}
+	if timeout <= 0 {
+		timeout = DefaultWaitTimeout
+	}
+	return withTimeout(ctx, t, cmd, timeout)
+}
+
+func withTimeout(ctx context.Context, t *ast.Task, cmd *ast.Cmd, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, timeout, &errors.TaskTimeoutError{
+		TaskName: t.Name(),
+		Cmd:      cmdLabel(cmd),
+		Timeout:  timeout,
+	})
+}
+
+// cmdLabel returns the label of the command in the timeout errors.
+func cmdLabel(cmd *ast.Cmd) string {
+	if cmd.Task != "" {
+		return "task " + cmd.Task
+	}
+	return cmd.Cmd
+}
+
+// timedOut replaces the error of a command which ran out of time with the
+// TaskTimeoutError its context was cancelled with.
+func timedOut(ctx context.Context, err error) error {
+	var timeoutErr *errors.TaskTimeoutError
+	if err != nil && ctx.Err() != nil && errors.As(context.Cause(ctx), &timeoutErr) {
+		return timeoutErr
+	}
+	return err
+}
+
+// runShellCmd runs the shell command at index i of the task like runCommand does,
+// but writes its output to the given writers instead of the executor's ones. The
+// command is retried according to its retry policy; retrying is called before each
+// new attempt.
+func (e *Executor) runShellCmd(ctx context.Context, t *ast.Task, call *Call, i int, stdout, stderr io.Writer, retrying func(Attempt)) error {
+	cmd := t.Cmds[i]
+
+	if !shouldRunOnCurrentPlatform(cmd.Platforms) {
+		e.Logger.VerboseOutf(logger.Yellow, "task: [%s] %s not for current platform - ignored\n", t.Name(), cmd.Cmd)
+		return nil
+	}
+
+	if e.Verbose || (!call.Silent && !cmd.Silent && !t.Silent && !e.Taskfile.Silent && !e.Silent) {
+		e.Logger.Errf(logger.Green, "task: [%s] %s\n", t.Name(), cmd.Cmd)
+	}
+
+	if e.Dry {
+		return nil
+	}
+
+	outputWrapper := e.Output
+	if t.Interactive {
+		outputWrapper = output.Interleaved{}
+	}
+	vars, err := e.Compiler.FastGetVariables(t, call)
+	outputTemplater := &templater.Cache{Vars: vars}
+	if err != nil {
+		return fmt.Errorf("task: failed to get variables: %w", err)
+	}
+	stdOut, stdErr, closer := outputWrapper.WrapWriter(stdout, stderr, t.Prefix, outputTemplater)
+
+	var output *tailBuffer
+	if cmd.Retry != nil && cmd.Retry.Output != "" {
+		output = &tailBuffer{size: retryOutputSize}
+		stdOut, stdErr = io.MultiWriter(stdOut, output), io.MultiWriter(stdErr, output)
+	}
+	for attempt := 1; ; attempt++ {
+		startedAt := time.Now()
+		// Only the first attempt reads the input: the shell keeps copying it in the
+		// background after the command exited, so sharing it with the next attempt
+		// would race, and what was read is gone anyway
+		stdin := e.Stdin
+		if attempt > 1 {
+			stdin = nil
+		}
+		err = e.runShellCmdAttempt(ctx, t, i, stdin, stdOut, stdErr)
+		if err == nil || cmd.Retry == nil || attempt >= cmd.Retry.Attempts {
+			break
+		}
+		var out string
+		if output != nil {
+			out = output.String()
+			output.Reset()
+		}
+		if !retryable(cmd.Retry, err, out) {
+			break
+		}
+		a := Attempt{
+			Number:     attempt,
+			Attempts:   cmd.Retry.Attempts,
+			StartedAt:  startedAt,
+			FinishedAt: time.Now(),
+			ExitCode:   ExitCode(err),
+			Err:        err,
+			Delay:      retryDelay(cmd.Retry, attempt),
+		}
+		e.Logger.VerboseErrf(logger.Yellow, "task: [%s] attempt %d/%d failed, retrying in %s: %v\n", t.Name(), attempt, a.Attempts, a.Delay, err)
+		if retrying != nil {
+			retrying(a)
+		}
+		if sleep(ctx, a.Delay) != nil {
+			break
+		}
+	}
+	if closeErr := closer(err); closeErr != nil {
+		e.Logger.Errf(logger.Red, "task: unable to close writer: %v\n", closeErr)
+	}
+	if _, isExitError := interp.IsExitStatus(err); isExitError && cmd.IgnoreError {
+		e.Logger.VerboseErrf(logger.Yellow, "task: [%s] command error ignored: %v\n", t.Name(), err)
+		return nil
+	}
+	return err
+}
+
+// runShellCmdAttempt runs the shell command at index i of the task once.
+func (e *Executor) runShellCmdAttempt(ctx context.Context, t *ast.Task, i int, stdin io.Reader, stdout, stderr io.Writer) error {
+	cmd := t.Cmds[i]
+	ctx, cancel := e.withCmdTimeout(ctx, t, i)
+	defer cancel()
+	err := execext.RunCommand(ctx, &execext.RunCommandOptions{
+		Command:   cmd.Cmd,
+		Dir:       t.Dir,
+		Env:       e.cmdEnv(t),
+		PosixOpts: slicesext.UniqueJoin(e.Taskfile.Set, t.Set, cmd.Set),
+		BashOpts:  slicesext.UniqueJoin(e.Taskfile.Shopt, t.Shopt, cmd.Shopt),
+		Stdin:     stdin,
+		Stdout:    stdout,
+		Stderr:    stderr,
+	})
+	return timedOut(ctx, err)
+}
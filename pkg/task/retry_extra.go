@@ -0,0 +1,127 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package task
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hypershift-community/hyper-console/pkg/task/taskfile/ast"
+)
+
+// Attempt describes a failed attempt of a command which is about to be retried.
+type Attempt struct {
+	// Number is the number of the attempt, starting at 1.
+	Number int
+	// Attempts is the maximum number of attempts of the command.
+	Attempts   int
+	StartedAt  time.Time
+	FinishedAt time.Time
+	ExitCode   int
+	Err        error
+	// Delay is how long to wait before the next attempt.
+	Delay time.Duration
+}
+
+// A RetryObserver is notified of the failed attempts of the commands which are
+// retried. The [PlanObserver] of a plan may implement it.
+type RetryObserver interface {
+	StepRetrying(p *Plan, s *PlanStep, a Attempt)
+}
+
+// retryOutputSize is how much of the output of an attempt is kept to match the
+// output filter of the retry policy.
+const retryOutputSize = 1 << 20
+
+// retryable returns true if the failure of an attempt with the given error and
+// output can be retried according to the policy.
+func retryable(r *ast.Retry, err error, output string) bool {
+	if len(r.ExitCodes) == 0 && r.Output == "" {
+		return true
+	}
+	if slices.Contains(r.ExitCodes, ExitCode(err)) {
+		return true
+	}
+	if r.Output != "" {
+		// The pattern is validated when the Taskfile is read
+		re := regexp.MustCompile(r.Output)
+		return re.MatchString(output)
+	}
+	return false
+}
+
+// retryDelay returns how long to wait after the given failed attempt.
+func retryDelay(r *ast.Retry, attempt int) time.Duration {
+	if r.Backoff != ast.BackoffExponential {
+		return r.Delay
+	}
+	limit := r.MaxDelay
+	if limit <= 0 {
+		limit = ast.DefaultMaxRetryDelay
+	}
+	// The delay is doubled after each attempt, as long as it doesn't overflow
+	d := limit
+	if shift := max(attempt-1, 0); shift < 63 && r.Delay <= math.MaxInt64>>shift {
+		d = min(r.Delay<<shift, limit)
+	}
+	// Equal jitter: half of the delay is random so that retries spread out
+	return d/2 + rand.N(d/2+1)
+}
+
+// sleep waits for the given duration unless the context is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// tailBuffer keeps the last bytes written to it.
+type tailBuffer struct {
+	mu   sync.Mutex
+	size int
+	buf  []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if extra := len(b.buf) - b.size; extra > 0 {
+		b.buf = append(b.buf[:0], b.buf[extra:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
+func (b *tailBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = b.buf[:0]
+}
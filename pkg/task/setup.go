@@ -203,6 +203,7 @@ func (e *Executor) setupCompiler() error {
 		UserWorkingDir: e.UserWorkingDir,
 		TaskfileEnv:    e.Taskfile.Env,
 		TaskfileVars:   e.Taskfile.Vars,
+		SpecialVars:    e.SpecialVars,
 		Logger:         e.Logger,
 	}
 	return nil
@@ -9,7 +9,6 @@ import (
 	"sync/atomic"
 
 	"github.com/hypershift-community/hyper-console/pkg/task/errors"
-	"github.com/hypershift-community/hyper-console/pkg/task/internal/env"
 	"github.com/hypershift-community/hyper-console/pkg/task/internal/execext"
 	"github.com/hypershift-community/hyper-console/pkg/task/internal/fingerprint"
 	"github.com/hypershift-community/hyper-console/pkg/task/internal/logger"
@@ -344,7 +343,7 @@ func (e *Executor) runCommand(ctx context.Context, t *ast.Task, call *Call, i in
 		err = execext.RunCommand(ctx, &execext.RunCommandOptions{
 			Command:   cmd.Cmd,
 			Dir:       t.Dir,
-			Env:       env.Get(t),
+			Env:       e.cmdEnv(t),
 			PosixOpts: slicesext.UniqueJoin(e.Taskfile.Set, t.Set, cmd.Set),
 			BashOpts:  slicesext.UniqueJoin(e.Taskfile.Shopt, t.Shopt, cmd.Shopt),
 			Stdin:     e.Stdin,
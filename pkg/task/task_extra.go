@@ -16,16 +16,24 @@ package task
 
 import (
 	"context"
-	"sync/atomic"
-
-	"mvdan.cc/sh/v3/interp"
+	"fmt"
+	"slices"
+	"time"
 
 	"github.com/hypershift-community/hyper-console/pkg/task/errors"
-	"github.com/hypershift-community/hyper-console/pkg/task/internal/fingerprint"
+	"github.com/hypershift-community/hyper-console/pkg/task/internal/editors"
 	"github.com/hypershift-community/hyper-console/pkg/task/internal/logger"
+	"github.com/hypershift-community/hyper-console/pkg/task/internal/templater"
 	"github.com/hypershift-community/hyper-console/pkg/task/taskfile/ast"
 )
 
+// EditorTaskfile is the listing of the tasks returned by ToEditorOutput, in the
+// shape of `task --list --json`.
+type EditorTaskfile = editors.Taskfile
+
+// EditorTask is a task of an EditorTaskfile.
+type EditorTask = editors.Task
+
 func (e *Executor) PrepareTask(call *Call) (*ast.Task, error) {
 	t, err := e.FastCompiledTask(call)
 	if err != nil {
@@ -51,109 +59,50 @@ func (e *Executor) PrepareTask(call *Call) (*ast.Task, error) {
 	return t, nil
 }
 
-// RunTaskCmd runs a task by its name
-func (e *Executor) RunTaskCmd(ctx context.Context, call *Call, t *ast.Task, cmdIndex int) error {
-	if cmdIndex < 0 || cmdIndex >= len(t.Cmds) {
+// DefaultDeferTimeout is the timeout of the deferred commands when neither they,
+// the executor nor their task set one.
+const DefaultDeferTimeout = 30 * time.Minute
+
+// RunDeferredTaskCmd runs a deferred command of a task, the StepDefer steps of a
+// Plan. It doesn't run the task dependencies nor check whether the task is
+// up-to-date, unlike the other steps of the plan: deferred commands are cleanup
+// steps and must run at the end of the task no matter how it ended, even when the
+// context of the task was cancelled. They run for up to their own timeout instead,
+// see [DefaultDeferTimeout]. The exitCode is the exit code of the command that
+// failed the task, if any, and is exposed to the command as EXIT_CODE.
+//
+// Errors of deferred commands are returned to the caller for reporting, but they must
+// not change the outcome of the task.
+func (e *Executor) RunDeferredTaskCmd(ctx context.Context, call *Call, t *ast.Task, cmdIndex int, exitCode uint8) error {
+	if cmdIndex < 0 || cmdIndex >= len(t.Cmds) || !t.Cmds[cmdIndex].Defer {
 		return &errors.TaskCmdIndexError{
 			TaskName: t.Task,
 			CmdIndex: cmdIndex,
 		}
 	}
 
-	if !e.Watch && atomic.AddInt32(e.taskCallCount[t.Task], 1) >= MaximumTaskCall {
-		return &errors.TaskCalledTooManyTimesError{
-			TaskName:        t.Task,
-			MaximumTaskCall: MaximumTaskCall,
-		}
-	}
-
-	release := e.acquireConcurrencyLimit()
-	defer release()
+	ctx, cancel := e.withDeferTimeout(context.WithoutCancel(ctx), t, cmdIndex)
+	defer cancel()
 
-	return e.startExecution(ctx, t, func(ctx context.Context) error {
-		e.Logger.VerboseErrf(logger.Magenta, "task: %q started\n", call.Task)
-		if err := e.runDeps(ctx, t); err != nil {
-			return err
-		}
+	origTask, err := e.GetTask(call)
+	if err != nil {
+		return err
+	}
 
-		skipFingerprinting := e.ForceAll || (!call.Indirect && e.Force)
-		if !skipFingerprinting {
-			if err := ctx.Err(); err != nil {
-				return err
-			}
-
-			preCondMet, err := e.areTaskPreconditionsMet(ctx, t)
-			if err != nil {
-				return err
-			}
-
-			// Get the fingerprinting method to use
-			method := e.Taskfile.Method
-			if t.Method != "" {
-				method = t.Method
-			}
-
-			upToDate, err := fingerprint.IsTaskUpToDate(ctx, t,
-				fingerprint.WithMethod(method),
-				fingerprint.WithTempDir(e.TempDir.Fingerprint),
-				fingerprint.WithDry(e.Dry),
-				fingerprint.WithLogger(e.Logger),
-			)
-			if err != nil {
-				return err
-			}
-
-			if upToDate && preCondMet {
-				if e.Verbose || (!call.Silent && !t.Silent && !e.Taskfile.Silent && !e.Silent) {
-					e.Logger.Errf(logger.Magenta, "task: Task %q is up to date\n", t.Name())
-				}
-				return nil
-			}
-		}
+	// Work on a copy so the templated command doesn't leak into the next run of the task.
+	deferred := *t
+	deferred.Cmds = slices.Clone(t.Cmds)
+	cmd := t.Cmds[cmdIndex].DeepCopy()
+	vars, _ := e.Compiler.GetVariables(origTask, call)
+	cache := &templater.Cache{Vars: vars}
+	extra := map[string]any{}
 
-		for _, p := range t.Prompt {
-			if p != "" && !e.Dry {
-				if err := e.Logger.Prompt(logger.Yellow, p, "n", "y", "yes"); errors.Is(err, logger.ErrNoTerminal) {
-					return &errors.TaskCancelledNoTerminalError{TaskName: call.Task}
-				} else if errors.Is(err, logger.ErrPromptCancelled) {
-					return &errors.TaskCancelledByUserError{TaskName: call.Task}
-				} else if err != nil {
-					return err
-				}
-			}
-		}
+	if exitCode > 0 {
+		extra["EXIT_CODE"] = fmt.Sprintf("%d", exitCode)
+	}
 
-		if err := e.mkdir(t); err != nil {
-			e.Logger.Errf(logger.Red, "task: cannot make directory %q: %v\n", t.Dir, err)
-		}
+	cmd.Cmd = templater.ReplaceWithExtra(cmd.Cmd, cache, extra)
+	deferred.Cmds[cmdIndex] = cmd
 
-		var deferredExitCode uint8
-
-		if t.Cmds[cmdIndex].Defer {
-			e.runDeferred(t, call, cmdIndex, &deferredExitCode)
-		} else {
-			if err := e.runCommand(ctx, t, call, cmdIndex); err != nil {
-				if err2 := e.statusOnError(t); err2 != nil {
-					e.Logger.VerboseErrf(logger.Yellow, "task: error cleaning status on error: %v\n", err2)
-				}
-
-				exitCode, isExitError := interp.IsExitStatus(err)
-				if isExitError {
-					if t.IgnoreError {
-						e.Logger.VerboseErrf(logger.Yellow, "task: task error ignored: %v\n", err)
-						return nil
-					}
-					deferredExitCode = exitCode
-				}
-
-				if call.Indirect {
-					return err
-				}
-
-				return &errors.TaskRunError{TaskName: t.Task, Err: err}
-			}
-			e.Logger.VerboseErrf(logger.Magenta, "task: %q finished\n", call.Task)
-		}
-		return nil
-	})
+	return timedOut(ctx, e.runCommand(ctx, &deferred, call, cmdIndex))
 }
@@ -1,6 +1,8 @@
 package ast
 
 import (
+	"time"
+
 	"gopkg.in/yaml.v3"
 
 	"github.com/hypershift-community/hyper-console/pkg/task/errors"
@@ -19,6 +21,10 @@ type Cmd struct {
 	IgnoreError bool
 	Defer       bool
 	Platforms   []*Platform
+	Timeout     time.Duration
+	Retry       *Retry
+	Wait        *Wait
+	Render      *Render
 }
 
 func (c *Cmd) DeepCopy() *Cmd {
@@ -36,6 +42,10 @@ func (c *Cmd) DeepCopy() *Cmd {
 		IgnoreError: c.IgnoreError,
 		Defer:       c.Defer,
 		Platforms:   deepcopy.Slice(c.Platforms),
+		Timeout:     c.Timeout,
+		Retry:       c.Retry.DeepCopy(),
+		Wait:        c.Wait.DeepCopy(),
+		Render:      c.Render.DeepCopy(),
 	}
 }
 
@@ -62,6 +72,10 @@ func (c *Cmd) UnmarshalYAML(node *yaml.Node) error {
 			IgnoreError bool `yaml:"ignore_error"`
 			Defer       *Defer
 			Platforms   []*Platform
+			Timeout     time.Duration
+			Retry       *Retry
+			Wait        *waitStruct
+			Render      *Render
 		}
 		if err := node.Decode(&cmdStruct); err != nil {
 			return errors.NewTaskfileDecodeError(err, node)
@@ -73,6 +87,7 @@ func (c *Cmd) UnmarshalYAML(node *yaml.Node) error {
 				c.Defer = true
 				c.Cmd = cmdStruct.Defer.Cmd
 				c.Silent = cmdStruct.Silent
+				c.Timeout = cmdStruct.Timeout
 				return nil
 			}
 
@@ -82,6 +97,7 @@ func (c *Cmd) UnmarshalYAML(node *yaml.Node) error {
 				c.Task = cmdStruct.Defer.Task
 				c.Vars = cmdStruct.Defer.Vars
 				c.Silent = cmdStruct.Defer.Silent
+				c.Timeout = cmdStruct.Timeout
 				return nil
 			}
 			return nil
@@ -93,6 +109,17 @@ func (c *Cmd) UnmarshalYAML(node *yaml.Node) error {
 			c.Vars = cmdStruct.Vars
 			c.For = cmdStruct.For
 			c.Silent = cmdStruct.Silent
+			c.Timeout = cmdStruct.Timeout
+			return nil
+		}
+
+		// A wait step
+		if cmdStruct.Wait != nil {
+			if err := cmdStruct.Wait.apply(c, node); err != nil {
+				return err
+			}
+			c.Silent = cmdStruct.Silent
+			c.Platforms = cmdStruct.Platforms
 			return nil
 		}
 
@@ -105,6 +132,9 @@ func (c *Cmd) UnmarshalYAML(node *yaml.Node) error {
 			c.Shopt = cmdStruct.Shopt
 			c.IgnoreError = cmdStruct.IgnoreError
 			c.Platforms = cmdStruct.Platforms
+			c.Timeout = cmdStruct.Timeout
+			c.Retry = cmdStruct.Retry
+			c.Render = cmdStruct.Render
 			return nil
 		}
 
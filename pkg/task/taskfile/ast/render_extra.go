@@ -0,0 +1,147 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"gopkg.in/yaml.v3"
+
+	"github.com/hypershift-community/hyper-console/pkg/jsonpath"
+	"github.com/hypershift-community/hyper-console/pkg/task/errors"
+	"github.com/hypershift-community/hyper-console/pkg/task/internal/deepcopy"
+)
+
+// Formats of the structured output of a command.
+const (
+	RenderTable   = "table"
+	RenderYAML    = "yaml"
+	RenderSummary = "summary"
+)
+
+// Render declares how the JSON or YAML output of a command is rendered in the run
+// view, instead of as raw text.
+type Render struct {
+	// Format is RenderTable, RenderYAML or RenderSummary.
+	Format string
+	// Path is a JSONPath selecting the data to render out of the output: the rows of
+	// a table, or the document rendered as YAML or summarized.
+	Path string
+	// Fields are the columns of a table or the entries of a summary.
+	Fields []*RenderField
+}
+
+// RenderField is a column of a table or an entry of a summary.
+type RenderField struct {
+	Name string
+	// Path is a JSONPath selecting the value of the field out of the rendered data.
+	Path string
+}
+
+func (r *Render) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+
+	// Shortcut syntax for the format
+	case yaml.ScalarNode:
+		if err := node.Decode(&r.Format); err != nil {
+			return errors.NewTaskfileDecodeError(err, node)
+		}
+
+	case yaml.MappingNode:
+		var render struct {
+			Format  string
+			Path    string
+			Columns []*RenderField
+			Fields  []*RenderField
+		}
+		if err := node.Decode(&render); err != nil {
+			return errors.NewTaskfileDecodeError(err, node)
+		}
+		r.Format = render.Format
+		r.Path = render.Path
+		r.Fields = append(render.Columns, render.Fields...)
+
+	default:
+		return errors.NewTaskfileDecodeError(nil, node).WithTypeMessage("render")
+	}
+
+	switch r.Format {
+	case RenderTable, RenderYAML, RenderSummary:
+	default:
+		return errors.NewTaskfileDecodeError(nil, node).WithMessage("unknown render format %q", r.Format)
+	}
+	paths := []string{r.Path}
+	for _, f := range r.Fields {
+		paths = append(paths, f.Path)
+	}
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		if _, err := jsonpath.Parse(p); err != nil {
+			return errors.NewTaskfileDecodeError(err, node).WithMessage("invalid render jsonpath")
+		}
+	}
+	return nil
+}
+
+func (f *RenderField) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+
+	// Shortcut syntax for a field named after its path
+	case yaml.ScalarNode:
+		if err := node.Decode(&f.Path); err != nil {
+			return errors.NewTaskfileDecodeError(err, node)
+		}
+		f.Name = f.Path
+
+	case yaml.MappingNode:
+		var field struct {
+			Name string
+			Path string
+		}
+		if err := node.Decode(&field); err != nil {
+			return errors.NewTaskfileDecodeError(err, node)
+		}
+		if field.Path == "" {
+			return errors.NewTaskfileDecodeError(nil, node).WithMessage("render field requires a path")
+		}
+		f.Name = field.Name
+		f.Path = field.Path
+		if f.Name == "" {
+			f.Name = f.Path
+		}
+
+	default:
+		return errors.NewTaskfileDecodeError(nil, node).WithTypeMessage("render field")
+	}
+	return nil
+}
+
+func (r *Render) DeepCopy() *Render {
+	if r == nil {
+		return nil
+	}
+	return &Render{
+		Format: r.Format,
+		Path:   r.Path,
+		Fields: deepcopy.Slice(r.Fields),
+	}
+}
+
+func (f *RenderField) DeepCopy() *RenderField {
+	if f == nil {
+		return nil
+	}
+	return &RenderField{Name: f.Name, Path: f.Path}
+}
@@ -0,0 +1,119 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hypershift-community/hyper-console/pkg/task/errors"
+	"github.com/hypershift-community/hyper-console/pkg/task/internal/deepcopy"
+)
+
+// Backoff strategies of a retry policy.
+const (
+	BackoffFixed       = "fixed"
+	BackoffExponential = "exponential"
+)
+
+// DefaultMaxRetryDelay is the longest delay of the exponential backoff when the
+// retry policy doesn't set one.
+const DefaultMaxRetryDelay = time.Hour
+
+// Retry is the retry policy of a command. A failed command runs again, up to
+// Attempts times in total, if its failure is retryable: when there is no filter, or
+// when its exit code is one of ExitCodes or its output matches Output.
+type Retry struct {
+	Attempts int
+	// Backoff is either BackoffFixed, which waits Delay between the attempts, or
+	// BackoffExponential, which doubles it after each attempt up to MaxDelay, or
+	// DefaultMaxRetryDelay, and adds jitter.
+	Backoff   string
+	Delay     time.Duration
+	MaxDelay  time.Duration
+	ExitCodes []int
+	Output    string
+}
+
+func (r *Retry) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+
+	// Shortcut syntax for the number of attempts
+	case yaml.ScalarNode:
+		var attempts int
+		if err := node.Decode(&attempts); err != nil {
+			return errors.NewTaskfileDecodeError(err, node)
+		}
+		r.Attempts = attempts
+
+	case yaml.MappingNode:
+		var retry struct {
+			Attempts  int
+			Backoff   string
+			Delay     time.Duration
+			MaxDelay  time.Duration `yaml:"max_delay"`
+			ExitCodes []int         `yaml:"exit_codes"`
+			Output    string
+		}
+		if err := node.Decode(&retry); err != nil {
+			return errors.NewTaskfileDecodeError(err, node)
+		}
+		r.Attempts = retry.Attempts
+		r.Backoff = retry.Backoff
+		r.Delay = retry.Delay
+		r.MaxDelay = retry.MaxDelay
+		r.ExitCodes = retry.ExitCodes
+		r.Output = retry.Output
+
+	default:
+		return errors.NewTaskfileDecodeError(nil, node).WithTypeMessage("retry")
+	}
+
+	if r.Attempts < 1 {
+		return errors.NewTaskfileDecodeError(nil, node).WithMessage("retry attempts must be at least 1")
+	}
+	switch r.Backoff {
+	case "":
+		r.Backoff = BackoffFixed
+	case BackoffFixed, BackoffExponential:
+	default:
+		return errors.NewTaskfileDecodeError(nil, node).WithMessage("unknown retry backoff %q", r.Backoff)
+	}
+	if r.Delay == 0 {
+		r.Delay = time.Second
+	}
+	if r.Output != "" {
+		if _, err := regexp.Compile(r.Output); err != nil {
+			return errors.NewTaskfileDecodeError(err, node).WithMessage("invalid retry output pattern")
+		}
+	}
+	return nil
+}
+
+func (r *Retry) DeepCopy() *Retry {
+	if r == nil {
+		return nil
+	}
+	return &Retry{
+		Attempts:  r.Attempts,
+		Backoff:   r.Backoff,
+		Delay:     r.Delay,
+		MaxDelay:  r.MaxDelay,
+		ExitCodes: deepcopy.Slice(r.ExitCodes),
+		Output:    r.Output,
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 
@@ -41,6 +42,7 @@ type Task struct {
 	Run           string
 	Platforms     []*Platform
 	Watch         bool
+	Timeout       time.Duration
 	Location      *Location
 	// Populated during merging
 	Namespace            string
@@ -138,6 +140,7 @@ func (t *Task) UnmarshalYAML(node *yaml.Node) error {
 			Platforms     []*Platform
 			Requires      *Requires
 			Watch         bool
+			Timeout       time.Duration
 		}
 		if err := node.Decode(&task); err != nil {
 			return errors.NewTaskfileDecodeError(err, node)
@@ -176,6 +179,7 @@ func (t *Task) UnmarshalYAML(node *yaml.Node) error {
 		t.Platforms = task.Platforms
 		t.Requires = task.Requires
 		t.Watch = task.Watch
+		t.Timeout = task.Timeout
 		return nil
 	}
 
@@ -220,6 +224,7 @@ func (t *Task) DeepCopy() *Task {
 		Location:             t.Location.DeepCopy(),
 		Requires:             t.Requires.DeepCopy(),
 		Namespace:            t.Namespace,
+		Timeout:              t.Timeout,
 	}
 	return c
 }
@@ -0,0 +1,81 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hypershift-community/hyper-console/pkg/jsonpath"
+	"github.com/hypershift-community/hyper-console/pkg/task/errors"
+)
+
+// DefaultWaitInterval is the interval between the checks of a wait step when none is
+// set.
+const DefaultWaitInterval = 10 * time.Second
+
+// Wait makes a command a wait step: the command is a check which runs at an interval
+// until the condition is met, or the step times out. Without a JSONPath, the condition is met when the check
+// succeeds. With one, the check must output JSON and the condition is met when the
+// JSONPath selects Value from it, or any non-empty value when Value is empty.
+type Wait struct {
+	JSONPath string
+	Value    string
+	Interval time.Duration
+}
+
+// waitStruct is the syntax of a wait step. The check command and the timeout of
+// the step are set on the command.
+type waitStruct struct {
+	Cmd      string
+	JSONPath string `yaml:"jsonpath"`
+	Value    string
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+func (w *waitStruct) apply(c *Cmd, node *yaml.Node) error {
+	if w.Cmd == "" {
+		return errors.NewTaskfileDecodeError(nil, node).WithMessage("wait requires a cmd")
+	}
+	if w.JSONPath != "" {
+		if _, err := jsonpath.Parse(w.JSONPath); err != nil {
+			return errors.NewTaskfileDecodeError(err, node).WithMessage("invalid wait jsonpath")
+		}
+	}
+	if w.Interval <= 0 {
+		w.Interval = DefaultWaitInterval
+	}
+	c.Cmd = w.Cmd
+	c.Timeout = w.Timeout
+	c.Wait = &Wait{
+		JSONPath: w.JSONPath,
+		Value:    w.Value,
+		Interval: w.Interval,
+	}
+	return nil
+}
+
+func (w *Wait) DeepCopy() *Wait {
+	if w == nil {
+		return nil
+	}
+	return &Wait{
+		JSONPath: w.JSONPath,
+		Value:    w.Value,
+		Interval: w.Interval,
+	}
+}
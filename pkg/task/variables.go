@@ -75,6 +75,7 @@ func (e *Executor) compiledTask(call *Call, evaluateShVars bool) (*ast.Task, err
 		Location:             origTask.Location,
 		Requires:             origTask.Requires,
 		Watch:                origTask.Watch,
+		Timeout:              origTask.Timeout,
 		Namespace:            origTask.Namespace,
 	}
 	new.Dir, err = execext.Expand(new.Dir)
@@ -176,6 +177,9 @@ func (e *Executor) compiledTask(call *Call, evaluateShVars bool) (*ast.Task, err
 					newCmd.Cmd = templater.ReplaceWithExtra(cmd.Cmd, cache, extra)
 					newCmd.Task = templater.ReplaceWithExtra(cmd.Task, cache, extra)
 					newCmd.Vars = templater.ReplaceVarsWithExtra(cmd.Vars, cache, extra)
+					if newCmd.Wait != nil {
+						newCmd.Wait.Value = templater.ReplaceWithExtra(cmd.Wait.Value, cache, extra)
+					}
 					new.Cmds = append(new.Cmds, newCmd)
 				}
 				continue
@@ -190,6 +194,9 @@ func (e *Executor) compiledTask(call *Call, evaluateShVars bool) (*ast.Task, err
 			newCmd.Cmd = templater.Replace(cmd.Cmd, cache)
 			newCmd.Task = templater.Replace(cmd.Task, cache)
 			newCmd.Vars = templater.ReplaceVars(cmd.Vars, cache)
+			if newCmd.Wait != nil {
+				newCmd.Wait.Value = templater.Replace(cmd.Wait.Value, cache)
+			}
 			new.Cmds = append(new.Cmds, newCmd)
 		}
 	}
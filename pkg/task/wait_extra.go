@@ -0,0 +1,148 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package task
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hypershift-community/hyper-console/pkg/jsonpath"
+	"github.com/hypershift-community/hyper-console/pkg/task/internal/execext"
+	"github.com/hypershift-community/hyper-console/pkg/task/internal/logger"
+	"github.com/hypershift-community/hyper-console/pkg/task/internal/slicesext"
+	"github.com/hypershift-community/hyper-console/pkg/task/taskfile/ast"
+)
+
+// Poll is the outcome of a check of the condition of a wait step.
+type Poll struct {
+	// Check is the number of the check, starting at 1.
+	Check int
+	// Value is the value observed by the check: the value selected by the JSONPath,
+	// or the last line of the output of the check without one.
+	Value string
+	Met   bool
+	// Err is the error of the check command, if it failed.
+	Err     error
+	Elapsed time.Duration
+}
+
+// DefaultWaitTimeout is the timeout of the wait steps when neither they nor the
+// executor set one, so that a condition which is never met doesn't poll forever.
+const DefaultWaitTimeout = 30 * time.Minute
+
+// A WaitObserver is notified of the checks of the wait steps. The [PlanObserver] of
+// a plan may implement it.
+type WaitObserver interface {
+	StepPolled(p *Plan, s *PlanStep, poll Poll)
+}
+
+// runWait runs the check of the wait step at index i of the task until its
+// condition is met or the step times out, see [DefaultWaitTimeout]. The output of the checks isn't shown,
+// except for the last one when the condition isn't met.
+func (e *Executor) runWait(ctx context.Context, t *ast.Task, call *Call, i int, stdout io.Writer, polled func(Poll)) error {
+	cmd := t.Cmds[i]
+
+	if !shouldRunOnCurrentPlatform(cmd.Platforms) {
+		e.Logger.VerboseOutf(logger.Yellow, "task: [%s] %s not for current platform - ignored\n", t.Name(), cmd.Cmd)
+		return nil
+	}
+
+	if e.Verbose || (!call.Silent && !cmd.Silent && !t.Silent && !e.Taskfile.Silent && !e.Silent) {
+		e.Logger.Errf(logger.Green, "task: [%s] wait: %s\n", t.Name(), cmd.Cmd)
+	}
+
+	if e.Dry {
+		return nil
+	}
+
+	var path *jsonpath.Template
+	if cmd.Wait.JSONPath != "" {
+		// The JSONPath is validated when the Taskfile is read
+		path = jsonpath.MustParse(cmd.Wait.JSONPath)
+	}
+	ctx, cancel := e.withWaitTimeout(ctx, t, i)
+	defer cancel()
+
+	start := time.Now()
+	var out bytes.Buffer
+	for check := 1; ; check++ {
+		out.Reset()
+		var errOut bytes.Buffer
+		err := execext.RunCommand(ctx, &execext.RunCommandOptions{
+			Command:   cmd.Cmd,
+			Dir:       t.Dir,
+			Env:       e.cmdEnv(t),
+			PosixOpts: slicesext.UniqueJoin(e.Taskfile.Set, t.Set, cmd.Set),
+			BashOpts:  slicesext.UniqueJoin(e.Taskfile.Shopt, t.Shopt, cmd.Shopt),
+			Stdin:     e.Stdin,
+			Stdout:    &out,
+			Stderr:    &errOut,
+		})
+		if err = timedOut(ctx, err); ctx.Err() != nil {
+			return e.waitFailed(stdout, &out, &errOut, err)
+		}
+
+		poll := Poll{Check: check, Err: err, Elapsed: time.Since(start)}
+		switch {
+		case err != nil:
+			poll.Value = lastLine(errOut.String())
+		case path != nil:
+			if poll.Value, err = path.ExecuteJSON(out.Bytes()); err != nil {
+				poll.Err = err
+			} else if cmd.Wait.Value == "" {
+				poll.Met = poll.Value != ""
+			} else {
+				poll.Met = poll.Value == cmd.Wait.Value
+			}
+		default:
+			poll.Value = lastLine(out.String())
+			poll.Met = true
+		}
+		if polled != nil {
+			polled(poll)
+		}
+		if poll.Met {
+			return nil
+		}
+
+		if err := sleep(ctx, cmd.Wait.Interval); err != nil {
+			return e.waitFailed(stdout, &out, &errOut, timedOut(ctx, err))
+		}
+	}
+}
+
+// waitFailed writes the output of the last check of a wait step which didn't meet
+// its condition, so that it can be looked into, and returns the error.
+func (e *Executor) waitFailed(stdout io.Writer, out, errOut *bytes.Buffer, err error) error {
+	if out.Len() > 0 || errOut.Len() > 0 {
+		fmt.Fprintln(stdout, "Output of the last check:")
+		_, _ = out.WriteTo(stdout)
+		_, _ = errOut.WriteTo(stdout)
+	}
+	return err
+}
+
+// lastLine returns the last non-empty line of the given output.
+func lastLine(s string) string {
+	s = strings.TrimRight(s, "\n\r\t ")
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	return strings.TrimSpace(s)
+}
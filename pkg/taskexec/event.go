@@ -0,0 +1,195 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package taskexec
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hypershift-community/hyper-console/pkg/task"
+)
+
+// EventBufferSize is the number of events buffered by the channel returned by
+// [ExecutorIterator.Events]. Once it is full, the commands block on their output
+// until the subscriber catches up.
+const EventBufferSize = 256
+
+// Event is an event published while the steps of a task run.
+type Event interface {
+	isEvent()
+}
+
+// CommandStarted is published when a step starts running.
+type CommandStarted struct {
+	Step Step
+	Time time.Time
+}
+
+// Stream identifies the output stream a chunk of output was written to.
+type Stream int
+
+const (
+	Stdout Stream = iota
+	Stderr
+)
+
+func (s Stream) String() string {
+	if s == Stderr {
+		return "stderr"
+	}
+	return "stdout"
+}
+
+// OutputChunk is published when a step writes to its output.
+type OutputChunk struct {
+	Step   Step
+	Stream Stream
+	Data   []byte
+}
+
+// CommandFinished is published when a step is done running.
+type CommandFinished struct {
+	Step     Step
+	ExitCode int
+	Duration time.Duration
+	Err      error
+	// Skipped is true if the step didn't run because the task is up-to-date.
+	Skipped bool
+	// Outputs are the outputs of the run once the step is done, the ones written by
+	// the step included.
+	Outputs map[string]string
+}
+
+// CommandRetrying is published when an attempt of a step failed and the step is
+// about to run again.
+type CommandRetrying struct {
+	Step    Step
+	Attempt task.Attempt
+}
+
+// ConditionPolled is published after each check of the condition of a wait step.
+type ConditionPolled struct {
+	Step Step
+	Poll task.Poll
+}
+
+// PromptRequested is published before a step asking the user to confirm running
+// the task.
+type PromptRequested struct {
+	Step   Step
+	Prompt string
+}
+
+func (CommandStarted) isEvent()  {}
+func (OutputChunk) isEvent()     {}
+func (CommandFinished) isEvent() {}
+func (CommandRetrying) isEvent() {}
+func (ConditionPolled) isEvent() {}
+func (PromptRequested) isEvent() {}
+
+// publisher publishes events to a bounded channel once someone subscribed to them.
+// Publishing blocks while the channel is full, which slows down the commands rather
+// than dropping their output, until the publisher is closed.
+type publisher struct {
+	mu     sync.RWMutex
+	once   sync.Once
+	ch     chan Event
+	done   chan struct{}
+	closed bool
+}
+
+func newPublisher() *publisher {
+	return &publisher{done: make(chan struct{})}
+}
+
+func (p *publisher) subscribe() <-chan Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ch == nil {
+		p.ch = make(chan Event, EventBufferSize)
+		if p.closed {
+			close(p.ch)
+		}
+	}
+	return p.ch
+}
+
+func (p *publisher) publish(e Event) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.ch == nil || p.closed {
+		return
+	}
+	select {
+	case p.ch <- e:
+	case <-p.done:
+	}
+}
+
+func (p *publisher) close() {
+	p.once.Do(func() {
+		// Unblock the publishers before waiting for them to release the lock
+		close(p.done)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.closed = true
+		if p.ch != nil {
+			close(p.ch)
+		}
+	})
+}
+
+// observer tracks the steps in the tree and publishes their retries and the checks
+// of the wait steps.
+type observer struct {
+	*Tree
+	t *_task
+}
+
+// StepRetrying implements [task.RetryObserver].
+func (o observer) StepRetrying(p *task.Plan, s *task.PlanStep, a task.Attempt) {
+	o.Tree.StepRetrying(p, s, a)
+	o.t.events.publish(CommandRetrying{Step: o.t.current, Attempt: a})
+}
+
+// StepPolled implements [task.WaitObserver].
+func (o observer) StepPolled(p *task.Plan, s *task.PlanStep, poll task.Poll) {
+	o.t.events.publish(ConditionPolled{Step: o.t.current, Poll: poll})
+}
+
+// eventWriter publishes what is written to it as output of the current step, on top
+// of writing it to the writer set with SetIO, if any.
+type eventWriter struct {
+	t      *_task
+	stream Stream
+}
+
+func (w *eventWriter) Write(p []byte) (int, error) {
+	out := w.t.stdout
+	if w.stream == Stderr {
+		out = w.t.stderr
+	}
+	if out != nil {
+		if n, err := out.Write(p); err != nil {
+			return n, err
+		}
+	}
+	w.t.events.publish(OutputChunk{
+		Step:   w.t.current,
+		Stream: w.stream,
+		Data:   append([]byte(nil), p...),
+	})
+	return len(p), nil
+}
@@ -15,28 +15,130 @@
 package taskexec
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"os"
+	"slices"
+	"time"
 
 	"github.com/hypershift-community/hyper-console/pkg/env"
 	"github.com/hypershift-community/hyper-console/pkg/iter"
 	"github.com/hypershift-community/hyper-console/pkg/task"
+	"github.com/hypershift-community/hyper-console/pkg/task/errors"
 	"github.com/hypershift-community/hyper-console/pkg/task/taskfile/ast"
 )
 
 type ExecutorIterator interface {
 	iter.Iterable[Executor]
 	GetTask() *ast.Task
+	// GetOutputStyle returns the output style the commands of the task use.
+	GetOutputStyle() ast.Output
+	// Steps returns all the steps of the execution plan of the task, in the order
+	// they run when nothing fails.
+	Steps() []Step
+	// Seek positions the iterator so that the next call to Next returns the
+	// executor of the step at the given index. This is used to resume a recipe
+	// from an arbitrary step without running the ones before it. Deferred
+	// commands declared before the step still run at the end.
+	Seek(index int) error
+	// Peek returns the step the next call to Next will return, if any.
+	Peek() (Step, bool)
+	// Cleanup ends the main phase of the task early, e.g. after a step failed
+	// or the run was cancelled. The remaining steps are dropped and only the
+	// deferred commands registered so far are returned by Next. The given error,
+	// if any, is used to set the EXIT_CODE of deferred commands.
+	Cleanup(err error)
+	// Tree returns the live tree of the steps of the task, where the calls to
+	// other tasks and the dependencies are expanded into their own steps.
+	Tree() *Tree
+	// Events returns the channel the events of the steps are published to. Nothing
+	// is published until it is called for the first time. The channel is buffered
+	// but the steps block once it is full, so it must be drained until Close.
+	Events() <-chan Event
+	// Close stops publishing events, closes the events channel and removes the
+	// outputs file.
+	Close()
 }
 
 type Executor interface {
-	Execute() error
+	// Execute runs the step. Cancelling the context kills the commands it runs, as
+	// timeouts do, but not the deferred ones, which run up to their own timeout.
+	Execute(ctx context.Context) error
 	SetEnv(env *env.Env)
 	SetIO(stdin io.Reader, stdout, stderr io.Writer)
+	// SetTimeout sets the timeout of the commands which don't set one.
+	SetTimeout(timeout time.Duration)
+	// SetOutputs sets outputs exposed to the commands as if a previous command had
+	// written them to the outputs file.
+	SetOutputs(outputs map[string]string)
+	// Outputs returns the outputs the commands wrote so far, along with the ones set
+	// with SetOutputs.
+	Outputs() map[string]string
+	// SetSpecialVars sets special variables available to the templates and to the
+	// commands as environment variables.
+	SetSpecialVars(vars map[string]string)
+	// Step returns the step this executor runs.
+	Step() Step
+	// Skipped returns true if the step didn't run because the task is up-to-date.
+	Skipped() bool
+	// Skip marks the step as skipped instead of executing it.
+	Skip()
 }
 
+// Phase is the phase of the task a step belongs to.
+type Phase int
+
+const (
+	// PhaseMain is the phase where the steps of the task run in order.
+	PhaseMain Phase = iota
+	// PhaseCleanup is the phase where the deferred commands run, in reverse order of
+	// declaration, once the main phase is over. It always runs, even after a failure.
+	PhaseCleanup
+)
+
+func (p Phase) String() string {
+	if p == PhaseCleanup {
+		return "cleanup"
+	}
+	return "main"
+}
+
+// Step is a single step of the execution plan of the task as returned by the iterator.
+type Step struct {
+	// Index is the index of the step in the plan.
+	Index int
+	Kind  task.StepKind
+	// CmdIndex is the index of the command in the task, or -1 for steps that are
+	// not commands like deps or status checks.
+	CmdIndex int
+	Cmd      *ast.Cmd
+	Phase    Phase
+
+	planStep *task.PlanStep
+}
+
+// String returns a human-readable description of the step.
+func (s Step) String() string {
+	if s.planStep == nil {
+		return ""
+	}
+	return s.planStep.String()
+}
+
+// The special variables describing the run of a recipe, see WithSpecialVars.
+const (
+	// EnvVar is the name of the environment the recipe runs in.
+	EnvVar = "HYPERDEV_ENV"
+	// RecipeVar is the name of the recipe.
+	RecipeVar = "HYPERDEV_RECIPE"
+	// RunIDVar is the ID of the run in the run history.
+	RunIDVar = "HYPERDEV_RUN_ID"
+	// ArtifactsVar is the directory where the commands write the files they
+	// generate, which is kept with the run history.
+	ArtifactsVar = "HYPERDEV_ARTIFACTS"
+)
+
 // TaskOption is a function that configures a task executor.
 type TaskOption func(Executor)
 
@@ -47,30 +149,128 @@ func WithEnv(env *env.Env) TaskOption {
 	}
 }
 
+// WithTimeout sets the default timeout of the commands of the task.
+func WithTimeout(timeout time.Duration) TaskOption {
+	return func(e Executor) {
+		e.SetTimeout(timeout)
+	}
+}
+
+// WithOutputs sets outputs exposed to the commands from the start, e.g. the ones of
+// a previous run.
+func WithOutputs(outputs map[string]string) TaskOption {
+	return func(e Executor) {
+		e.SetOutputs(outputs)
+	}
+}
+
+// WithSpecialVars sets special variables, such as the ones describing the run of a
+// recipe, available to the templates and to the commands as environment variables.
+func WithSpecialVars(vars map[string]string) TaskOption {
+	return func(e Executor) {
+		e.SetSpecialVars(vars)
+	}
+}
+
 func WithIO(stdin io.Reader, stdout, stderr io.Writer) TaskOption {
 	return func(e Executor) {
 		e.SetIO(stdin, stdout, stderr)
 	}
 }
 
+// _task iterates over the steps of the execution plan of a task following the
+// semantics of Task: the steps run in order, except for the deferred commands which
+// are registered when the iteration goes past their declaration and run in LIFO
+// order once the other steps are done or the task failed.
 type _task struct {
 	task.Executor
-	env      *env.Env
-	cmdIndex int
+	env *env.Env
+	// pos is the index of the next step of the plan to visit.
+	pos int
+	// reached is the index of the last command reached during the main phase. The
+	// deferred commands declared before it are the ones that run during cleanup.
+	reached  int
+	phase    Phase
+	current  Step
+	skipped  bool
 	prepared bool
 	task     *ast.Task
 	call     *task.Call
+	plan     *task.Plan
+	tree     *Tree
+	events   *publisher
+	// stdout and stderr are the writers set with SetIO. The executor writes to
+	// eventWriters instead, which publish the output before forwarding it.
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// ListTasks lists the tasks of the Taskfile in the directory but the internal
+// ones, in the shape of `task --list --json` which the editors integrate with.
+// Whether the tasks are up-to-date isn't checked, it would run their status
+// commands.
+func ListTasks(dir string) (*task.EditorTaskfile, error) {
+	e, err := setupQuiet(dir)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := e.GetTaskList(task.FilterOutInternal)
+	if err != nil {
+		return nil, fmt.Errorf("error listing tasks: %w", err)
+	}
+	return e.ToEditorOutput(tasks, true)
+}
+
+// EnvNames returns the sorted names of the environment variables declared by the
+// Taskfile in the directory and by its tasks. The outputs given to a run, such as
+// the parameters of a workflow node, override them.
+func EnvNames(dir string) ([]string, error) {
+	e, err := setupQuiet(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := slices.Collect(e.Taskfile.Env.Keys())
+	for t := range e.Taskfile.Tasks.Values(nil) {
+		names = append(names, slices.Collect(t.Env.Keys())...)
+	}
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
+
+// noInput is the input of the commands, always empty. Unlike an empty buffer it
+// has no state, the shell copying it in the background after a command exited
+// doesn't race with the next command.
+type noInput struct{}
+
+func (noInput) Read([]byte) (int, error) {
+	return 0, io.EOF
+}
+
+// setupQuiet sets up an executor of the Taskfile in the directory which doesn't
+// run anything.
+func setupQuiet(dir string) (*task.Executor, error) {
+	e := &task.Executor{
+		Dir:    dir,
+		Stdin:  noInput{},
+		Stdout: io.Discard,
+		Stderr: io.Discard,
+	}
+	if err := e.Setup(); err != nil {
+		return nil, fmt.Errorf("error setting up task executor: %w", err)
+	}
+	return e, nil
 }
 
 func NewExecutorIterator(dir string, opts ...TaskOption) (ExecutorIterator, int, error) {
 	t := &_task{
 		Executor: task.Executor{
-			Dir:    dir,
-			Stdin:  &bytes.Buffer{},
-			Stdout: &bytes.Buffer{},
-			Stderr: &bytes.Buffer{},
+			Dir:   dir,
+			Stdin: noInput{},
 		},
+		events: newPublisher(),
 	}
+	t.Stdout = &eventWriter{t: t, stream: Stdout}
+	t.Stderr = &eventWriter{t: t, stream: Stderr}
 	for _, opt := range opts {
 		opt(t)
 	}
@@ -91,52 +291,219 @@ func NewExecutorIterator(dir string, opts ...TaskOption) (ExecutorIterator, int,
 		// Define the _task to run
 		call := &task.Call{Task: "default"}
 
-		task, err := t.PrepareTask(call)
+		plan, err := t.CompilePlan(call)
 		if err != nil {
 			return nil, -1, fmt.Errorf("error perapring execution of task: %w", err)
 		}
-		t.task = task
+		t.plan = plan
+		t.tree = newTree(plan, func() (io.Writer, io.Writer) {
+			return t.Stdout, t.Stderr
+		})
+		plan.SetObserver(observer{Tree: t.tree, t: t})
+		t.task = plan.Task
 		t.call = call
+		t.reached = -1
 		t.prepared = true
 	}
-	return t, len(t.task.Cmds), nil
+	if t.OutputsFile == "" {
+		// Each run has its own outputs file, see task.OutputsEnvVar
+		f, err := os.CreateTemp("", "hyperdev-outputs-*")
+		if err != nil {
+			return nil, -1, fmt.Errorf("error creating outputs file: %w", err)
+		}
+		_ = f.Close()
+		t.OutputsFile = f.Name()
+	}
+	return t, len(t.plan.Steps), nil
 }
 
 func (t *_task) HasNext() bool {
-	return t.cmdIndex < len(t.task.Cmds)
+	_, ok := t.Peek()
+	return ok
 }
 
-func (t *_task) Next() (Executor, error) {
+func (t *_task) Peek() (Step, bool) {
+	i, phase := t.next()
+	if i < 0 {
+		return Step{}, false
+	}
+	return t.stepAt(i, phase), true
+}
 
-	if t.cmdIndex >= len(t.task.Cmds) {
+func (t *_task) Next() (Executor, error) {
+	i, phase := t.next()
+	if i < 0 {
 		return nil, fmt.Errorf("no more commands to run")
 	}
-	t.cmdIndex++
+	step := t.stepAt(i, phase)
+	if phase == PhaseMain {
+		if step.CmdIndex >= 0 {
+			// Going past the deferred commands declared before this one registers them
+			t.reached = step.CmdIndex
+		}
+	} else if t.phase == PhaseMain {
+		// The main phase completed, all the deferred commands are registered
+		t.phase = PhaseCleanup
+		t.reached = len(t.task.Cmds)
+	}
+	t.pos = i + 1
+	t.current = step
+	t.skipped = false
 	return t, nil
 }
 
-func (t *_task) Execute() error {
-	if t.cmdIndex > len(t.task.Cmds) {
-		return fmt.Errorf("no more commands to run")
+// next returns the index and phase of the next step to run, or -1 if there is none.
+func (t *_task) next() (int, Phase) {
+	steps := t.plan.Steps
+	reached := t.reached
+	if t.phase == PhaseMain {
+		for i := t.pos; i < len(steps); i++ {
+			if steps[i].Kind != task.StepDefer {
+				return i, PhaseMain
+			}
+		}
+		reached = len(t.task.Cmds)
+	}
+	for i := t.pos; i < len(steps); i++ {
+		if t.plan.Registered(steps[i], reached) {
+			return i, PhaseCleanup
+		}
+	}
+	return -1, PhaseMain
+}
+
+func (t *_task) stepAt(i int, phase Phase) Step {
+	s := t.plan.Steps[i]
+	return Step{
+		Index:    i,
+		Kind:     s.Kind,
+		CmdIndex: s.CmdIndex,
+		Cmd:      s.Cmd,
+		Phase:    phase,
+		planStep: s,
+	}
+}
+
+func (t *_task) Steps() []Step {
+	steps := make([]Step, len(t.plan.Steps))
+	for i, s := range t.plan.Steps {
+		phase := PhaseMain
+		if s.Kind == task.StepDefer {
+			phase = PhaseCleanup
+		}
+		steps[i] = t.stepAt(i, phase)
+	}
+	return steps
+}
+
+func (t *_task) Seek(index int) error {
+	if index < 0 || index >= len(t.plan.Steps) {
+		return &errors.TaskCmdIndexError{
+			TaskName: t.task.Task,
+			CmdIndex: index,
+		}
+	}
+	t.pos = index
+	if t.plan.Steps[index].Kind == task.StepDefer {
+		t.phase = PhaseCleanup
+		t.reached = len(t.task.Cmds)
+		return nil
 	}
-	// Run the _task
-	//TODO: Weave in a context
-	if err := t.RunTaskCmd(context.Background(), t.call, t.task, t.cmdIndex-1); err != nil {
+	t.phase = PhaseMain
+	t.reached = -1
+	return nil
+}
+
+func (t *_task) Cleanup(err error) {
+	if t.phase == PhaseCleanup {
+		return
+	}
+	t.phase = PhaseCleanup
+	t.plan.Fail(err)
+	// The deferred steps are at the end of the plan
+	for t.pos < len(t.plan.Steps) && t.plan.Steps[t.pos].Kind != task.StepDefer {
+		t.pos++
+	}
+}
+
+func (t *_task) Execute(ctx context.Context) error {
+	if t.current.planStep == nil {
+		return fmt.Errorf("no command to run")
+	}
+	step := t.current
+	t.skipped = t.plan.Skip(step.planStep)
+	start := time.Now()
+	t.events.publish(CommandStarted{Step: step, Time: start})
+	if step.Kind == task.StepPrompt && !t.skipped {
+		t.events.publish(PromptRequested{Step: step, Prompt: step.planStep.Prompt})
+	}
+	err := t.plan.RunStep(ctx, step.planStep)
+	t.events.publish(CommandFinished{
+		Step:     step,
+		ExitCode: task.ExitCode(err),
+		Duration: time.Since(start),
+		Err:      err,
+		Skipped:  t.skipped,
+		Outputs:  t.Outputs(),
+	})
+	if err != nil {
+		if step.Phase == PhaseCleanup {
+			return fmt.Errorf("error running deferred command: %w", err)
+		}
 		return fmt.Errorf("error running task: %w", err)
 	}
 	return nil
 }
 
+func (t *_task) Step() Step {
+	return t.current
+}
+
+func (t *_task) Skipped() bool {
+	return t.skipped
+}
+
+func (t *_task) Skip() {
+	if t.current.planStep != nil {
+		t.tree.Skip(t.current.planStep)
+	}
+}
+
+func (t *_task) Tree() *Tree {
+	return t.tree
+}
+
 func (t *_task) SetEnv(env *env.Env) {
 	t.env = env
 }
 
 func (t *_task) SetIO(stdin io.Reader, stdout, stderr io.Writer) {
 	t.Stdin = stdin
-	t.Stdout = stdout
-	t.Stderr = stderr
+	t.stdout = stdout
+	t.stderr = stderr
+}
+
+func (t *_task) SetTimeout(timeout time.Duration) {
+	t.CmdTimeout = timeout
+}
+
+func (t *_task) SetSpecialVars(vars map[string]string) {
+	t.SpecialVars = vars
+}
+
+func (t *_task) Events() <-chan Event {
+	return t.events.subscribe()
+}
+
+func (t *_task) Close() {
+	t.events.close()
+	_ = os.Remove(t.OutputsFile)
 }
 
 func (t *_task) GetTask() *ast.Task {
 	return t.task
 }
+
+func (t *_task) GetOutputStyle() ast.Output {
+	return t.OutputStyle
+}
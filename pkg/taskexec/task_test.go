@@ -16,13 +16,19 @@ package taskexec
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/hypershift-community/hyper-console/pkg/env"
+	"github.com/hypershift-community/hyper-console/pkg/task"
+	"github.com/hypershift-community/hyper-console/pkg/task/errors"
 )
 
 type validator func(t *testing.T, out string)
@@ -130,7 +136,7 @@ tasks:
 				var stderr bytes.Buffer
 				task.SetIO(nil, &stdout, &stderr)
 
-				err = task.Execute()
+				err = task.Execute(context.Background())
 				require.NoError(t, err)
 				tt.validators[i](t, stdout.String())
 			}
@@ -148,3 +154,916 @@ func writeTaskFile(dir, content string) error {
 	_, err = fd.WriteString(content)
 	return err
 }
+
+func Test_task_Seek(t *testing.T) {
+	dir := t.TempDir()
+	err := writeTaskFile(dir, `version: '3'
+tasks:
+  default:
+    cmds:
+      - echo first
+      - echo second
+      - echo third
+`)
+	require.NoError(t, err)
+
+	taskIter, n, err := NewExecutorIterator(dir)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	require.ErrorContains(t, taskIter.Seek(3), "has no command at index 3")
+	require.ErrorContains(t, taskIter.Seek(-1), "has no command at index -1")
+
+	require.NoError(t, taskIter.Seek(1))
+	var outputs []string
+	for taskIter.HasNext() {
+		task, err := taskIter.Next()
+		require.NoError(t, err)
+
+		var stdout bytes.Buffer
+		task.SetIO(nil, &stdout, &bytes.Buffer{})
+		require.NoError(t, task.Execute(context.Background()))
+		outputs = append(outputs, stdout.String())
+	}
+	require.Equal(t, []string{"second\n", "third\n"}, outputs)
+}
+
+func Test_task_Deferred(t *testing.T) {
+	tests := []struct {
+		name           string
+		taskYaml       string
+		expectedOutput []string
+		expectedPhases []Phase
+	}{
+		{
+			name: "deferred commands should run at the end in LIFO order",
+			taskYaml: `version: '3'
+tasks:
+  default:
+    cmds:
+      - echo create bucket
+      - defer: echo delete bucket
+      - echo create cluster
+      - defer: echo delete cluster
+      - echo run tests
+`,
+			expectedOutput: []string{"create bucket\n", "create cluster\n", "run tests\n", "delete cluster\n", "delete bucket\n"},
+			expectedPhases: []Phase{PhaseMain, PhaseMain, PhaseMain, PhaseCleanup, PhaseCleanup},
+		},
+		{
+			name: "only the deferred commands registered before a failure should run",
+			taskYaml: `version: '3'
+tasks:
+  default:
+    cmds:
+      - echo create bucket
+      - defer: echo delete bucket {{.EXIT_CODE}}
+      - exit 3
+      - defer: echo delete cluster
+      - echo run tests
+`,
+			expectedOutput: []string{"create bucket\n", "", "delete bucket 3\n"},
+			expectedPhases: []Phase{PhaseMain, PhaseMain, PhaseCleanup},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			err := writeTaskFile(dir, tt.taskYaml)
+			require.NoError(t, err)
+
+			taskIter, _, err := NewExecutorIterator(dir)
+			require.NoError(t, err)
+
+			var outputs []string
+			var phases []Phase
+			for taskIter.HasNext() {
+				task, err := taskIter.Next()
+				require.NoError(t, err)
+
+				var stdout bytes.Buffer
+				task.SetIO(nil, &stdout, &bytes.Buffer{})
+				if err := task.Execute(context.Background()); err != nil {
+					taskIter.Cleanup(err)
+				}
+				outputs = append(outputs, stdout.String())
+				phases = append(phases, task.Step().Phase)
+			}
+			require.Equal(t, tt.expectedOutput, outputs)
+			require.Equal(t, tt.expectedPhases, phases)
+		})
+	}
+}
+
+func Test_task_Plan(t *testing.T) {
+	tests := []struct {
+		name            string
+		taskYaml        string
+		expectedKinds   []task.StepKind
+		expectedSkipped []bool
+		expectedDepRuns int
+	}{
+		{
+			name: "deps and status should be checked once for the whole task",
+			taskYaml: `version: '3'
+tasks:
+  dep:
+    cmds:
+      - echo dep >> deps.log
+  default:
+    deps: [dep]
+    status:
+      - test -f missing
+    cmds:
+      - echo one
+      - echo two
+`,
+			expectedKinds:   []task.StepKind{task.StepDeps, task.StepStatus, task.StepCmd, task.StepCmd},
+			expectedSkipped: []bool{false, false, false, false},
+			expectedDepRuns: 1,
+		},
+		{
+			name: "commands of an up-to-date task should be skipped",
+			taskYaml: `version: '3'
+tasks:
+  default:
+    status:
+      - "true"
+    cmds:
+      - echo one
+      - defer: echo cleanup
+      - task: other
+  other:
+    cmds:
+      - echo other
+`,
+			expectedKinds:   []task.StepKind{task.StepStatus, task.StepCmd, task.StepTask, task.StepDefer},
+			expectedSkipped: []bool{false, true, true, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			err := writeTaskFile(dir, tt.taskYaml)
+			require.NoError(t, err)
+
+			taskIter, n, err := NewExecutorIterator(dir)
+			require.NoError(t, err)
+			require.Equal(t, len(tt.expectedKinds), n)
+
+			var kinds []task.StepKind
+			var skipped []bool
+			for taskIter.HasNext() {
+				e, err := taskIter.Next()
+				require.NoError(t, err)
+				e.SetIO(nil, &bytes.Buffer{}, &bytes.Buffer{})
+				require.NoError(t, e.Execute(context.Background()))
+				kinds = append(kinds, e.Step().Kind)
+				skipped = append(skipped, e.Skipped())
+			}
+			require.Equal(t, tt.expectedKinds, kinds)
+			require.Equal(t, tt.expectedSkipped, skipped)
+
+			if tt.expectedDepRuns > 0 {
+				log, err := os.ReadFile(filepath.Join(dir, "deps.log"))
+				require.NoError(t, err)
+				require.Equal(t, tt.expectedDepRuns, strings.Count(string(log), "dep\n"))
+			}
+		})
+	}
+}
+
+func Test_task_Tree(t *testing.T) {
+	tests := []struct {
+		name           string
+		taskYaml       string
+		expectedTree   string
+		expectedOutput map[string]string
+		expectError    bool
+	}{
+		{
+			name: "nested calls and deps should be expanded",
+			taskYaml: `version: '3'
+tasks:
+  a:
+    cmds:
+      - echo a
+  b:
+    cmds:
+      - echo b
+  nested:
+    cmds:
+      - echo nested
+      - task: a
+  default:
+    deps: [a, b]
+    cmds:
+      - task: nested
+      - echo done
+`,
+			expectedTree: `deps: a, b succeeded
+  task: a succeeded parallel
+    echo a succeeded
+  task: b succeeded parallel
+    echo b succeeded
+task: nested succeeded
+  echo nested succeeded
+  task: a succeeded
+    echo a succeeded
+echo done succeeded
+`,
+			expectedOutput: map[string]string{
+				"task: nested": "nested\na\n",
+				"echo done":    "done\n",
+			},
+		},
+		{
+			name: "a failed nested step should fail its ancestors and skip the remaining steps",
+			taskYaml: `version: '3'
+tasks:
+  failing:
+    cmds:
+      - defer: echo cleanup
+      - exit 3
+      - echo never
+  default:
+    cmds:
+      - task: failing
+      - echo after
+`,
+			expectedTree: `task: failing failed
+  exit 3 failed
+  echo never skipped
+  defer: echo cleanup succeeded
+echo after pending
+`,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			err := writeTaskFile(dir, tt.taskYaml)
+			require.NoError(t, err)
+
+			taskIter, _, err := NewExecutorIterator(dir)
+			require.NoError(t, err)
+
+			var execErr error
+			for execErr == nil && taskIter.HasNext() {
+				e, err := taskIter.Next()
+				require.NoError(t, err)
+				e.SetIO(nil, &bytes.Buffer{}, &bytes.Buffer{})
+				execErr = e.Execute(context.Background())
+			}
+			require.Equal(t, tt.expectError, execErr != nil)
+
+			sb := strings.Builder{}
+			outputs := map[string]string{}
+			var walk func(nodes []*Node, depth int)
+			walk = func(nodes []*Node, depth int) {
+				for _, n := range nodes {
+					sb.WriteString(strings.Repeat("  ", depth) + n.Label + " " + n.Status.String())
+					if n.Parallel {
+						sb.WriteString(" parallel")
+					}
+					sb.WriteString("\n")
+					if depth == 0 {
+						outputs[n.Label] = taskIter.Tree().Output(n.ID)
+					}
+					walk(n.Children, depth+1)
+				}
+			}
+			walk(taskIter.Tree().Roots(), 0)
+			require.Equal(t, tt.expectedTree, sb.String())
+			for label, output := range tt.expectedOutput {
+				require.Equal(t, output, outputs[label], label)
+			}
+		})
+	}
+}
+
+func Test_task_Events(t *testing.T) {
+	dir := t.TempDir()
+	err := writeTaskFile(dir, `version: '3'
+tasks:
+  default:
+    silent: true
+    cmds:
+      - echo out
+      - echo err >&2
+      - exit 2
+`)
+	require.NoError(t, err)
+
+	taskIter, _, err := NewExecutorIterator(dir)
+	require.NoError(t, err)
+	events := taskIter.Events()
+
+	done := make(chan []Event)
+	go func() {
+		var received []Event
+		for e := range events {
+			received = append(received, e)
+		}
+		done <- received
+	}()
+
+	for taskIter.HasNext() {
+		e, err := taskIter.Next()
+		require.NoError(t, err)
+		if err := e.Execute(context.Background()); err != nil {
+			break
+		}
+	}
+	taskIter.Close()
+	received := <-done
+
+	var (
+		started, finished []int
+		exitCodes         []int
+		output            = map[Stream]string{}
+	)
+	for _, e := range received {
+		switch e := e.(type) {
+		case CommandStarted:
+			started = append(started, e.Step.Index)
+		case OutputChunk:
+			output[e.Stream] += string(e.Data)
+		case CommandFinished:
+			finished = append(finished, e.Step.Index)
+			exitCodes = append(exitCodes, e.ExitCode)
+		}
+	}
+	require.Equal(t, []int{0, 1, 2}, started)
+	require.Equal(t, []int{0, 1, 2}, finished)
+	require.Equal(t, []int{0, 0, 2}, exitCodes)
+	require.Equal(t, "out\n", output[Stdout])
+	require.Equal(t, "err\n", output[Stderr])
+}
+
+func Test_task_Timeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		taskFile string
+		opts     []TaskOption
+		cmd      string
+	}{
+		{
+			name: "command timeout",
+			taskFile: `version: '3'
+tasks:
+  default:
+    cmds:
+      - cmd: sleep 5
+        timeout: 200ms
+`,
+			cmd: "sleep 5",
+		},
+		{
+			name: "process group",
+			taskFile: `version: '3'
+tasks:
+  default:
+    cmds:
+      - cmd: sh -c 'sleep 5 & sleep 5'
+        timeout: 200ms
+`,
+			cmd: "sh -c 'sleep 5 & sleep 5'",
+		},
+		{
+			name: "task timeout",
+			taskFile: `version: '3'
+tasks:
+  default:
+    timeout: 200ms
+    cmds:
+      - sleep 5
+`,
+			cmd: "sleep 5",
+		},
+		{
+			name: "task call timeout",
+			taskFile: `version: '3'
+tasks:
+  default:
+    cmds:
+      - task: sleep
+        timeout: 200ms
+  sleep:
+    cmds:
+      - sleep 5
+`,
+			cmd: "task sleep",
+		},
+		{
+			name: "default timeout",
+			taskFile: `version: '3'
+tasks:
+  default:
+    cmds:
+      - sleep 5
+`,
+			opts: []TaskOption{WithTimeout(200 * time.Millisecond)},
+			cmd:  "sleep 5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, writeTaskFile(dir, tt.taskFile))
+
+			taskIter, _, err := NewExecutorIterator(dir, tt.opts...)
+			require.NoError(t, err)
+			defer taskIter.Close()
+			e, err := taskIter.Next()
+			require.NoError(t, err)
+
+			start := time.Now()
+			err = e.Execute(context.Background())
+			require.Less(t, time.Since(start), 5*time.Second)
+			var timeoutErr *errors.TaskTimeoutError
+			require.ErrorAs(t, err, &timeoutErr)
+			require.Equal(t, tt.cmd, timeoutErr.Cmd)
+			require.Equal(t, 200*time.Millisecond, timeoutErr.Timeout)
+			require.Equal(t, errors.CodeTaskTimeout, task.ExitCode(err))
+		})
+	}
+}
+
+func Test_task_Abort(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, writeTaskFile(dir, `version: '3'
+tasks:
+  default:
+    cmds:
+      - defer: echo cleanup
+      - sh -c 'sleep 60 & sleep 60'
+`))
+
+	taskIter, _, err := NewExecutorIterator(dir)
+	require.NoError(t, err)
+	defer taskIter.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	var outputs []string
+	for taskIter.HasNext() {
+		e, err := taskIter.Next()
+		require.NoError(t, err)
+
+		var stdout bytes.Buffer
+		e.SetIO(nil, &stdout, &bytes.Buffer{})
+		start := time.Now()
+		if err := e.Execute(ctx); err != nil {
+			// Aborting kills the process group of the command
+			require.Less(t, time.Since(start), 5*time.Second)
+			require.ErrorContains(t, err, "context canceled")
+			taskIter.Cleanup(err)
+		}
+		outputs = append(outputs, stdout.String())
+	}
+	// The deferred commands still run once the run is aborted
+	require.Equal(t, []string{"", "cleanup\n"}, outputs)
+}
+
+func Test_task_TaskTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		taskFile string
+		// failed is the index of the step which times out
+		failed int
+		cmd    string
+	}{
+		{
+			name: "several commands",
+			taskFile: `version: '3'
+tasks:
+  default:
+    timeout: 500ms
+    cmds:
+      - sleep 0.2
+      - sleep 0.2
+      - sleep 0.2
+`,
+			failed: 2,
+			cmd:    "sleep 0.2",
+		},
+		{
+			name: "deps",
+			taskFile: `version: '3'
+tasks:
+  default:
+    timeout: 200ms
+    deps: [slow]
+    cmds:
+      - echo done
+  slow:
+    cmds:
+      - sleep 5
+`,
+			failed: 0,
+			cmd:    "deps: slow",
+		},
+		{
+			name: "nested call",
+			taskFile: `version: '3'
+tasks:
+  default:
+    timeout: 300ms
+    cmds:
+      - sleep 0.2
+      - task: slow
+  slow:
+    cmds:
+      - sleep 5
+`,
+			failed: 1,
+			cmd:    "task slow",
+		},
+		{
+			name: "deferred command",
+			taskFile: `version: '3'
+tasks:
+  default:
+    timeout: 200ms
+    cmds:
+      - defer: sleep 5
+      - echo done
+`,
+			failed: 1,
+			cmd:    "sleep 5",
+		},
+		{
+			name: "deferred command with its own timeout",
+			taskFile: `version: '3'
+tasks:
+  default:
+    cmds:
+      - defer: sleep 5
+        timeout: 200ms
+      - echo done
+`,
+			failed: 1,
+			cmd:    "sleep 5",
+		},
+		{
+			name: "deferred task call with its own timeout",
+			taskFile: `version: '3'
+tasks:
+  default:
+    cmds:
+      - defer:
+          task: slow
+        timeout: 200ms
+      - echo done
+  slow:
+    cmds:
+      - sleep 5
+`,
+			failed: 1,
+			cmd:    "task slow",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, writeTaskFile(dir, tt.taskFile))
+
+			taskIter, _, err := NewExecutorIterator(dir)
+			require.NoError(t, err)
+			defer taskIter.Close()
+
+			start := time.Now()
+			failed := -1
+			var timeoutErr *errors.TaskTimeoutError
+			for i := 0; taskIter.HasNext(); i++ {
+				e, err := taskIter.Next()
+				require.NoError(t, err)
+				e.SetIO(nil, &bytes.Buffer{}, &bytes.Buffer{})
+				if err := e.Execute(context.Background()); err != nil {
+					require.Equal(t, -1, failed, "unexpected error: %v", err)
+					require.ErrorAs(t, err, &timeoutErr)
+					failed = i
+					taskIter.Cleanup(err)
+				}
+			}
+			require.Less(t, time.Since(start), 2*time.Second)
+			require.Equal(t, tt.failed, failed)
+			require.Equal(t, tt.cmd, timeoutErr.Cmd)
+		})
+	}
+}
+
+func Test_task_Retry(t *testing.T) {
+	// The command fails until its third attempt
+	const cmd = `n=$(cat count 2>/dev/null || echo 0); n=$((n+1)); echo $n > count; echo "attempt $n"; [ $n -ge 3 ] || exit 3`
+	tests := []struct {
+		name     string
+		retry    string
+		attempts []int
+		wantErr  bool
+	}{
+		{
+			name:     "attempts",
+			retry:    `{attempts: 3, delay: 10ms}`,
+			attempts: []int{1, 2},
+		},
+		{
+			name:     "too few attempts",
+			retry:    `{attempts: 2, delay: 10ms, backoff: exponential}`,
+			attempts: []int{1},
+			wantErr:  true,
+		},
+		{
+			name:     "retryable exit code",
+			retry:    `{attempts: 3, delay: 10ms, exit_codes: [3]}`,
+			attempts: []int{1, 2},
+		},
+		{
+			name:    "not retryable exit code",
+			retry:   `{attempts: 3, delay: 10ms, exit_codes: [1, 2]}`,
+			wantErr: true,
+		},
+		{
+			name:     "retryable output",
+			retry:    `{attempts: 3, delay: 10ms, output: "attempt [12]"}`,
+			attempts: []int{1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, writeTaskFile(dir, fmt.Sprintf(`version: '3'
+tasks:
+  default:
+    silent: true
+    cmds:
+      - cmd: '%s'
+        retry: %s
+`, cmd, tt.retry)))
+
+			taskIter, _, err := NewExecutorIterator(dir)
+			require.NoError(t, err)
+			events := taskIter.Events()
+			done := make(chan []int)
+			go func() {
+				var attempts []int
+				for e := range events {
+					if e, ok := e.(CommandRetrying); ok {
+						attempts = append(attempts, e.Attempt.Number)
+					}
+				}
+				done <- attempts
+			}()
+
+			e, err := taskIter.Next()
+			require.NoError(t, err)
+			err = e.Execute(context.Background())
+			taskIter.Close()
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.attempts, <-done)
+		})
+	}
+}
+
+func Test_task_Wait(t *testing.T) {
+	// Each check outputs the number of checks so far
+	const check = `n=$(cat count 2>/dev/null || echo 0); n=$((n+1)); echo $n > count; echo "{\"status\": {\"ready\": $n}}"`
+	tests := []struct {
+		name    string
+		wait    string
+		polls   []string
+		met     bool
+		timeout bool
+	}{
+		{
+			name:  "jsonpath value",
+			wait:  `{cmd: '%s', jsonpath: '{.status.ready}', value: "3", interval: 10ms}`,
+			polls: []string{"1", "2", "3"},
+			met:   true,
+		},
+		{
+			name:  "jsonpath any value",
+			wait:  `{cmd: '%s', jsonpath: '{.status.ready}', interval: 10ms}`,
+			polls: []string{"1"},
+			met:   true,
+		},
+		{
+			name:  "check command",
+			wait:  `{cmd: '%s && [ $n -ge 2 ]', interval: 10ms}`,
+			polls: []string{"", `{"status": {"ready": 2}}`},
+			met:   true,
+		},
+		{
+			name:    "timeout",
+			wait:    `{cmd: '%s', jsonpath: '{.status.phase}', value: Ready, interval: 50ms, timeout: 120ms}`,
+			polls:   []string{"", "", ""},
+			timeout: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, writeTaskFile(dir, fmt.Sprintf(`version: '3'
+tasks:
+  default:
+    silent: true
+    cmds:
+      - wait: %s
+`, fmt.Sprintf(tt.wait, check))))
+
+			var out bytes.Buffer
+			taskIter, _, err := NewExecutorIterator(dir, WithIO(nil, &out, &out))
+			require.NoError(t, err)
+			events := taskIter.Events()
+			done := make(chan []task.Poll)
+			go func() {
+				var polls []task.Poll
+				for e := range events {
+					if e, ok := e.(ConditionPolled); ok {
+						polls = append(polls, e.Poll)
+					}
+				}
+				done <- polls
+			}()
+
+			e, err := taskIter.Next()
+			require.NoError(t, err)
+			require.Equal(t, task.StepWait, e.Step().Kind)
+			err = e.Execute(context.Background())
+			taskIter.Close()
+			polls := <-done
+
+			if tt.timeout {
+				var timeoutErr *errors.TaskTimeoutError
+				require.ErrorAs(t, err, &timeoutErr)
+				require.Contains(t, out.String(), "Output of the last check:")
+				require.GreaterOrEqual(t, len(polls), 2)
+				return
+			}
+			require.NoError(t, err)
+			values := make([]string, len(polls))
+			for i, p := range polls {
+				values[i] = p.Value
+			}
+			require.Equal(t, tt.polls, values)
+			require.Equal(t, tt.met, polls[len(polls)-1].Met)
+			require.Empty(t, out.String())
+		})
+	}
+}
+
+func Test_task_Outputs(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, writeTaskFile(dir, `version: '3'
+tasks:
+  default:
+    silent: true
+    cmds:
+      - defer: echo "cleanup $INFRA_ID $REGION"
+      - |
+        echo "INFRA_ID=infra-1" >> "$HYPERDEV_OUTPUT"
+        echo "# comment" >> "$HYPERDEV_OUTPUT"
+        echo "KUBECONFIG=$PWD/kubeconfig" >> "$HYPERDEV_OUTPUT"
+      - echo "INFRA_ID=infra-2" >> "$HYPERDEV_OUTPUT"
+      - echo "$INFRA_ID $REGION"
+`))
+
+	var out bytes.Buffer
+	taskIter, n, err := NewExecutorIterator(dir, WithIO(nil, &out, &out), WithOutputs(map[string]string{"REGION": "us-east-1"}))
+	require.NoError(t, err)
+	events := taskIter.Events()
+	done := make(chan []map[string]string)
+	go func() {
+		var outputs []map[string]string
+		for e := range events {
+			if e, ok := e.(CommandFinished); ok {
+				outputs = append(outputs, e.Outputs)
+			}
+		}
+		done <- outputs
+	}()
+
+	for range n {
+		e, err := taskIter.Next()
+		require.NoError(t, err)
+		require.NoError(t, e.Execute(context.Background()))
+	}
+	file := taskIter.(*_task).OutputsFile
+	taskIter.Close()
+	outputs := <-done
+	require.NoFileExists(t, file)
+
+	require.Equal(t, "infra-2 us-east-1\ncleanup infra-2 us-east-1\n", out.String())
+	require.Equal(t, []map[string]string{
+		{"REGION": "us-east-1", "INFRA_ID": "infra-1", "KUBECONFIG": filepath.Join(dir, "kubeconfig")},
+		{"REGION": "us-east-1", "INFRA_ID": "infra-2", "KUBECONFIG": filepath.Join(dir, "kubeconfig")},
+		{"REGION": "us-east-1", "INFRA_ID": "infra-2", "KUBECONFIG": filepath.Join(dir, "kubeconfig")},
+		{"REGION": "us-east-1", "INFRA_ID": "infra-2", "KUBECONFIG": filepath.Join(dir, "kubeconfig")},
+	}, outputs)
+}
+
+func Test_task_OutputsAreEnvVars(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, writeTaskFile(dir, `version: '3'
+tasks:
+  default:
+    silent: true
+    cmds:
+      - echo "INFRA_ID=infra-1" >> "$HYPERDEV_OUTPUT"
+      - echo "env=$INFRA_ID template={{.INFRA_ID}}"
+`))
+
+	var out bytes.Buffer
+	taskIter, n, err := NewExecutorIterator(dir, WithIO(nil, &out, &out))
+	require.NoError(t, err)
+	defer taskIter.Close()
+	for range n {
+		e, err := taskIter.Next()
+		require.NoError(t, err)
+		require.NoError(t, e.Execute(context.Background()))
+	}
+	// The commands are templated before any of them ran
+	require.Equal(t, "env=infra-1 template=\n", out.String())
+}
+
+func Test_task_SpecialVars(t *testing.T) {
+	dir := t.TempDir()
+	artifacts := t.TempDir()
+	require.NoError(t, writeTaskFile(dir, `version: '3'
+env:
+  KUBECONFIG: "{{.HYPERDEV_ARTIFACTS}}/kubeconfig"
+tasks:
+  default:
+    silent: true
+    cmds:
+      - echo "{{.HYPERDEV_RECIPE}} {{.HYPERDEV_ENV}} {{.HYPERDEV_RUN_ID}}"
+      - echo "$HYPERDEV_RUN_ID $KUBECONFIG"
+`))
+
+	var out bytes.Buffer
+	taskIter, n, err := NewExecutorIterator(dir, WithIO(nil, &out, &out), WithSpecialVars(map[string]string{
+		EnvVar:       "dev",
+		RecipeVar:    "create-cluster",
+		RunIDVar:     "20250301-101500-3fa2",
+		ArtifactsVar: artifacts,
+	}))
+	require.NoError(t, err)
+	defer taskIter.Close()
+	for range n {
+		e, err := taskIter.Next()
+		require.NoError(t, err)
+		require.NoError(t, e.Execute(context.Background()))
+	}
+	require.Equal(t, fmt.Sprintf("create-cluster dev 20250301-101500-3fa2\n20250301-101500-3fa2 %s/kubeconfig\n", artifacts), out.String())
+}
+
+func Test_ListTasksAndEnvNames(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "taskfile.yaml"), []byte(`version: '3'
+env:
+  CLUSTER_NAME: dev
+  REGION: us-east-1
+tasks:
+  default:
+    desc: Create the cluster
+    aliases: [create]
+    cmds:
+      - task: wait
+  wait:
+    env:
+      REGION: eu-west-1
+      REPLICAS: 3
+    cmds:
+      - echo waiting
+  helper:
+    internal: true
+    cmds:
+      - echo hidden
+`), 0o644))
+
+	tf, err := ListTasks(dir)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "taskfile.yaml"), tf.Location)
+	require.Len(t, tf.Tasks, 2)
+	require.Equal(t, "default", tf.Tasks[0].Name)
+	require.Equal(t, "Create the cluster", tf.Tasks[0].Desc)
+	require.Equal(t, []string{"create"}, tf.Tasks[0].Aliases)
+	require.Equal(t, 6, tf.Tasks[0].Location.Line)
+	require.Equal(t, "wait", tf.Tasks[1].Name)
+
+	names, err := EnvNames(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"CLUSTER_NAME", "REGION", "REPLICAS"}, names)
+
+	_, err = ListTasks(t.TempDir())
+	require.Error(t, err)
+}
@@ -0,0 +1,296 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package taskexec
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hypershift-community/hyper-console/pkg/task"
+)
+
+// NodeStatus is the status of a node of the step [Tree].
+type NodeStatus int
+
+const (
+	NodePending NodeStatus = iota
+	NodeRunning
+	NodeSucceeded
+	NodeFailed
+	// NodeSkipped is the status of the steps that were skipped by the user or that
+	// didn't run because a dependency running in parallel failed.
+	NodeSkipped
+	// NodeUpToDate is the status of the steps that didn't run because their task is
+	// up-to-date.
+	NodeUpToDate
+)
+
+func (s NodeStatus) String() string {
+	return [...]string{"pending", "running", "succeeded", "failed", "skipped", "up-to-date"}[s]
+}
+
+// Done returns true if the node reached a final status.
+func (s NodeStatus) Done() bool {
+	return s >= NodeSucceeded
+}
+
+// Node is a node of the step [Tree]. The steps of the task are the roots of the
+// tree, the steps of the tasks they call are their children. Dependencies are
+// represented by one child per dependency, with Parallel set, whose children are
+// the steps of the dependency.
+type Node struct {
+	ID         int
+	Label      string
+	Kind       task.StepKind
+	Status     NodeStatus
+	StartedAt  time.Time
+	FinishedAt time.Time
+	// Parallel is true for the nodes that run concurrently with their siblings.
+	Parallel bool
+	// Attempt is the attempt of a command being retried, out of Attempts. Both are
+	// 0 for the commands which weren't retried.
+	Attempt  int
+	Attempts int
+	Err      error
+	Children []*Node
+
+	parent *Node
+	output bytes.Buffer
+}
+
+// Duration returns how long the node ran, or has been running so far.
+func (n *Node) Duration() time.Duration {
+	switch {
+	case n.StartedAt.IsZero():
+		return 0
+	case n.FinishedAt.IsZero():
+		return time.Since(n.StartedAt)
+	default:
+		return n.FinishedAt.Sub(n.StartedAt)
+	}
+}
+
+// Tree is the live tree of the steps of a task, including the steps of the tasks it
+// calls and depends on. It is updated as the steps run and is safe for concurrent use.
+type Tree struct {
+	mu    sync.Mutex
+	roots []*Node
+	nodes []*Node
+	steps map[*task.PlanStep]*Node
+	plans map[*task.Plan]*Node
+
+	// out is where the output of the nested steps is copied to, on top of their nodes.
+	outMu sync.Mutex
+	out   func() (stdout, stderr io.Writer)
+}
+
+func newTree(plan *task.Plan, out func() (stdout, stderr io.Writer)) *Tree {
+	tree := &Tree{
+		steps: make(map[*task.PlanStep]*Node),
+		plans: make(map[*task.Plan]*Node),
+		out:   out,
+	}
+	tree.roots = tree.addPlan(plan, nil)
+	return tree
+}
+
+func (t *Tree) newNode(parent *Node, label string, kind task.StepKind) *Node {
+	n := &Node{ID: len(t.nodes), Label: label, Kind: kind, parent: parent}
+	t.nodes = append(t.nodes, n)
+	if parent != nil {
+		parent.Children = append(parent.Children, n)
+	}
+	return n
+}
+
+func (t *Tree) addPlan(plan *task.Plan, parent *Node) []*Node {
+	var nodes []*Node
+	for _, s := range plan.Steps {
+		n := t.newNode(parent, s.String(), s.Kind)
+		t.steps[s] = n
+		nodes = append(nodes, n)
+		if s.Sub != nil {
+			t.addPlan(s.Sub, n)
+		}
+		for _, sub := range s.Subs {
+			dep := t.newNode(n, "task: "+sub.Call.Task, task.StepTask)
+			dep.Parallel = len(s.Subs) > 1
+			t.plans[sub] = dep
+			t.addPlan(sub, dep)
+		}
+	}
+	return nodes
+}
+
+// Roots returns a copy of the top-level nodes of the tree, with their children.
+func (t *Tree) Roots() []*Node {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return copyNodes(t.roots, nil)
+}
+
+func copyNodes(nodes []*Node, parent *Node) []*Node {
+	out := make([]*Node, len(nodes))
+	for i, n := range nodes {
+		c := &Node{
+			ID:         n.ID,
+			Label:      n.Label,
+			Kind:       n.Kind,
+			Status:     n.Status,
+			StartedAt:  n.StartedAt,
+			FinishedAt: n.FinishedAt,
+			Parallel:   n.Parallel,
+			Attempt:    n.Attempt,
+			Attempts:   n.Attempts,
+			Err:        n.Err,
+			parent:     parent,
+		}
+		c.Children = copyNodes(n.Children, c)
+		out[i] = c
+	}
+	return out
+}
+
+// Output returns the output of the node with the given ID, including the output of
+// its children.
+func (t *Tree) Output(id int) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if id < 0 || id >= len(t.nodes) {
+		return ""
+	}
+	return t.nodes[id].output.String()
+}
+
+// Skip marks a top-level step, and all its children, as skipped.
+func (t *Tree) Skip(s *task.PlanStep) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n, ok := t.steps[s]; ok {
+		finish(n, NodeSkipped, nil)
+	}
+}
+
+// StepStarted implements [task.PlanObserver].
+func (t *Tree) StepStarted(p *task.Plan, s *task.PlanStep) (io.Writer, io.Writer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n, ok := t.steps[s]
+	if !ok {
+		return nil, nil
+	}
+	now := time.Now()
+	for a := n; a != nil && a.Status == NodePending; a = a.parent {
+		a.Status = NodeRunning
+		a.StartedAt = now
+	}
+	stdout, stderr := t.out()
+	return &nodeWriter{tree: t, node: n, out: stdout}, &nodeWriter{tree: t, node: n, out: stderr}
+}
+
+// StepFinished implements [task.PlanObserver].
+func (t *Tree) StepFinished(p *task.Plan, s *task.PlanStep, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n, ok := t.steps[s]
+	if !ok {
+		return
+	}
+	status := NodeSucceeded
+	switch {
+	case err != nil:
+		status = NodeFailed
+	case p.Skip(s):
+		status = NodeUpToDate
+	}
+	finish(n, status, err)
+	if dep, ok := t.plans[p]; ok && err == nil && s == p.Steps[len(p.Steps)-1] {
+		// The last step completes the dependency. A failed dependency is completed
+		// along with the deps step once all the dependencies returned.
+		finish(dep, NodeSucceeded, nil)
+	}
+}
+
+// StepRetrying implements [task.RetryObserver].
+func (t *Tree) StepRetrying(p *task.Plan, s *task.PlanStep, a task.Attempt) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n, ok := t.steps[s]; ok {
+		n.Attempt = a.Number + 1
+		n.Attempts = a.Attempts
+	}
+}
+
+// finish sets the final status of a node. The children that didn't get to run are
+// marked as skipped, or up-to-date if the node is, and the ones still running get
+// the status of the node unless one of their own steps failed.
+func finish(n *Node, status NodeStatus, err error) {
+	now := time.Now()
+	if n.Status.Done() {
+		return
+	}
+	n.Status = status
+	n.Err = err
+	if n.StartedAt.IsZero() {
+		n.StartedAt = now
+	}
+	n.FinishedAt = now
+	for _, c := range n.Children {
+		switch {
+		case c.Status.Done():
+		case c.Status == NodeRunning && failed(c):
+			finish(c, NodeFailed, nil)
+		case c.Status == NodeRunning:
+			finish(c, status, nil)
+		case status == NodeUpToDate:
+			finish(c, NodeUpToDate, nil)
+		default:
+			finish(c, NodeSkipped, nil)
+		}
+	}
+}
+
+// failed returns true if any of the descendants of the node failed.
+func failed(n *Node) bool {
+	for _, c := range n.Children {
+		if c.Status == NodeFailed || failed(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeWriter writes the output of a step to its node and its ancestors, so that
+// focusing on a task shows the output of all its steps, as well as to the writer
+// of the executor.
+type nodeWriter struct {
+	tree *Tree
+	node *Node
+	out  io.Writer
+}
+
+func (w *nodeWriter) Write(p []byte) (int, error) {
+	w.tree.mu.Lock()
+	for n := w.node; n != nil; n = n.parent {
+		n.output.Write(p)
+	}
+	w.tree.mu.Unlock()
+	// The executor's writer may block, e.g. on a pipe, so don't hold the tree lock
+	w.tree.outMu.Lock()
+	defer w.tree.outMu.Unlock()
+	return w.out.Write(p)
+}
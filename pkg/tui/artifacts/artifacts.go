@@ -0,0 +1,206 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package artifacts
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/atotto/clipboard"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/muesli/termenv"
+
+	"github.com/hypershift-community/hyper-console/pkg/history"
+	"github.com/hypershift-community/hyper-console/pkg/logging"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/keys"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/navigation"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/simplelist"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/styles"
+)
+
+var (
+	Logger = logging.Logger
+
+	OpenKey     = keys.NewCustomKey("Open", "enter", "Open the selected artifact with the default application")
+	CopyPathKey = keys.NewCustomKey("Copy path", "c", "Copy the path of the selected artifact to the clipboard")
+)
+
+// ShowMessage asks for the artifacts of a run to be shown.
+type ShowMessage struct {
+	RunID string
+	Dir   string
+}
+
+type artifactsLoadedMessage []history.Artifact
+
+// Model lists the artifacts of a run with their size.
+type Model struct {
+	list        list.Model
+	dir         string
+	artifacts   []history.Artifact
+	keyMap      *keys.KeyMap
+	initialized bool
+	err         error
+	// status reports the outcome of the last action on an artifact.
+	status string
+}
+
+func New(windowWidth int, windowHeight int, msg ShowMessage) tea.Model {
+	defaultStyles := styles.DefaultStyles()
+	keyMap := keys.NewKeyMap().
+		WithKey(keys.Up, false).
+		WithKey(keys.Down, false).
+		WithKey(OpenKey, true).
+		WithKey(CopyPathKey, true).
+		WithKey(keys.Cancel, false).
+		WithKey(keys.Quit, false)
+
+	l := simplelist.NewList(keyMap, &defaultStyles, windowWidth, windowHeight)
+
+	l.Title = fmt.Sprintf("Artifacts of run %s", msg.RunID)
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(false)
+	l.Styles.PaginationStyle = defaultStyles.Pagination
+	l.Styles.HelpStyle = defaultStyles.Help
+
+	return &Model{
+		list:   l,
+		dir:    msg.Dir,
+		keyMap: keyMap,
+	}
+}
+
+func (m *Model) Init() tea.Cmd {
+	return func() tea.Msg {
+		artifacts, err := history.ListArtifacts(m.dir)
+		if err != nil {
+			m.err = err
+			return nil
+		}
+		return artifactsLoadedMessage(artifacts)
+	}
+}
+
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.list.SetWidth(msg.Width)
+		m.list.SetHeight(msg.Height)
+		return m, nil
+	case tea.KeyMsg:
+		switch {
+		case m.keyMap.Matches(msg, OpenKey):
+			m.open()
+		case m.keyMap.Matches(msg, CopyPathKey):
+			m.copyPath()
+		case m.keyMap.Matches(msg, keys.Cancel):
+			return m, navigation.Back()
+		}
+	case artifactsLoadedMessage:
+		m.artifacts = msg
+		items := make([]list.Item, len(m.artifacts))
+		for i, a := range m.artifacts {
+			items[i] = &simplelist.Item{Name: a.Path, Description: FormatSize(a.Size)}
+		}
+		m.list.SetItems(items)
+		m.initialized = true
+	}
+
+	m.list, cmd = m.list.Update(msg)
+	cmds = append(cmds, cmd)
+	return m, tea.Batch(cmds...)
+}
+
+func (m *Model) View() string {
+	if len(m.artifacts) == 0 {
+		if m.err != nil {
+			return "\nError listing artifacts: " + m.err.Error()
+		}
+		if m.initialized {
+			return "\nNo artifacts in " + m.dir
+		}
+		return "\nLoading artifacts..."
+	}
+	if m.status != "" {
+		return "\n" + m.list.View() + "\n" + m.status
+	}
+	return "\n" + m.list.View()
+}
+
+func (m *Model) selected() (string, bool) {
+	if len(m.artifacts) == 0 {
+		return "", false
+	}
+	return filepath.Join(m.dir, m.artifacts[m.list.Cursor()].Path), true
+}
+
+// open opens the selected artifact with the default application of the desktop.
+func (m *Model) open() {
+	path, ok := m.selected()
+	if !ok {
+		return
+	}
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", path)
+	case "windows":
+		cmd = exec.Command("cmd", "/c", "start", "", path)
+	default:
+		cmd = exec.Command("xdg-open", path)
+	}
+	if err := cmd.Start(); err != nil {
+		m.status = fmt.Sprintf("Unable to open %s: %s", path, err)
+		return
+	}
+	go func() {
+		_ = cmd.Wait()
+	}()
+	m.status = "Opened " + path
+}
+
+// copyPath copies the path of the selected artifact to the system clipboard, or
+// through the terminal when there is none, e.g. over SSH.
+func (m *Model) copyPath() {
+	path, ok := m.selected()
+	if !ok {
+		return
+	}
+	if err := clipboard.WriteAll(path); err != nil {
+		Logger.Debug("No system clipboard, copying through the terminal", "error", err)
+		termenv.Copy(path)
+	}
+	m.status = "Copied " + path
+}
+
+// FormatSize formats a size in bytes with a binary unit.
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
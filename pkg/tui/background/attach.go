@@ -0,0 +1,283 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package background
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/history"
+	"github.com/hypershift-community/hyper-console/pkg/recipes"
+	"github.com/hypershift-community/hyper-console/pkg/supervisor"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/keys"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/navigation"
+)
+
+var (
+	titleStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("63"))
+	helpStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+
+	statusStyles = map[history.Status]lipgloss.Style{
+		history.StatusRunning:   lipgloss.NewStyle().Foreground(lipgloss.Color("63")),
+		history.StatusSucceeded: lipgloss.NewStyle().Foreground(lipgloss.Color("42")),
+		history.StatusFailed:    lipgloss.NewStyle().Foreground(lipgloss.Color("196")),
+		history.StatusAborted:   lipgloss.NewStyle().Foreground(lipgloss.Color("214")),
+	}
+)
+
+// startedMessage is sent once the run was started in the supervisor.
+type startedMessage struct {
+	info supervisor.RunInfo
+	err  error
+}
+
+// outputMessage is a chunk of the output of the run.
+type outputMessage string
+
+// endedMessage is sent once the run ended, or the output couldn't be streamed.
+type endedMessage struct {
+	info supervisor.RunInfo
+	err  error
+	// pending is the number of changes to its environment the run left pending.
+	pending int
+}
+
+type attachModel struct {
+	cfg    *config.Config
+	client *supervisor.Client
+	// recipe is the recipe to start, nil when attaching to a run started before.
+	recipe   *recipes.Recipe
+	info     supervisor.RunInfo
+	output   strings.Builder
+	events   chan tea.Msg
+	cancel   context.CancelFunc
+	viewport viewport.Model
+	keyMap   *keys.KeyMap
+	done     bool
+	err      error
+	// status reports the outcome of the last action on the run.
+	status string
+	// pending is the number of changes to its environment the run left pending.
+	pending int
+}
+
+// NewStart creates the view starting the recipe in the supervisor, starting the
+// supervisor if needed, and attaching to its output.
+func NewStart(width, height int, recipe recipes.Recipe, cfg *config.Config) tea.Model {
+	m := newAttach(width, height, cfg)
+	m.recipe = &recipe
+	m.info = supervisor.RunInfo{Recipe: recipe.Name, Environment: recipe.Environment}
+	return m
+}
+
+// NewAttach creates the view attached to the output of a run of the supervisor.
+func NewAttach(width, height int, id string, cfg *config.Config) tea.Model {
+	m := newAttach(width, height, cfg)
+	m.info = supervisor.RunInfo{ID: id}
+	return m
+}
+
+func newAttach(width, height int, cfg *config.Config) *attachModel {
+	return &attachModel{
+		cfg:      cfg,
+		client:   supervisor.NewClient(supervisor.Socket(cfg)),
+		viewport: viewport.New(width, max(height-4, 1)),
+		keyMap: keys.NewViewportKeyMap().
+			WithKey(AbortKey, true).
+			WithKey(keys.ForceQuit, false),
+	}
+}
+
+func (m *attachModel) Init() tea.Cmd {
+	if m.recipe == nil {
+		return m.attach()
+	}
+	recipe := *m.recipe
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		c, err := supervisor.Ensure(ctx, m.cfg)
+		if err != nil {
+			return startedMessage{err: err}
+		}
+		info, err := c.Start(ctx, recipe.Name, recipe.Environment, nil)
+		return startedMessage{info: info, err: err}
+	}
+}
+
+// attach streams the output of the run until it ends or the view detaches.
+func (m *attachModel) attach() tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	events := make(chan tea.Msg, 64)
+	m.events = events
+	id := m.info.ID
+	go func() {
+		defer close(events)
+		info, err := m.client.Attach(ctx, id, writerFunc(func(p []byte) (int, error) {
+			select {
+			case events <- outputMessage(p):
+				return len(p), nil
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		}))
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		events <- endedMessage{info: info, err: err, pending: m.pendingEnvChanges(info.ID)}
+	}()
+	return m.waitForEvents()
+}
+
+func (m *attachModel) waitForEvents() tea.Cmd {
+	events := m.events
+	return func() tea.Msg {
+		return <-events
+	}
+}
+
+func (m *attachModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.viewport.Width = msg.Width
+		m.viewport.Height = max(msg.Height-4, 1)
+		return m, nil
+	case startedMessage:
+		if msg.err != nil {
+			m.err = msg.err
+			m.done = true
+			return m, nil
+		}
+		m.info = msg.info
+		return m, m.attach()
+	case outputMessage:
+		atBottom := m.viewport.AtBottom()
+		m.output.WriteString(string(msg))
+		m.viewport.SetContent(m.output.String())
+		if atBottom {
+			m.viewport.GotoBottom()
+		}
+		return m, m.waitForEvents()
+	case endedMessage:
+		m.done = true
+		m.err = msg.err
+		if msg.err == nil {
+			m.info = msg.info
+			m.pending = msg.pending
+		}
+		return m, nil
+	case tea.KeyMsg:
+		return m, m.handleKeys(msg)
+	}
+	return m, nil
+}
+
+func (m *attachModel) handleKeys(msg tea.KeyMsg) tea.Cmd {
+	switch {
+	case m.keyMap.Matches(msg, keys.Quit) || m.keyMap.Matches(msg, keys.ForceQuit):
+		// The run keeps running in the supervisor
+		m.detach()
+		return tea.Quit
+	case m.keyMap.Matches(msg, keys.Cancel):
+		m.detach()
+		return navigation.Back()
+	case m.keyMap.Matches(msg, AbortKey):
+		if m.done || m.info.ID == "" {
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if _, err := m.client.Abort(ctx, m.info.ID); err != nil {
+			m.status = "Unable to abort the run: " + err.Error()
+			return nil
+		}
+		m.status = "The run is aborted, its deferred commands still run."
+		return nil
+	}
+	var cmd tea.Cmd
+	m.viewport, cmd = m.viewport.Update(msg)
+	return cmd
+}
+
+// pendingEnvChanges returns the number of changes to its environment the run left
+// pending, the supervisor doesn't apply them.
+func (m *attachModel) pendingEnvChanges(id string) int {
+	if m.cfg.HistoryDir == "" || id == "" {
+		return 0
+	}
+	r, err := history.NewStore(m.cfg.HistoryDir).Get(id)
+	if err != nil {
+		Logger.Warn("Error reading run history", "run", id, "error", err)
+		return 0
+	}
+	return len(r.EnvPending)
+}
+
+func (m *attachModel) detach() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+}
+
+func (m *attachModel) View() string {
+	var sb strings.Builder
+	title := m.info.Recipe
+	if m.info.Environment != "" {
+		title += " @ " + m.info.Environment
+	}
+	if title == "" {
+		title = m.info.ID
+	}
+	sb.WriteString("\n" + titleStyle.Render("Background run "+title) + "\n")
+	sb.WriteString(m.viewport.View() + "\n")
+	switch {
+	case m.err != nil:
+		sb.WriteString(errorStyle.Render(m.err.Error()))
+	case m.done:
+		status := statusStyles[m.info.Status].Render(string(m.info.Status))
+		sb.WriteString(fmt.Sprintf("Run %s %s in %s", m.info.ID, status, m.info.Duration().Round(time.Second)))
+		if m.info.Error != "" {
+			sb.WriteString(": " + errorStyle.Render(m.info.Error))
+		}
+		if m.pending > 0 {
+			sb.WriteString(fmt.Sprintf("\n%d change(s) to environment %s are pending, review and apply them from the run history.", m.pending, m.info.Environment))
+		}
+	case m.info.ID == "":
+		sb.WriteString("Starting the run in the supervisor...")
+	case m.status != "":
+		sb.WriteString(m.status)
+	default:
+		sb.WriteString(fmt.Sprintf("Run %s is running in the supervisor, it keeps running after leaving or quitting.", m.info.ID))
+	}
+	sb.WriteString("\n" + helpStyle.Render("↑/↓ scroll • x abort • esc detach"))
+	return sb.String()
+}
+
+// writerFunc is an io.Writer calling the function.
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
@@ -0,0 +1,215 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package background shows the runs of the supervisor, which keep running after
+// the TUI quits, and attaches to their output.
+package background
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/logging"
+	"github.com/hypershift-community/hyper-console/pkg/supervisor"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/keys"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/navigation"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/simplelist"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/styles"
+)
+
+var (
+	Logger = logging.Logger
+
+	AbortKey = keys.NewCustomKey("Abort", "x", "Abort the selected run, its deferred commands still run")
+)
+
+// refreshInterval is how often the runs are listed again.
+const refreshInterval = 2 * time.Second
+
+// AttachMessage asks for the output of a run of the supervisor.
+type AttachMessage struct {
+	ID string
+}
+
+type runsLoadedMessage struct {
+	runs []supervisor.RunInfo
+	err  error
+}
+
+type tickMessage time.Time
+
+// Model lists the runs of the supervisor.
+type Model struct {
+	list        list.Model
+	cfg         *config.Config
+	client      *supervisor.Client
+	runs        []supervisor.RunInfo
+	keyMap      *keys.KeyMap
+	initialized bool
+	err         error
+	// status reports the outcome of the last action on a run.
+	status string
+}
+
+func New(windowWidth int, windowHeight int, cfg *config.Config) tea.Model {
+	defaultStyles := styles.DefaultStyles()
+	keyMap := keys.NewListKeyMap().
+		WithKey(AbortKey, true).
+		WithKey(keys.Cancel, false)
+
+	l := simplelist.NewList(keyMap, &defaultStyles, windowWidth, windowHeight)
+
+	l.Title = "Background Runs"
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(false)
+	l.Styles.PaginationStyle = defaultStyles.Pagination
+	l.Styles.HelpStyle = defaultStyles.Help
+
+	return &Model{
+		list:   l,
+		cfg:    cfg,
+		client: supervisor.NewClient(supervisor.Socket(cfg)),
+		keyMap: keyMap,
+	}
+}
+
+func (m *Model) Init() tea.Cmd {
+	return m.loadRuns()
+}
+
+// loadRuns lists the runs. The supervisor isn't started just to list them, there
+// are no runs if it isn't running.
+func (m *Model) loadRuns() tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		runs, err := m.client.List(ctx)
+		return runsLoadedMessage{runs: runs, err: err}
+	}
+}
+
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.list.SetWidth(msg.Width)
+		m.list.SetHeight(msg.Height)
+		return m, nil
+	case tea.KeyMsg:
+		switch {
+		case m.keyMap.Matches(msg, keys.Enter):
+			cmd = m.attachCmd()
+		case m.keyMap.Matches(msg, AbortKey):
+			cmd = m.abortCmd()
+		case m.keyMap.Matches(msg, keys.Cancel):
+			return m, navigation.Back()
+		}
+		cmds = append(cmds, cmd)
+	case runsLoadedMessage:
+		m.runs, m.err = msg.runs, msg.err
+		m.setItems()
+		if !m.initialized {
+			m.initialized = true
+			cmds = append(cmds, tick())
+		}
+	case tickMessage:
+		return m, tea.Batch(m.loadRuns(), tick())
+	}
+
+	m.list, cmd = m.list.Update(msg)
+	cmds = append(cmds, cmd)
+	return m, tea.Batch(cmds...)
+}
+
+func tick() tea.Cmd {
+	return tea.Tick(refreshInterval, func(t time.Time) tea.Msg {
+		return tickMessage(t)
+	})
+}
+
+func (m *Model) View() string {
+	if len(m.runs) == 0 {
+		switch {
+		case m.err != nil:
+			return "\nThe supervisor isn't running. Running a recipe in the background (ctrl+g on a recipe) starts it."
+		case m.initialized:
+			return "\nNo runs in the background."
+		}
+		return "\nLoading background runs..."
+	}
+	if m.status != "" {
+		return "\n" + m.list.View() + "\n" + m.status
+	}
+	return "\n" + m.list.View()
+}
+
+func (m *Model) setItems() {
+	items := make([]list.Item, len(m.runs))
+	for i, r := range m.runs {
+		items[i] = &simplelist.Item{Name: runTitle(r), Description: runDescription(r)}
+	}
+	m.list.SetItems(items)
+}
+
+func runTitle(r supervisor.RunInfo) string {
+	if r.Environment == "" {
+		return r.Recipe
+	}
+	return r.Recipe + " @ " + r.Environment
+}
+
+func runDescription(r supervisor.RunInfo) string {
+	desc := string(r.Status)
+	if r.Steps > 0 {
+		desc += fmt.Sprintf(", step %d/%d", r.Step+1, r.Steps)
+	}
+	return fmt.Sprintf("%s, %s, %s", desc, r.Duration().Round(time.Second), r.ID)
+}
+
+func (m *Model) attachCmd() tea.Cmd {
+	if len(m.runs) == 0 {
+		return nil
+	}
+	id := m.runs[m.list.Cursor()].ID
+	m.status = ""
+	return func() tea.Msg {
+		return AttachMessage{ID: id}
+	}
+}
+
+func (m *Model) abortCmd() tea.Cmd {
+	if len(m.runs) == 0 {
+		return nil
+	}
+	r := m.runs[m.list.Cursor()]
+	if !r.Active() {
+		m.status = "The selected run isn't running."
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := m.client.Abort(ctx, r.ID); err != nil {
+		m.status = "Unable to abort the run: " + err.Error()
+		return nil
+	}
+	m.status = "The run is aborted, its deferred commands still run."
+	return m.loadRuns()
+}
@@ -16,6 +16,8 @@ package environments
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
@@ -30,12 +32,25 @@ import (
 	"github.com/hypershift-community/hyper-console/pkg/tui/lib/styles"
 )
 
-var Logger = logging.Logger
+var (
+	Logger = logging.Logger
+
+	ToggleKey          = keys.NewCustomKey("Toggle", " ", "Select the environment for the batch run, or unselect it")
+	MoreParallelismKey = keys.NewCustomKey("More parallel", "+", "Run the recipe in more environments at the same time")
+	LessParallelismKey = keys.NewCustomKey("Less parallel", "-", "Run the recipe in fewer environments at the same time")
+)
 
 type SelectMessage struct {
 	Environment string
 }
 
+// BatchMessage is sent once the environments of a batch run are selected.
+type BatchMessage struct {
+	Recipe       recipes.Recipe
+	Environments []string
+	Parallelism  int
+}
+
 type envsLoadedMessage map[string]*env.Env
 
 type Model struct {
@@ -46,6 +61,10 @@ type Model struct {
 	keyMap      *keys.KeyMap
 	initialized bool
 	err         error
+	// multi is set when selecting several environments for a batch run.
+	multi       bool
+	selected    map[string]bool
+	parallelism int
 }
 
 func New(windowWidth int, windowHeight int, recipe *recipes.Recipe, cfg *config.Config) tea.Model {
@@ -68,6 +87,28 @@ func New(windowWidth int, windowHeight int, recipe *recipes.Recipe, cfg *config.
 	}
 }
 
+// NewMulti creates a view selecting several environments to run the recipe in as a
+// batch, one after the other or some of them at the same time.
+func NewMulti(windowWidth int, windowHeight int, recipe *recipes.Recipe, cfg *config.Config) tea.Model {
+	m := New(windowWidth, windowHeight, recipe, cfg).(*Model)
+	m.multi = true
+	m.selected = make(map[string]bool)
+	m.parallelism = 1
+	m.keyMap.
+		WithKey(ToggleKey, true).
+		WithKey(MoreParallelismKey, true).
+		WithKey(LessParallelismKey, true).
+		WithKey(keys.Cancel, false)
+	m.setTitle()
+	return m
+}
+
+func (m *Model) setTitle() {
+	if m.multi {
+		m.list.Title = fmt.Sprintf("Batch run recipe %s in %d environment(s), %d at a time", m.recipe.Name, len(m.selected), m.parallelism)
+	}
+}
+
 func (m *Model) Init() tea.Cmd {
 	return func() tea.Msg {
 		Logger.Debug("Loading environments")
@@ -92,27 +133,28 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 	case tea.KeyMsg:
 		switch {
+		case m.multi && m.keyMap.Matches(msg, keys.Enter):
+			cmd = m.batchCmd()
 		case m.keyMap.Matches(msg, keys.Enter):
 			cmd = m.getSelectedCmd()
+		case m.multi && m.keyMap.Matches(msg, ToggleKey):
+			m.toggle()
+			return m, nil
+		case m.multi && m.keyMap.Matches(msg, MoreParallelismKey):
+			m.parallelism = min(m.parallelism+1, max(len(m.envs), 1))
+			m.setTitle()
+			return m, nil
+		case m.multi && m.keyMap.Matches(msg, LessParallelismKey):
+			m.parallelism = max(m.parallelism-1, 1)
+			m.setTitle()
+			return m, nil
 		case m.keyMap.Matches(msg, keys.Cancel):
 			return m, navigation.Back()
 		}
 		cmds = append(cmds, cmd)
 	case envsLoadedMessage:
-		envs := msg
-		items := make([]list.Item, len(envs))
-		i := 0
-		for n, e := range envs {
-			//TODO: this means we know that simplelist.Item is a list.Item. This is not ideal and should be fixed.
-			item := simplelist.Item{Name: n}
-			if e.Description != "" {
-				item.Description = e.Description
-			}
-			items[i] = &item
-			i++
-		}
-		m.list.SetItems(items)
-		m.envs = envs
+		m.envs = msg
+		m.setItems()
 		m.initialized = true
 	}
 
@@ -148,3 +190,62 @@ func (m *Model) getSelectedCmd() tea.Cmd {
 		return SelectMessage{Environment: envName}
 	}
 }
+
+// setItems lists the environments by name. In a batch selection, the selected ones
+// are checked.
+func (m *Model) setItems() {
+	names := slices.Sorted(maps.Keys(m.envs))
+	items := make([]list.Item, len(names))
+	for i, n := range names {
+		//TODO: this means we know that simplelist.Item is a list.Item. This is not ideal and should be fixed.
+		item := simplelist.Item{Name: n, Description: m.envs[n].Description}
+		if m.multi {
+			check := "[ ] "
+			if m.selected[n] {
+				check = "[x] "
+			}
+			item.Name = check + n
+		}
+		items[i] = &item
+	}
+	m.list.SetItems(items)
+}
+
+func (m *Model) cursorEnv() (string, bool) {
+	names := slices.Sorted(maps.Keys(m.envs))
+	if len(names) == 0 {
+		return "", false
+	}
+	return names[m.list.Cursor()], true
+}
+
+func (m *Model) toggle() {
+	name, ok := m.cursorEnv()
+	if !ok {
+		return
+	}
+	if m.selected[name] {
+		delete(m.selected, name)
+	} else {
+		m.selected[name] = true
+	}
+	m.setItems()
+	m.setTitle()
+}
+
+// batchCmd starts the batch run in the selected environments, or in the one under
+// the cursor if none is selected.
+func (m *Model) batchCmd() tea.Cmd {
+	envs := slices.Sorted(maps.Keys(m.selected))
+	if len(envs) == 0 {
+		name, ok := m.cursorEnv()
+		if !ok {
+			return nil
+		}
+		envs = []string{name}
+	}
+	msg := BatchMessage{Recipe: *m.recipe, Environments: envs, Parallelism: m.parallelism}
+	return func() tea.Msg {
+		return msg
+	}
+}
@@ -0,0 +1,289 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package history
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/env"
+	"github.com/hypershift-community/hyper-console/pkg/history"
+	"github.com/hypershift-community/hyper-console/pkg/logging"
+	"github.com/hypershift-community/hyper-console/pkg/tui/artifacts"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/keys"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/navigation"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/simplelist"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/styles"
+	"github.com/hypershift-community/hyper-console/pkg/tui/recipes/run"
+)
+
+var (
+	Logger = logging.Logger
+
+	RetryKey     = keys.NewCustomKey("Retry failed step", "r", "Retry the failed step of the selected run")
+	ResumeKey    = keys.NewCustomKey("Resume from step", "enter", "Pick a step to resume the selected run from")
+	RevertKey    = keys.NewCustomKey("Revert env changes", "v", "Revert the changes the selected run made to its environment")
+	ApplyKey     = keys.NewCustomKey("Apply env changes", "w", "Apply the changes the selected run left pending to its environment")
+	ArtifactsKey = keys.NewCustomKey("Artifacts", "f", "Browse the files the commands of the selected run generated")
+)
+
+type runsLoadedMessage []*history.Run
+
+type Model struct {
+	list        list.Model
+	cfg         *config.Config
+	store       *history.Store
+	runs        []*history.Run
+	keyMap      *keys.KeyMap
+	initialized bool
+	err         error
+	// status reports the outcome of the last action on a run.
+	status string
+	// confirming is the run whose pending environment changes are shown, to be
+	// applied when ApplyKey is pressed again.
+	confirming *history.Run
+}
+
+func New(windowWidth int, windowHeight int, cfg *config.Config) tea.Model {
+	defaultStyles := styles.DefaultStyles()
+	keyMap := keys.NewListKeyMap().
+		WithKey(ResumeKey, true).
+		WithKey(RetryKey, true).
+		WithKey(RevertKey, true).
+		WithKey(ApplyKey, true).
+		WithKey(ArtifactsKey, true).
+		WithKey(keys.Cancel, false)
+
+	l := simplelist.NewList(keyMap, &defaultStyles, windowWidth, windowHeight)
+
+	l.Title = "Run History"
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(false)
+	l.Styles.PaginationStyle = defaultStyles.Pagination
+	l.Styles.HelpStyle = defaultStyles.Help
+
+	return &Model{
+		list:   l,
+		cfg:    cfg,
+		store:  history.NewStore(cfg.HistoryDir),
+		keyMap: keyMap,
+	}
+}
+
+func (m *Model) Init() tea.Cmd {
+	return func() tea.Msg {
+		Logger.Debug("Loading run history")
+		runs, err := m.store.List()
+		if err != nil {
+			m.err = err
+			return nil
+		}
+		return runsLoadedMessage(runs)
+	}
+}
+
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+
+	case tea.WindowSizeMsg:
+		m.list.SetWidth(msg.Width)
+		m.list.SetHeight(msg.Height)
+		return m, nil
+	case tea.KeyMsg:
+		if !m.keyMap.Matches(msg, ApplyKey) {
+			m.confirming = nil
+		}
+		switch {
+		case m.keyMap.Matches(msg, ResumeKey):
+			cmd = m.resumeCmd(false)
+		case m.keyMap.Matches(msg, RetryKey):
+			cmd = m.resumeCmd(true)
+		case m.keyMap.Matches(msg, RevertKey):
+			m.revertEnvChanges()
+		case m.keyMap.Matches(msg, ApplyKey):
+			m.applyEnvChanges()
+		case m.keyMap.Matches(msg, ArtifactsKey):
+			cmd = m.artifactsCmd()
+		case m.keyMap.Matches(msg, keys.Cancel):
+			return m, navigation.Back()
+		}
+		cmds = append(cmds, cmd)
+	case runsLoadedMessage:
+		m.runs = msg
+		m.setItems()
+		m.initialized = true
+	}
+
+	m.list, cmd = m.list.Update(msg)
+	cmds = append(cmds, cmd)
+	return m, tea.Batch(cmds...)
+}
+
+func (m *Model) View() string {
+	if len(m.runs) == 0 {
+		if m.err != nil {
+			return "\nError loading run history: " + m.err.Error()
+		}
+		if m.initialized {
+			return "\nNo runs found in " + m.cfg.HistoryDir
+		}
+		return "\nLoading run history..."
+	}
+	if m.status != "" {
+		return "\n" + m.list.View() + "\n" + m.status
+	}
+	return "\n" + m.list.View()
+}
+
+func (m *Model) setItems() {
+	items := make([]list.Item, len(m.runs))
+	for i, r := range m.runs {
+		items[i] = &simplelist.Item{Name: runTitle(r), Description: runDescription(r)}
+	}
+	m.list.SetItems(items)
+}
+
+// revertEnvChanges reverts the changes the selected run applied to its environment.
+// Nothing is reverted if the environment was modified since.
+func (m *Model) revertEnvChanges() {
+	if len(m.runs) == 0 {
+		return
+	}
+	r := m.runs[m.list.Cursor()]
+	if len(r.EnvChanges) == 0 || r.EnvReverted {
+		m.status = "The selected run has no environment changes to revert."
+		return
+	}
+	if err := env.Revert(filepath.Join(m.cfg.EnvironmentsDir, r.Environment), r.EnvChanges); err != nil {
+		m.status = fmt.Sprintf("Unable to revert the changes to environment %s: %s", r.Environment, err)
+		return
+	}
+	r.EnvReverted = true
+	if err := m.store.Save(r); err != nil {
+		Logger.Error("Error saving run history", "run", r.ID, "error", err)
+	}
+	m.status = fmt.Sprintf("Reverted %d change(s) to environment %s.", len(r.EnvChanges), r.Environment)
+	m.setItems()
+}
+
+// applyEnvChanges applies the changes the selected run left pending to its
+// environment. They are shown first, and only applied once confirmed by pressing
+// ApplyKey again. Nothing is applied if the environment was modified since.
+func (m *Model) applyEnvChanges() {
+	if len(m.runs) == 0 {
+		return
+	}
+	r := m.runs[m.list.Cursor()]
+	if len(r.EnvPending) == 0 {
+		m.status = "The selected run has no pending environment changes."
+		return
+	}
+	if m.confirming != r {
+		m.confirming = r
+		var sb strings.Builder
+		for _, c := range r.EnvPending {
+			sb.WriteString(strings.Join(c.Diff(), "\n") + "\n")
+		}
+		sb.WriteString(fmt.Sprintf("Press %s again to apply them to environment %s.", ApplyKey.KeyStroke(), r.Environment))
+		m.status = sb.String()
+		return
+	}
+	m.confirming = nil
+	if err := env.Apply(filepath.Join(m.cfg.EnvironmentsDir, r.Environment), r.EnvPending); err != nil {
+		m.status = fmt.Sprintf("Unable to apply the changes to environment %s: %s", r.Environment, err)
+		return
+	}
+	r.EnvChanges, r.EnvPending = r.EnvPending, nil
+	if err := m.store.Save(r); err != nil {
+		Logger.Error("Error saving run history", "run", r.ID, "error", err)
+	}
+	m.status = fmt.Sprintf("Applied %d change(s) to environment %s.", len(r.EnvChanges), r.Environment)
+	m.setItems()
+}
+
+// artifactsCmd shows the artifacts of the selected run.
+func (m *Model) artifactsCmd() tea.Cmd {
+	if len(m.runs) == 0 {
+		return nil
+	}
+	r := m.runs[m.list.Cursor()]
+	if r.ArtifactsDir == "" {
+		m.status = "The selected run has no artifacts."
+		return nil
+	}
+	return func() tea.Msg {
+		return artifacts.ShowMessage{RunID: r.ID, Dir: r.ArtifactsDir}
+	}
+}
+
+// resumeCmd resumes the selected run. Retrying only makes sense for runs that failed
+// and starts right away from the failed step, resuming opens the run preview so the
+// step to resume from can be picked.
+func (m *Model) resumeCmd(retry bool) tea.Cmd {
+	if len(m.runs) == 0 {
+		return nil
+	}
+	r := m.runs[m.list.Cursor()]
+	index := r.ResumableStep()
+	if retry && (r.Status == history.StatusSucceeded || index < 0) {
+		return nil
+	}
+	if index < 0 {
+		index = 0
+	}
+	return func() tea.Msg {
+		return run.ResumeMessage{
+			Recipe:     r.Recipe,
+			Run:        r,
+			StartIndex: index,
+			Start:      retry,
+		}
+	}
+}
+
+func runTitle(r *history.Run) string {
+	title := r.Recipe.Name
+	if r.Environment != "" {
+		title = fmt.Sprintf("%s [Env: %s]", title, r.Environment)
+	}
+	return fmt.Sprintf("%s %s", title, r.Status)
+}
+
+func runDescription(r *history.Run) string {
+	desc := r.StartedAt.Format("2006-01-02 15:04:05")
+	if i := r.FailedStep(); i >= 0 {
+		desc = fmt.Sprintf("%s, failed at step %d/%d", desc, i+1, len(r.Steps))
+	}
+	if r.ResumedFrom != "" {
+		desc = fmt.Sprintf("%s, resumed from %s", desc, r.ResumedFrom)
+	}
+	switch {
+	case r.EnvReverted:
+		desc = fmt.Sprintf("%s, env changes reverted", desc)
+	case len(r.EnvChanges) > 0:
+		desc = fmt.Sprintf("%s, %d env change(s)", desc, len(r.EnvChanges))
+	case len(r.EnvPending) > 0:
+		desc = fmt.Sprintf("%s, %d pending env change(s)", desc, len(r.EnvPending))
+	}
+	return desc
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/hypershift-community/hyper-console/pkg/tui/lib/keys"
 	"github.com/hypershift-community/hyper-console/pkg/tui/lib/simplelist"
 	"github.com/hypershift-community/hyper-console/pkg/tui/lib/styles"
+	"github.com/hypershift-community/hyper-console/pkg/tui/sessions"
 )
 
 const (
@@ -28,6 +29,15 @@ const (
 	defaultHeight = 30
 )
 
+const (
+	RecipesItem = iota
+	ClustersItem
+	HistoryItem
+	WorkflowsItem
+	SessionsItem
+	BackgroundItem
+)
+
 type SelectMessage struct {
 	Selected int
 }
@@ -41,10 +51,15 @@ func New() tea.Model {
 	items := []simplelist.Item{
 		{Name: "Recipes", Description: "View and run recipes"},
 		{Name: "HyperShift Clusters", Description: "View and manage HyperShift clusters"},
+		{Name: "Run History", Description: "Retry or resume previous recipe runs"},
+		{Name: "Workflows", Description: "Run recipes chained into workflows"},
+		{Name: "Sessions", Description: "Switch between the runs started"},
+		{Name: "Background Runs", Description: "Attach to the runs which keep running after quitting"},
 	}
 
 	defaultStyles := styles.DefaultStyles()
-	keyMap := keys.NewListKeyMap()
+	keyMap := keys.NewListKeyMap().
+		WithKey(sessions.PanelKey, true)
 
 	l := simplelist.NewList(keyMap, &defaultStyles, defaultWidth, defaultHeight, items...)
 
@@ -18,17 +18,27 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/tui/artifacts"
+	"github.com/hypershift-community/hyper-console/pkg/tui/background"
 	"github.com/hypershift-community/hyper-console/pkg/tui/environments"
+	"github.com/hypershift-community/hyper-console/pkg/tui/history"
 	"github.com/hypershift-community/hyper-console/pkg/tui/home"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/keys"
 	"github.com/hypershift-community/hyper-console/pkg/tui/lib/navigation"
 	"github.com/hypershift-community/hyper-console/pkg/tui/recipes"
+	"github.com/hypershift-community/hyper-console/pkg/tui/recipes/batch"
 	"github.com/hypershift-community/hyper-console/pkg/tui/recipes/run"
+	"github.com/hypershift-community/hyper-console/pkg/tui/sessions"
+	"github.com/hypershift-community/hyper-console/pkg/tui/workflows"
 )
 
 type Model struct {
 	modelStack []tea.Model
 	windowSize tea.WindowSizeMsg
 	cfg        *config.Config
+	// sessions are the runs of recipes, which keep running when their view is left.
+	sessions *sessions.Manager
+	keyMap   *keys.KeyMap
 }
 
 func NewModel(cfg *config.Config) tea.Model {
@@ -36,6 +46,8 @@ func NewModel(cfg *config.Config) tea.Model {
 	return &Model{
 		modelStack: []tea.Model{home.New()},
 		cfg:        cfg,
+		sessions:   sessions.NewManager(),
+		keyMap:     keys.NewKeyMap().WithKey(sessions.PanelKey, false),
 	}
 }
 
@@ -51,18 +63,94 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.windowSize = msg
+	case tea.KeyMsg:
+		if _, ok := m.modelStack[len(m.modelStack)-1].(*sessions.Panel); !ok && m.keyMap.Matches(msg, sessions.PanelKey) {
+			model = sessions.NewPanel(m.windowSize.Width, m.windowSize.Height, m.sessions)
+			m.modelStack = append(m.modelStack, model)
+			return m, model.Init()
+		}
+	case sessions.Message:
+		// The messages asking for another view are handled here, the others
+		// belong to the model of the session whether it is on screen or not
+		switch inner := msg.Msg.(type) {
+		case run.ResumeMessage:
+			if inner.Replace {
+				// The new run replaces the session of the previous one
+				m.closeSession(msg.ID)
+				inner.Replace = false
+			}
+			return m.Update(inner)
+		case navigation.BackMessage, artifacts.ShowMessage:
+			return m.Update(inner)
+		}
+		return m, m.sessions.Update(msg.ID, msg.Msg)
 	case home.SelectMessage:
-		model = recipes.New(m.windowSize.Width, m.windowSize.Height, m.cfg)
+		switch msg.Selected {
+		case home.HistoryItem:
+			model = history.New(m.windowSize.Width, m.windowSize.Height, m.cfg)
+		case home.WorkflowsItem:
+			model = workflows.New(m.windowSize.Width, m.windowSize.Height, m.cfg)
+		case home.SessionsItem:
+			model = sessions.NewPanel(m.windowSize.Width, m.windowSize.Height, m.sessions)
+		case home.BackgroundItem:
+			model = background.New(m.windowSize.Width, m.windowSize.Height, m.cfg)
+		default:
+			model = recipes.New(m.windowSize.Width, m.windowSize.Height, m.cfg)
+		}
 		cmds = append(cmds, model.Init())
 		m.modelStack = append(m.modelStack, model)
 	case recipes.SelectMessage:
-		model = run.New(m.windowSize.Width, m.windowSize.Height, msg.Recipe, m.cfg.EnvironmentsDir)
+		var opts []run.Option
+		if msg.Step {
+			opts = append(opts, run.WithStepMode())
+		}
+		cmds = append(cmds, m.startSession(run.New(m.windowSize.Width, m.windowSize.Height, msg.Recipe, m.cfg, opts...)))
+	case run.ResumeMessage:
+		if msg.Replace && len(m.modelStack) > 1 {
+			m.modelStack = m.modelStack[:len(m.modelStack)-1]
+		}
+		cmds = append(cmds, m.startSession(run.New(m.windowSize.Width, m.windowSize.Height, msg.Recipe, m.cfg,
+			run.WithResume(msg.Run, msg.StartIndex, msg.Start))))
+	case recipes.BackgroundMessage:
+		model = background.NewStart(m.windowSize.Width, m.windowSize.Height, msg.Recipe, m.cfg)
 		cmds = append(cmds, model.Init())
 		m.modelStack = append(m.modelStack, model)
+	case background.AttachMessage:
+		model = background.NewAttach(m.windowSize.Width, m.windowSize.Height, msg.ID, m.cfg)
+		cmds = append(cmds, model.Init())
+		m.modelStack = append(m.modelStack, model)
+	case sessions.AttachMessage:
+		// The panel is replaced by the session
+		m.modelStack = m.modelStack[:len(m.modelStack)-1]
+		m.modelStack = append(m.modelStack, m.sessions.Attach(msg.ID))
+		cmds = append(cmds, m.sessions.Update(msg.ID, m.windowSize))
+	case sessions.CloseMessage:
+		m.closeSession(msg.ID)
 	case recipes.SetEnvMessage:
 		model = environments.New(m.windowSize.Width, m.windowSize.Height, msg.Recipe, m.cfg)
 		cmds = append(cmds, model.Init())
 		m.modelStack = append(m.modelStack, model)
+	case artifacts.ShowMessage:
+		model = artifacts.New(m.windowSize.Width, m.windowSize.Height, msg)
+		cmds = append(cmds, model.Init())
+		m.modelStack = append(m.modelStack, model)
+	case recipes.BatchMessage:
+		model = environments.NewMulti(m.windowSize.Width, m.windowSize.Height, msg.Recipe, m.cfg)
+		cmds = append(cmds, model.Init())
+		m.modelStack = append(m.modelStack, model)
+	case environments.BatchMessage:
+		// The selection of the environments is replaced by the batch run
+		m.modelStack = m.modelStack[:len(m.modelStack)-1]
+		model = batch.New(m.windowSize.Width, m.windowSize.Height, msg.Recipe, msg.Environments, msg.Parallelism, m.cfg)
+		cmds = append(cmds, model.Init())
+		m.modelStack = append(m.modelStack, model)
+	case workflows.RunMessage:
+		if msg.Replace && len(m.modelStack) > 1 {
+			m.modelStack = m.modelStack[:len(m.modelStack)-1]
+		}
+		model = workflows.NewRun(m.windowSize.Width, m.windowSize.Height, msg.Workflow, msg.ResumeFrom, m.cfg)
+		cmds = append(cmds, model.Init())
+		m.modelStack = append(m.modelStack, model)
 	case environments.SelectMessage:
 		// All we need to do is pop the current model off the stack
 		// and rely on passing the selected environment message to the
@@ -81,6 +169,26 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// startSession starts the run as a session and shows it.
+func (m *Model) startSession(r sessions.Runner) tea.Cmd {
+	s, cmd := m.sessions.Start(r)
+	m.modelStack = append(m.modelStack, m.sessions.Attach(s.ID))
+	return cmd
+}
+
+// closeSession closes the session and removes its views.
+func (m *Model) closeSession(id int) {
+	m.sessions.Close(id)
+	stack := m.modelStack[:0]
+	for _, model := range m.modelStack {
+		if v, ok := model.(*sessions.View); ok && v.ID() == id {
+			continue
+		}
+		stack = append(stack, model)
+	}
+	m.modelStack = stack
+}
+
 func (m *Model) View() string {
 	return m.modelStack[len(m.modelStack)-1].View()
 }
@@ -0,0 +1,319 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package batch shows the runs of a recipe in several environments as a matrix of
+// the status of each step in each environment, which can be drilled into to see the
+// output of a step.
+package batch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/hypershift-community/hyper-console/pkg/batch"
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/history"
+	"github.com/hypershift-community/hyper-console/pkg/logging"
+	"github.com/hypershift-community/hyper-console/pkg/recipes"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/keys"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/navigation"
+)
+
+var (
+	Logger = logging.Logger
+
+	LeftKey  = keys.NewCustomKey("Left", "left", "Select the previous step")
+	RightKey = keys.NewCustomKey("Right", "right", "Select the next step")
+	AbortKey = keys.NewCustomKey("Abort", "x", "Abort the runs, their deferred commands still run")
+
+	titleStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("63"))
+	headerStyle   = lipgloss.NewStyle().Bold(true)
+	selectedStyle = lipgloss.NewStyle().Reverse(true)
+	helpStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+
+	stepMarks = map[history.StepStatus]string{
+		history.StepPending:   lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render("·"),
+		history.StepRunning:   lipgloss.NewStyle().Foreground(lipgloss.Color("63")).Render("●"),
+		history.StepSucceeded: lipgloss.NewStyle().Foreground(lipgloss.Color("42")).Render("✓"),
+		history.StepFailed:    lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Render("✗"),
+		history.StepTimedOut:  lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Render("⏱"),
+		history.StepSkipped:   lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Render("-"),
+	}
+	statusStyles = map[history.Status]lipgloss.Style{
+		batch.StatusPending:     lipgloss.NewStyle().Foreground(lipgloss.Color("241")),
+		history.StatusRunning:   lipgloss.NewStyle().Foreground(lipgloss.Color("63")),
+		history.StatusSucceeded: lipgloss.NewStyle().Foreground(lipgloss.Color("42")),
+		history.StatusFailed:    lipgloss.NewStyle().Foreground(lipgloss.Color("196")),
+		history.StatusAborted:   lipgloss.NewStyle().Foreground(lipgloss.Color("214")),
+	}
+)
+
+// updatedMessage is sent when the result of a run changed, or once the batch is done
+// when the updates are closed.
+type updatedMessage struct {
+	closed bool
+}
+
+type tickMessage time.Time
+
+type model struct {
+	recipe      recipes.Recipe
+	batch       *batch.Batch
+	cancel      context.CancelFunc
+	parallelism int
+	results     []batch.Result
+	keyMap      *keys.KeyMap
+	width       int
+	height      int
+	row         int
+	col         int
+	// detail is set while the output of the selected cell is shown.
+	detail   bool
+	viewport viewport.Model
+	done     bool
+}
+
+// New creates the view running the recipe in the given environments.
+func New(width, height int, recipe recipes.Recipe, environments []string, parallelism int, cfg *config.Config) tea.Model {
+	b := batch.New(recipe, environments, batch.Options{Config: cfg, Parallelism: parallelism})
+	m := &model{
+		recipe:      recipe,
+		batch:       b,
+		parallelism: max(parallelism, 1),
+		results:     b.Results(),
+		keyMap: keys.NewViewportKeyMap().
+			WithKey(LeftKey, false).
+			WithKey(RightKey, false).
+			WithKey(AbortKey, true).
+			WithKey(keys.ForceQuit, false),
+		width:    width,
+		height:   height,
+		viewport: viewport.New(width, max(height-4, 1)),
+	}
+	return m
+}
+
+func (m *model) Init() tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	run := func() tea.Msg {
+		m.batch.Run(ctx)
+		return nil
+	}
+	return tea.Batch(run, m.waitForUpdates(), tick())
+}
+
+// waitForUpdates waits for the result of a run to change.
+func (m *model) waitForUpdates() tea.Cmd {
+	updates := m.batch.Updates()
+	return func() tea.Msg {
+		_, ok := <-updates
+		return updatedMessage{closed: !ok}
+	}
+}
+
+// tick refreshes the durations of the runs while they are running.
+func tick() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
+		return tickMessage(t)
+	})
+}
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.viewport.Width = msg.Width
+		m.viewport.Height = max(msg.Height-4, 1)
+		m.refreshDetail()
+		return m, nil
+	case updatedMessage:
+		m.results = m.batch.Results()
+		m.refreshDetail()
+		if msg.closed {
+			m.done = true
+			return m, nil
+		}
+		return m, m.waitForUpdates()
+	case tickMessage:
+		if m.done {
+			return m, nil
+		}
+		m.results = m.batch.Results()
+		m.refreshDetail()
+		return m, tick()
+	case tea.KeyMsg:
+		return m, m.handleKeys(msg)
+	}
+	return m, nil
+}
+
+func (m *model) handleKeys(msg tea.KeyMsg) tea.Cmd {
+	switch {
+	case m.keyMap.Matches(msg, keys.Quit) || m.keyMap.Matches(msg, keys.ForceQuit):
+		m.cancel()
+		return tea.Quit
+	case m.keyMap.Matches(msg, AbortKey):
+		m.cancel()
+		return nil
+	case m.detail && (m.keyMap.Matches(msg, keys.Cancel) || m.keyMap.Matches(msg, keys.Enter)):
+		m.detail = false
+		return nil
+	case m.detail:
+		var cmd tea.Cmd
+		m.viewport, cmd = m.viewport.Update(msg)
+		return cmd
+	case m.keyMap.Matches(msg, keys.Cancel):
+		if !m.done {
+			// Leaving would leave the runs unattended, they must be aborted first
+			return nil
+		}
+		return navigation.Back()
+	case m.keyMap.Matches(msg, keys.Up):
+		m.row = max(m.row-1, 0)
+	case m.keyMap.Matches(msg, keys.Down):
+		m.row = min(m.row+1, max(len(m.results)-1, 0))
+	case m.keyMap.Matches(msg, LeftKey):
+		m.col = max(m.col-1, 0)
+	case m.keyMap.Matches(msg, RightKey):
+		m.col = min(m.col+1, max(m.columns()-1, 0))
+	case m.keyMap.Matches(msg, keys.Enter):
+		if m.col < len(m.results[m.row].Steps) {
+			m.detail = true
+			m.refreshDetail()
+			m.viewport.GotoBottom()
+		}
+	}
+	return nil
+}
+
+// columns returns the number of steps of the widest run.
+func (m *model) columns() int {
+	n := 0
+	for _, r := range m.results {
+		n = max(n, len(r.Steps))
+	}
+	return n
+}
+
+// refreshDetail refreshes the output of the selected cell while it is shown.
+func (m *model) refreshDetail() {
+	if !m.detail {
+		return
+	}
+	r := m.results[m.row]
+	var content string
+	if m.col < len(r.Output) {
+		content = r.Output[m.col]
+	}
+	if s := r.Steps[m.col]; s.Error != "" {
+		content += "\n" + statusStyles[history.StatusFailed].Render(s.Error)
+	}
+	if content == "" {
+		content = "No output."
+	}
+	atBottom := m.viewport.AtBottom()
+	m.viewport.SetContent(content)
+	if atBottom {
+		m.viewport.GotoBottom()
+	}
+}
+
+func (m *model) View() string {
+	var sb strings.Builder
+	sb.WriteString("\n" + titleStyle.Render(fmt.Sprintf("Batch run of %s, %d at a time", m.recipe.Name, m.parallelism)) + "\n\n")
+	if m.detail {
+		r := m.results[m.row]
+		sb.WriteString(headerStyle.Render(fmt.Sprintf("[%s] step %d: %s", r.Environment, m.col+1, r.Steps[m.col].Cmd)) + "\n")
+		sb.WriteString(m.viewport.View() + "\n")
+		sb.WriteString(helpStyle.Render("esc back • ↑/↓ scroll"))
+		return sb.String()
+	}
+	sb.WriteString(m.matrixView() + "\n\n")
+	sb.WriteString(m.summaryView() + "\n")
+	help := "↑/↓/←/→ select • enter output • x abort"
+	if m.done {
+		help += " • esc back"
+	}
+	sb.WriteString(helpStyle.Render(help))
+	return sb.String()
+}
+
+// matrixView renders a row per environment with the status of the run and a column
+// per step.
+func (m *model) matrixView() string {
+	envWidth := len("ENVIRONMENT")
+	for _, r := range m.results {
+		envWidth = max(envWidth, lipgloss.Width(r.Environment))
+	}
+	const statusWidth, durationWidth = 9, 8
+	cols := m.columns()
+	var sb strings.Builder
+	sb.WriteString(headerStyle.Render(fmt.Sprintf("%-*s  %-*s  %*s ", envWidth, "ENVIRONMENT", statusWidth, "STATUS", durationWidth, "TIME")))
+	for c := 0; c < cols; c++ {
+		sb.WriteString(headerStyle.Render(fmt.Sprintf(" %2d", c+1)))
+	}
+	for i, r := range m.results {
+		sb.WriteString("\n")
+		status := statusStyles[r.Status].Render(fmt.Sprintf("%-*s", statusWidth, r.Status))
+		duration := "-"
+		if d := r.Duration(); d > 0 {
+			duration = d.Round(time.Second).String()
+		}
+		sb.WriteString(fmt.Sprintf("%-*s  %s  %*s ", envWidth, r.Environment, status, durationWidth, duration))
+		for c := 0; c < cols; c++ {
+			mark := " "
+			if c < len(r.Steps) {
+				mark = stepMarks[r.Steps[c].Status]
+			}
+			cell := "  " + mark
+			if i == m.row && c == m.col {
+				cell = " " + selectedStyle.Render(" "+mark)
+			}
+			sb.WriteString(cell)
+		}
+		if r.Error != "" && len(r.Steps) == 0 {
+			sb.WriteString("  " + statusStyles[history.StatusFailed].Render(r.Error))
+		}
+	}
+	if len(m.results) > 0 && m.col < len(m.results[m.row].Steps) {
+		sb.WriteString("\n\n" + fmt.Sprintf("Step %d: %s", m.col+1, m.results[m.row].Steps[m.col].Cmd))
+	}
+	return sb.String()
+}
+
+func (m *model) summaryView() string {
+	s := m.batch.Summary()
+	running := 0
+	for _, r := range s.Results {
+		if r.Status == history.StatusRunning {
+			running++
+		}
+	}
+	summary := fmt.Sprintf("%d succeeded, %d failed", s.Succeeded, s.Failed)
+	if running > 0 {
+		summary = fmt.Sprintf("%d running, %s", running, summary)
+	}
+	if m.done {
+		summary = "Done: " + summary
+	}
+	return summary
+}
@@ -29,10 +29,17 @@ import (
 
 var (
 	SetEnvKey = keys.NewCustomKey("Set Environment", "ctrl+e", "Set the environment for the recipe")
+	BatchKey  = keys.NewCustomKey("Batch run", "ctrl+b", "Run the recipe in several environments")
+	// BackgroundKey runs the recipe in the supervisor, where it survives quitting.
+	BackgroundKey = keys.NewCustomKey("Run in background", "ctrl+g", "Run the recipe in the supervisor, it keeps running after quitting")
+	// StepKey opens the run in its preview, to set breakpoints or step through it.
+	StepKey = keys.NewCustomKey("Step through", "ctrl+t", "Preview the recipe to set breakpoints and step through it")
 )
 
 type SelectMessage struct {
 	Recipe recipes.Recipe
+	// Step pauses before the first command, the run is previewed instead of started.
+	Step bool
 }
 
 type SetEnvMessage struct {
@@ -40,6 +47,16 @@ type SetEnvMessage struct {
 	Recipe   *recipes.Recipe
 }
 
+// BatchMessage asks for the environments to run the recipe in as a batch.
+type BatchMessage struct {
+	Recipe *recipes.Recipe
+}
+
+// BackgroundMessage asks for the recipe to run in the supervisor.
+type BackgroundMessage struct {
+	Recipe recipes.Recipe
+}
+
 type recipesMessage []recipes.Recipe
 
 type item struct {
@@ -65,7 +82,10 @@ func New(width, height int, cfg *config.Config) tea.Model {
 	items := make([]list.Item, 0)
 	defaultStyles := styles.DefaultStyles()
 	keyMap := keys.NewListKeyMap().
-		WithKey(SetEnvKey, true)
+		WithKey(SetEnvKey, true).
+		WithKey(BatchKey, true).
+		WithKey(BackgroundKey, true).
+		WithKey(StepKey, true)
 	delegate := newItemDelegate(keyMap, &defaultStyles)
 	l := list.New(items, delegate, width, height)
 	l.Title = "HyperShift Dev Console"
@@ -107,11 +127,17 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch {
 		case m.keyMap.Matches(msg, keys.Enter):
-			cmd = m.getSelectedCmd()
+			cmd = m.getSelectedCmd(false)
+		case m.keyMap.Matches(msg, StepKey):
+			cmd = m.getSelectedCmd(true)
 		case m.keyMap.Matches(msg, keys.Cancel):
 			return m, navigation.Back()
 		case m.keyMap.Matches(msg, SetEnvKey):
 			return m, m.setEnvCmd(m.list.Cursor())
+		case m.keyMap.Matches(msg, BatchKey):
+			return m, m.batchCmd(m.list.Cursor())
+		case m.keyMap.Matches(msg, BackgroundKey):
+			return m, m.backgroundCmd(m.list.Cursor())
 		}
 		cmds = append(cmds, cmd)
 	case recipesMessage:
@@ -132,9 +158,9 @@ func (m *Model) View() string {
 	//return lipgloss.PlaceHorizontal(m.windowWidth, lipgloss.Center, listView)
 }
 
-func (m *Model) getSelectedCmd() tea.Cmd {
+func (m *Model) getSelectedCmd(step bool) tea.Cmd {
 	return func() tea.Msg {
-		return SelectMessage{Recipe: m.recipes[m.list.Cursor()]}
+		return SelectMessage{Recipe: m.recipes[m.list.Cursor()], Step: step}
 	}
 }
 
@@ -144,6 +170,18 @@ func (m *Model) setEnvCmd(index int) tea.Cmd {
 	}
 }
 
+func (m *Model) batchCmd(index int) tea.Cmd {
+	return func() tea.Msg {
+		return BatchMessage{Recipe: &m.recipes[index]}
+	}
+}
+
+func (m *Model) backgroundCmd(index int) tea.Cmd {
+	return func() tea.Msg {
+		return BackgroundMessage{Recipe: m.recipes[index]}
+	}
+}
+
 func (m *Model) refreshList() {
 	items := make([]list.Item, len(m.recipes))
 	widest := 0
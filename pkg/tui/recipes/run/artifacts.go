@@ -0,0 +1,59 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package run
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/hypershift-community/hyper-console/pkg/history"
+	"github.com/hypershift-community/hyper-console/pkg/tui/artifacts"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/keys"
+)
+
+var ArtifactsKey = keys.NewCustomKey("Artifacts", "f", "Browse the files the commands of the run generated")
+
+// writeArtifacts writes the number of artifacts the run generated to the log once
+// it is over.
+func (m *model) writeArtifacts() {
+	if m.artifactsDir == "" {
+		return
+	}
+	list, err := history.ListArtifacts(m.artifactsDir)
+	if err != nil {
+		Logger.Error("Error listing artifacts", "dir", m.artifactsDir, "error", err)
+		return
+	}
+	if len(list) == 0 {
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString(currentCmdStyle.Render("Artifacts") + "\n" + strings.Repeat("─", m.width) + "\n")
+	sb.WriteString(fmt.Sprintf("%d file(s) in %s\n", len(list), m.artifactsDir))
+	sb.WriteString(fmt.Sprintf("Press %s to browse them.\n", ArtifactsKey.KeyStroke()))
+	_, _ = m.log.WriteString(sb.String())
+}
+
+func (m *model) handleArtifactsKeys(msg tea.KeyMsg) (bool, tea.Cmd) {
+	if !m.keyMap.Matches(msg, ArtifactsKey) || !m.done || m.artifactsDir == "" {
+		return false, nil
+	}
+	show := artifacts.ShowMessage{RunID: m.runID, Dir: m.artifactsDir}
+	return true, func() tea.Msg {
+		return show
+	}
+}
@@ -0,0 +1,107 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package run
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/hypershift-community/hyper-console/pkg/env"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/keys"
+)
+
+var (
+	ApplyEnvKey = keys.NewCustomKey("Apply env changes", "w", "Apply the changes of the recipe to its environment, once confirmed")
+
+	addedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	removedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("203"))
+)
+
+// planEnvWrites plans the changes the recipe makes to its environment once the run
+// succeeded and writes them to the log as a diff. They are only applied once the
+// user confirms, and are recorded as pending until then, so that they can also be
+// applied from the run history.
+func (m *model) planEnvWrites() {
+	if m.recipe.EnvWrites == nil || m.execution == nil || m.error != nil || m.aborted {
+		return
+	}
+	changes, err := m.execution.EnvWrites(m.outputs)
+	if err != nil {
+		m.writeEnvStatus(fmt.Sprintf("%s Unable to plan the changes to environment %s: %s", crossMark, m.recipe.Environment, err))
+		return
+	}
+	if len(changes) == 0 {
+		m.writeEnvStatus(fmt.Sprintf("%s Environment %s is up to date.", checkMark, m.recipe.Environment))
+		return
+	}
+	m.envChanges = changes
+	if m.record != nil {
+		m.record.EnvPending = changes
+		m.saveRecord()
+	}
+	var sb strings.Builder
+	sb.WriteString(currentCmdStyle.Render("Environment changes") + "\n" + strings.Repeat("─", m.width) + "\n")
+	for _, c := range changes {
+		for _, l := range c.Diff() {
+			switch l[0] {
+			case '+':
+				l = addedStyle.Render(l)
+			case '-':
+				l = removedStyle.Render(l)
+			default:
+				l = warningStyle.Render(l)
+			}
+			sb.WriteString(l + "\n")
+		}
+	}
+	sb.WriteString(fmt.Sprintf("\nPress %s twice to apply them to environment %s.\n", ApplyEnvKey.KeyStroke(), m.recipe.Environment))
+	_, _ = m.log.WriteString(sb.String())
+}
+
+// handleEnvKeys applies the planned changes to the environment when the user
+// confirms them: the first keypress brings the diff back on screen, the second one
+// applies them.
+func (m *model) handleEnvKeys(msg tea.KeyMsg) (bool, tea.Cmd) {
+	if m.envChanges == nil || !m.keyMap.Matches(msg, ApplyEnvKey) {
+		return false, nil
+	}
+	if !m.envConfirming {
+		m.envConfirming = true
+		m.detached = false
+		m.writeEnvStatus(fmt.Sprintf("Press %s again to apply %d change(s) to environment %s, any other key cancels.", ApplyEnvKey.KeyStroke(), len(m.envChanges), m.recipe.Environment))
+		return true, nil
+	}
+	changes := m.envChanges
+	m.envChanges = nil
+	m.envConfirming = false
+	if err := env.Apply(m.execution.EnvDir(), changes); err != nil {
+		m.writeEnvStatus(fmt.Sprintf("%s Unable to apply the changes to environment %s: %s", crossMark, m.recipe.Environment, err))
+		return true, nil
+	}
+	if m.record != nil {
+		m.record.EnvChanges = changes
+		m.record.EnvPending = nil
+		m.saveRecord()
+	}
+	m.writeEnvStatus(fmt.Sprintf("%s Applied %d change(s) to environment %s. They can be reverted from the run history.", checkMark, len(changes), m.recipe.Environment))
+	return true, nil
+}
+
+func (m *model) writeEnvStatus(status string) {
+	_, _ = m.log.WriteString(status + "\n")
+}
@@ -0,0 +1,98 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package run
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/hypershift-community/hyper-console/pkg/taskexec"
+)
+
+// maxEventBatch is the maximum number of events handled in a single update. Events
+// are batched so that commands with a lot of output don't trigger one render per
+// write, and bounded so that a chatty command doesn't starve the UI.
+const maxEventBatch = 64
+
+var promptStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFCC66")).Bold(true)
+
+// eventsMsg is a batch of events published by the iterator.
+type eventsMsg []taskexec.Event
+
+// waitForEvents waits for the next events published by the iterator. It returns
+// nil once the iterator is closed, which ends the subscription.
+func (m *model) waitForEvents() tea.Cmd {
+	events := m.events
+	return func() tea.Msg {
+		e, ok := <-events
+		if !ok {
+			return nil
+		}
+		batch := eventsMsg{e}
+		for len(batch) < maxEventBatch {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					return batch
+				}
+				batch = append(batch, e)
+			default:
+				return batch
+			}
+		}
+		return batch
+	}
+}
+
+// handleEvents updates the view with a batch of events and waits for the next ones.
+func (m *model) handleEvents(msg eventsMsg) tea.Cmd {
+	var cmds []tea.Cmd
+	for _, e := range msg {
+		switch e := e.(type) {
+		case taskexec.CommandStarted:
+			m.beginCommand(e.Step.String(), e.Step.Phase == taskexec.PhaseCleanup, e.Time)
+			if e.Step.Cmd != nil {
+				m.currentCommand.render = e.Step.Cmd.Render
+			}
+			if e.Step.Cmd != nil && e.Step.Cmd.Retry != nil && e.Step.Cmd.Retry.Attempts > 1 {
+				m.beginAttempt(1, e.Step.Cmd.Retry.Attempts, e.Time)
+			}
+			m.beginWait(e)
+		case taskexec.OutputChunk:
+			_, _ = m.log.Write(e.Data)
+			// The last line may not be complete yet
+			m.scanGroups(m.log.Len() - 1)
+		case taskexec.CommandRetrying:
+			m.retryCommand(e)
+		case taskexec.ConditionPolled:
+			m.conditionPolled(e)
+		case taskexec.PromptRequested:
+			_, _ = m.log.WriteString(promptStyle.Render("? "+e.Prompt) + "\n")
+		case taskexec.CommandFinished:
+			m.finishAttempts(e)
+			m.endWait(e.Err)
+			m.recordOutputs(e.Outputs)
+			switch {
+			case e.Err != nil:
+				cmds = append(cmds, m.commandFailed(e.Err))
+			case e.Skipped:
+				cmds = append(cmds, m.commandExecuted(commandUpToDate))
+			default:
+				cmds = append(cmds, m.commandExecuted("Command executed successfully"))
+			}
+		}
+	}
+	return tea.Batch(append(cmds, m.waitForEvents())...)
+}
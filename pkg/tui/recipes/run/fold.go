@@ -0,0 +1,411 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package run
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/hypershift-community/hyper-console/pkg/runner"
+	"github.com/hypershift-community/hyper-console/pkg/task/taskfile/ast"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/keys"
+)
+
+var (
+	FoldKey        = keys.NewCustomKey("Fold", " ", "Fold or unfold the section at the top of the view")
+	ExpandAllKey   = keys.NewCustomKey("Expand all", "+", "Expand all the sections")
+	CollapseAllKey = keys.NewCustomKey("Collapse all", "-", "Collapse all the finished sections")
+	RawOutputKey   = keys.NewCustomKey("Raw output", "o", "Toggle between the rendered and the raw output of the commands")
+
+	templateActions = regexp.MustCompile(`{{.*?}}`)
+)
+
+// section is a foldable part of the log: the output of a command, or a group of
+// lines delimited by the begin and end markers of the `output: group` style.
+type section struct {
+	label   string
+	cleanup bool
+	// group is true for the sections delimited by output group markers.
+	group bool
+	// attempt is the number of the attempt, out of attempts, of the sections holding
+	// the output of an attempt of a command which is retried.
+	attempt  int
+	attempts int
+	// header is the line of the log rendered as the header of the section.
+	header int
+	// end is the line after the last line of the section, -1 while it is running.
+	end       int
+	lines     int
+	startedAt time.Time
+	duration  time.Duration
+	err       error
+	upToDate  bool
+	collapsed bool
+	parent    *section
+	// render is the renderer declared by the command, if any. Once the command
+	// succeeded, rendered holds the rendered rows, which replace the lines of its
+	// output up to outputEnd.
+	render    *ast.Render
+	rendered  []string
+	outputEnd int
+}
+
+func (s *section) done() bool {
+	return s.end >= 0
+}
+
+// contains returns true if the given line of the log is part of the section.
+func (s *section) contains(line int) bool {
+	return line >= s.header && (!s.done() || line < s.end)
+}
+
+// segment is a part of the rendered log: either n lines of the log starting at line,
+// the header of a section, which is a single row, or the n rows of the rendered
+// output of a section, which replace its output starting at line.
+type segment struct {
+	line     int
+	n        int
+	section  *section
+	rendered bool
+}
+
+// contains returns true if the segment shows the given line of the log.
+func (seg segment) contains(line int) bool {
+	if seg.rendered {
+		return line >= seg.line && line < seg.section.outputEnd
+	}
+	return line >= seg.line && line < seg.line+seg.n
+}
+
+// segments splits the log in the segments that are rendered, skipping the content of
+// the collapsed sections.
+func (m *model) segments() []segment {
+	var segments []segment
+	pos := 0
+	for _, s := range m.sections {
+		if s.header < pos {
+			// Hidden in a collapsed section
+			continue
+		}
+		if s.header > pos {
+			segments = append(segments, segment{line: pos, n: s.header - pos})
+		}
+		segments = append(segments, segment{line: s.header, n: 1, section: s})
+		pos = s.header + 1
+		switch {
+		case s.collapsed && s.done():
+			pos = s.end
+		case m.showsRendered(s):
+			// The empty line after the header, then the rendered output
+			segments = append(segments, segment{line: pos, n: 1})
+			segments = append(segments, segment{line: pos + 1, n: len(s.rendered), section: s, rendered: true})
+			pos = s.outputEnd
+		}
+	}
+	if n := m.log.Len(); n > pos {
+		segments = append(segments, segment{line: pos, n: n - pos})
+	}
+	return segments
+}
+
+// totalRows returns the number of rows of the rendered log.
+func (m *model) totalRows() int {
+	n := 0
+	for _, s := range m.segments() {
+		n += s.n
+	}
+	return n
+}
+
+// rows renders the rows of the log in [from, from+n). It also returns the line of
+// the log each row shows, or -1 for the headers of the sections and the rendered
+// outputs.
+func (m *model) rows(from, n int) ([]string, []int, error) {
+	var rows []string
+	var lines []int
+	row := 0
+	for _, seg := range m.segments() {
+		if len(rows) >= n {
+			break
+		}
+		if row+seg.n <= from {
+			row += seg.n
+			continue
+		}
+		if seg.rendered {
+			skip := max(0, from-row)
+			read := seg.section.rendered[skip:min(seg.n, skip+n-len(rows))]
+			for range read {
+				lines = append(lines, -1)
+			}
+			rows = append(rows, read...)
+			row += seg.n
+			continue
+		}
+		if seg.section != nil {
+			rows = append(rows, m.sectionHeader(seg.section))
+			lines = append(lines, -1)
+			row++
+			continue
+		}
+		skip := max(0, from-row)
+		read, err := m.log.Lines(seg.line+skip, min(seg.n-skip, n-len(rows)))
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := range read {
+			lines = append(lines, seg.line+skip+i)
+		}
+		rows = append(rows, read...)
+		row += seg.n
+	}
+	return rows, lines, nil
+}
+
+// rowOf returns the row showing the given line of the log, expanding the sections
+// hiding it.
+func (m *model) rowOf(line int) int {
+	for _, s := range m.sections {
+		if s.header != line && s.contains(line) {
+			s.collapsed = false
+		}
+	}
+	row := 0
+	for _, seg := range m.segments() {
+		if seg.contains(line) {
+			if seg.rendered {
+				return row
+			}
+			return row + line - seg.line
+		}
+		row += seg.n
+	}
+	return row
+}
+
+// lineAt returns the line of the log shown by the given row.
+func (m *model) lineAt(row int) int {
+	for _, seg := range m.segments() {
+		if row < seg.n {
+			if seg.rendered {
+				return seg.line
+			}
+			return seg.line + row
+		}
+		row -= seg.n
+	}
+	return m.log.Len() - 1
+}
+
+// sectionHeader renders the header of a section with its fold marker, status,
+// duration and line count.
+func (m *model) sectionHeader(s *section) string {
+	fold := "▾"
+	if s.collapsed && s.done() {
+		fold = "▸"
+	}
+	if s.group {
+		return fmt.Sprintf("%s %s %s", fold, s.label, skippedStyle.Render(fmt.Sprintf("(%d lines)", s.lines)))
+	}
+	if s.attempt > 0 {
+		return m.attemptHeader(fold, s)
+	}
+	var mark, info string
+	switch {
+	case !s.done():
+		mark = m.spinner.View()
+		info = fmt.Sprintf("(running %s%s)", time.Since(s.startedAt).Round(time.Second), m.attemptView(s))
+		if m.wait != nil && s == m.currentCommand {
+			info = fmt.Sprintf("(%s)", m.waitInfo())
+		}
+	case runner.TimedOut(s.err):
+		mark = crossMark.String()
+		info = fmt.Sprintf("(timed out after %s, %d lines)", formatDuration(s.duration), s.lines)
+	case s.err != nil:
+		mark = crossMark.String()
+		info = fmt.Sprintf("(failed after %s, %d lines)", formatDuration(s.duration), s.lines)
+	case s.upToDate:
+		mark = upToDateMark.String()
+		info = "(up to date)"
+	default:
+		mark = checkMark.String()
+		info = fmt.Sprintf("(%s, %d lines)", formatDuration(s.duration), s.lines)
+	}
+	label := currentCmdStyle.Render(s.label)
+	if s.cleanup {
+		label = cleanupStyle.Render("[cleanup] ") + label
+	}
+	return fmt.Sprintf("%s %s %s %s", fold, mark, label, skippedStyle.Render(info))
+}
+
+// handleFoldKeys handles the keys used to fold the sections of the log. It returns
+// true if the key was consumed and shouldn't be passed to the viewport.
+func (m *model) handleFoldKeys(msg tea.KeyMsg) (bool, tea.Cmd) {
+	if !m.ready || m.previewing || m.treeView {
+		return false, nil
+	}
+	switch {
+	case m.keyMap.Matches(msg, FoldKey):
+		line := m.lineAt(m.logOffset)
+		var target *section
+		for _, s := range m.sections {
+			if s.contains(line) && s.done() {
+				// The innermost section wins, they are sorted by header
+				target = s
+			}
+		}
+		if target == nil {
+			return true, nil
+		}
+		target.collapsed = !target.collapsed
+		m.detached = true
+		m.logOffset = m.rowOf(target.header)
+	case m.keyMap.Matches(msg, ExpandAllKey):
+		for _, s := range m.sections {
+			s.collapsed = false
+		}
+	case m.keyMap.Matches(msg, CollapseAllKey):
+		for _, s := range m.sections {
+			s.collapsed = s.done()
+		}
+	case m.keyMap.Matches(msg, RawOutputKey):
+		m.rawOutput = !m.rawOutput
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// beginCommand writes the header of the command that starts running to the log and
+// opens its section.
+func (m *model) beginCommand(label string, cleanup bool, startedAt time.Time) {
+	s := &section{
+		label:     label,
+		cleanup:   cleanup,
+		header:    m.log.Len(),
+		end:       -1,
+		startedAt: startedAt,
+	}
+	m.sections = append(m.sections, s)
+	m.currentCommand = s
+	m.scanned = s.header + 2
+	if cleanup {
+		_, _ = m.log.WriteString("- " + cleanupStyle.Render("[cleanup] ") + currentCmdStyle.Render(label) + "\n\n")
+	} else {
+		_, _ = m.log.WriteString("- " + currentCmdStyle.Render(label) + "\n\n")
+	}
+}
+
+// endCommand writes the outcome of the current command to the log and closes its
+// section. Successful commands are collapsed, unless their output is rendered, and
+// failed ones stay expanded.
+func (m *model) endCommand(err error, upToDate bool) {
+	s := m.currentCommand
+	if s == nil {
+		return
+	}
+	m.scanGroups(m.log.Len())
+	output := s.header + 2
+	if m.attempt != nil {
+		// Only the output of the last attempt is rendered
+		output = m.attempt.header + 1
+	}
+	m.endAttempt(err, true)
+	s.lines = max(0, m.log.Len()-(s.header+2))
+	if err == nil && !upToDate {
+		m.renderOutput(output)
+	}
+	sb := strings.Builder{}
+	sb.WriteString("\n")
+	if runner.TimedOut(err) {
+		sb.WriteString(fmt.Sprintf("%s Timed out: %s\n", crossMark, err))
+	} else if err != nil {
+		sb.WriteString(fmt.Sprintf("%s Failed: %s\n", crossMark, err))
+	} else if upToDate {
+		sb.WriteString(fmt.Sprintf("%s Up to date.\n", checkMark))
+	} else {
+		sb.WriteString(fmt.Sprintf("%s Done.\n", checkMark))
+	}
+	sb.WriteString(fmt.Sprintf("%s\n", strings.Repeat("─", m.width)))
+	_, _ = m.log.WriteString(sb.String())
+
+	s.end = m.log.Len()
+	s.err = err
+	s.upToDate = upToDate
+	s.duration = time.Since(s.startedAt)
+	m.recordDuration(s.duration)
+	// The rendered output is the result the command is run for
+	s.collapsed = err == nil && s.rendered == nil
+	for _, g := range m.sections {
+		if g.parent == s && !g.done() {
+			// The end marker never came
+			g.end = s.end
+			g.lines = s.end - g.header - 1
+		}
+	}
+	m.currentCommand = nil
+	m.group = nil
+}
+
+// setOutputStyle sets up the detection of the output groups when the Taskfile uses
+// the `output: group` style with begin and end markers.
+func (m *model) setOutputStyle(style ast.Output) {
+	if style.Name != "group" || !style.Group.IsSet() || style.Group.Begin == "" || style.Group.End == "" {
+		return
+	}
+	m.groupBegin = markerPattern(style.Group.Begin)
+	m.groupEnd = markerPattern(style.Group.End)
+}
+
+// markerPattern turns a group marker, which is a template, into a pattern matching
+// the lines it renders to.
+func markerPattern(marker string) *regexp.Regexp {
+	parts := templateActions.Split(strings.TrimSpace(marker), -1)
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
+
+// scanGroups looks for the group markers in the lines of the current command written
+// since the last scan, up to the given line, and opens or closes the group sections.
+func (m *model) scanGroups(to int) {
+	if m.groupBegin == nil || m.currentCommand == nil || to <= m.scanned {
+		return
+	}
+	lines, err := m.log.Lines(m.scanned, to-m.scanned)
+	if err != nil {
+		return
+	}
+	for i, l := range lines {
+		line := m.scanned + i
+		plain := strings.TrimSpace(ansi.Strip(l))
+		switch {
+		case m.group == nil && m.groupBegin.MatchString(plain):
+			m.group = &section{label: plain, group: true, header: line, end: -1, parent: m.currentCommand}
+			m.sections = append(m.sections, m.group)
+		case m.group != nil && m.groupEnd.MatchString(plain):
+			m.group.end = line + 1
+			m.group.lines = line - m.group.header - 1
+			m.group = nil
+		}
+	}
+	m.scanned = to
+}
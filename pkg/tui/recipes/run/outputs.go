@@ -0,0 +1,43 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package run
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// recordOutputs records the outputs of the run once a command is done.
+func (m *model) recordOutputs(outputs map[string]string) {
+	m.outputs = outputs
+	if m.record != nil && len(outputs) > 0 {
+		m.record.Outputs = outputs
+	}
+}
+
+// writeOutputs writes the outputs of the run to the log once it is over.
+func (m *model) writeOutputs() {
+	if len(m.outputs) == 0 {
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString(currentCmdStyle.Render("Outputs") + "\n" + strings.Repeat("─", m.width) + "\n")
+	for _, k := range slices.Sorted(maps.Keys(m.outputs)) {
+		sb.WriteString(fmt.Sprintf("%s=%s\n", k, m.outputs[k]))
+	}
+	_, _ = m.log.WriteString(sb.String())
+}
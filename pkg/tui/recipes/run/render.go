@@ -0,0 +1,53 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package run
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/hypershift-community/hyper-console/pkg/render"
+)
+
+// renderOutput renders the output of the current command written from the given
+// line, when the command declares a renderer. The rendered rows replace the output
+// in the view unless the raw output is toggled on. When the output can't be
+// rendered, the reason is written to the log and the raw output is shown.
+func (m *model) renderOutput(from int) {
+	s := m.currentCommand
+	if s == nil || s.render == nil || from >= m.log.Len() {
+		return
+	}
+	lines, err := m.log.Lines(from, m.log.Len()-from)
+	if err != nil {
+		return
+	}
+	output := ansi.Strip(strings.Join(lines, "\n"))
+	out, err := render.Render(s.render, []byte(output))
+	if err != nil {
+		_, _ = m.log.WriteString(skippedStyle.Render(fmt.Sprintf("Unable to render the output: %s", err)) + "\n")
+		return
+	}
+	s.rendered = strings.Split(out, "\n")
+	s.outputEnd = m.log.Len()
+}
+
+// showsRendered returns true if the view shows the rendered output of the section
+// rather than the raw one.
+func (m *model) showsRendered(s *section) bool {
+	return s.rendered != nil && !m.rawOutput
+}
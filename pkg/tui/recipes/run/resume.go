@@ -0,0 +1,179 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package run
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/hypershift-community/hyper-console/pkg/history"
+	"github.com/hypershift-community/hyper-console/pkg/recipes"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/keys"
+)
+
+var (
+	RetryKey  = keys.NewCustomKey("Retry", "r", "Retry the failed step")
+	ResumeKey = keys.NewCustomKey("Resume", "R", "Pick a step to resume the recipe from")
+
+	warningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+)
+
+// ResumeMessage asks for a new run of the recipe that continues a previous run from
+// the given step instead of starting from the first one.
+type ResumeMessage struct {
+	Recipe     recipes.Recipe
+	Run        *history.Run
+	StartIndex int
+	// Start skips the preview and starts running right away.
+	Start bool
+	// Replace replaces the current view instead of opening a new one on top of it.
+	Replace bool
+}
+
+// Option configures the run view.
+type Option func(*model)
+
+// WithResume resumes a previous run from the given step. When start is false the
+// preview is shown with the cursor on that step so a different one can be picked.
+func WithResume(run *history.Run, startIndex int, start bool) Option {
+	return func(m *model) {
+		m.resumeFrom = run
+		m.cursor = startIndex
+		m.autoStart = start
+	}
+}
+
+func (m *model) handleResumeKeys(msg tea.KeyMsg) (bool, tea.Cmd) {
+	if !m.done || m.record == nil || m.record.Status == history.StatusSucceeded {
+		return false, nil
+	}
+	switch {
+	case m.keyMap.Matches(msg, RetryKey):
+		return true, m.resumeCmd(m.record.ResumableStep(), true)
+	case m.keyMap.Matches(msg, ResumeKey):
+		return true, m.resumeCmd(m.record.ResumableStep(), false)
+	}
+	return false, nil
+}
+
+func (m *model) resumeCmd(index int, start bool) tea.Cmd {
+	if index < 0 {
+		return nil
+	}
+	msg := ResumeMessage{
+		Recipe:     m.recipe,
+		Run:        m.record,
+		StartIndex: index,
+		Start:      start,
+		Replace:    true,
+	}
+	// The session of this run is closed once the new run replaced it
+	return func() tea.Msg {
+		return msg
+	}
+}
+
+// stepLabels returns the labels of all the steps of the execution plan; the same
+// labels are stored in the run history so changes to the recipe can be detected
+// when resuming.
+func (m *model) stepLabels() []string {
+	labels := make([]string, len(m.steps))
+	for i, s := range m.steps {
+		labels[i] = s.String()
+	}
+	return labels
+}
+
+// startRecord creates the history record for this run. When resuming, the steps that
+// succeeded in the previous run are carried over so the new run can itself be resumed.
+func (m *model) startRecord(startIndex int) {
+	if m.history == nil {
+		return
+	}
+	m.record = history.NewRun(m.recipe, m.stepLabels())
+	m.record.ID = m.runID
+	m.record.ArtifactsDir = m.artifactsDir
+	m.record.Workspace = m.workspace
+	for i := 0; i < startIndex; i++ {
+		m.record.Steps[i].Status = history.StepSkipped
+	}
+	if m.resumeFrom != nil {
+		m.record.ResumedFrom = m.resumeFrom.ID
+		for _, s := range m.resumeFrom.StaleSteps(startIndex) {
+			m.record.Steps[s.Index] = s
+		}
+	}
+	m.saveRecord()
+}
+
+func (m *model) saveRecord() {
+	if m.history == nil || m.record == nil {
+		return
+	}
+	if err := m.history.Save(m.record); err != nil {
+		Logger.Error("Error saving run history", "run", m.record.ID, "error", err)
+	}
+}
+
+// staleWarning describes why the state produced by the steps before startIndex in the
+// run being resumed might not be valid anymore. It returns an empty string when not
+// resuming or when nothing is reused.
+func (m *model) staleWarning(startIndex int) string {
+	if m.resumeFrom == nil || startIndex == 0 {
+		return ""
+	}
+	prev := m.resumeFrom
+	var lines []string
+	stale := prev.StaleSteps(startIndex)
+	if len(stale) > 0 {
+		ranAt := stale[len(stale)-1].FinishedAt
+		lines = append(lines, fmt.Sprintf("⚠ Resuming run %s from step %d. %d earlier step(s) ran %s ago and are not re-run;",
+			prev.ID, startIndex+1, len(stale), time.Since(ranAt).Round(time.Second)))
+		lines = append(lines, "  anything they created (buckets, clusters, kubeconfigs...) may be stale or gone.")
+	}
+	if skipped := startIndex - len(stale); skipped > 0 {
+		lines = append(lines, fmt.Sprintf("⚠ %d earlier step(s) never succeeded in run %s and will be skipped.", skipped, prev.ID))
+	}
+	if prev.Environment != m.recipe.Environment {
+		lines = append(lines, fmt.Sprintf("⚠ Run %s used environment %q, this run uses %q.", prev.ID, prev.Environment, m.recipe.Environment))
+	}
+	if changed := prev.ChangedSteps(startIndex, m.stepLabels()); len(changed) > 0 {
+		steps := make([]string, len(changed))
+		for i, c := range changed {
+			steps[i] = fmt.Sprintf("%d", c+1)
+		}
+		lines = append(lines, fmt.Sprintf("⚠ Step(s) %s changed since run %s.", strings.Join(steps, ", "), prev.ID))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return warningStyle.Render(strings.Join(lines, "\n"))
+}
+
+// resumeHelp renders the keys available once a run failed or was aborted.
+func (m *model) resumeHelp() string {
+	if !m.done || m.record == nil || m.record.Status == history.StatusSucceeded {
+		return ""
+	}
+	index := m.record.ResumableStep()
+	if index < 0 {
+		return ""
+	}
+	return skippedStyle.Render(fmt.Sprintf("r: retry step %d • R: resume from step N • esc: back", index+1))
+}
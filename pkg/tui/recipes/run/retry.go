@@ -0,0 +1,133 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package run
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hypershift-community/hyper-console/pkg/history"
+	"github.com/hypershift-community/hyper-console/pkg/task"
+	"github.com/hypershift-community/hyper-console/pkg/taskexec"
+)
+
+// beginAttempt writes the header of an attempt of the current command to the log
+// and opens its section, so that the output of each attempt can be folded.
+func (m *model) beginAttempt(n, attempts int, startedAt time.Time) {
+	s := &section{
+		label:     fmt.Sprintf("Attempt %d/%d", n, attempts),
+		attempt:   n,
+		attempts:  attempts,
+		header:    m.log.Len(),
+		end:       -1,
+		startedAt: startedAt,
+		parent:    m.currentCommand,
+	}
+	m.sections = append(m.sections, s)
+	m.attempt = s
+	_, _ = m.log.WriteString(currentCmdStyle.Render(s.label) + "\n")
+}
+
+// endAttempt closes the section of the current attempt, if any. Failed attempts are
+// collapsed unless they are the last one.
+func (m *model) endAttempt(err error, last bool) {
+	s := m.attempt
+	if s == nil {
+		return
+	}
+	s.end = m.log.Len()
+	s.lines = s.end - s.header - 1
+	s.err = err
+	s.duration = time.Since(s.startedAt)
+	s.collapsed = err != nil && !last
+	if m.group != nil {
+		// The end marker never came
+		m.group.end = s.end
+		m.group.lines = s.end - m.group.header - 1
+		m.group = nil
+	}
+	m.attempt = nil
+}
+
+// retryCommand handles a failed attempt of the current command, which runs again
+// after a delay.
+func (m *model) retryCommand(e taskexec.CommandRetrying) {
+	a := e.Attempt
+	m.scanGroups(m.log.Len())
+	_, _ = m.log.WriteString(fmt.Sprintf("\n%s Attempt %d/%d failed: %s\n%s\n",
+		crossMark, a.Number, a.Attempts, a.Err,
+		skippedStyle.Render(fmt.Sprintf("Retrying in %s...", formatDuration(a.Delay)))))
+	opened := m.attempt != nil
+	m.endAttempt(a.Err, false)
+	if opened {
+		m.beginAttempt(a.Number+1, a.Attempts, a.FinishedAt.Add(a.Delay))
+	}
+	m.retries = append(m.retries, a)
+	if m.record != nil {
+		m.record.AddAttempt(m.step.Index, history.Attempt{
+			StartedAt:  a.StartedAt,
+			FinishedAt: a.FinishedAt,
+			ExitCode:   a.ExitCode,
+			Error:      a.Err.Error(),
+		})
+		m.saveRecord()
+	}
+}
+
+// finishAttempts records the last attempt of a command which was retried.
+func (m *model) finishAttempts(e taskexec.CommandFinished) {
+	if len(m.retries) == 0 {
+		return
+	}
+	last := m.retries[len(m.retries)-1]
+	a := history.Attempt{
+		StartedAt:  last.FinishedAt.Add(last.Delay),
+		FinishedAt: time.Now(),
+		ExitCode:   task.ExitCode(e.Err),
+	}
+	if e.Err != nil {
+		a.Error = e.Err.Error()
+	}
+	if m.record != nil {
+		m.record.AddAttempt(m.step.Index, a)
+	}
+	m.retries = nil
+}
+
+// attemptView describes the attempt of the given running command, if it was retried.
+func (m *model) attemptView(s *section) string {
+	if len(m.retries) == 0 || s != m.currentCommand {
+		return ""
+	}
+	last := m.retries[len(m.retries)-1]
+	return fmt.Sprintf(", attempt %d/%d", last.Number+1, last.Attempts)
+}
+
+// attemptHeader renders the header of the section of an attempt.
+func (m *model) attemptHeader(fold string, s *section) string {
+	var mark, info string
+	switch {
+	case !s.done():
+		mark = m.spinner.View()
+		info = fmt.Sprintf("(running %s)", time.Since(s.startedAt).Round(time.Second))
+	case s.err != nil:
+		mark = crossMark.String()
+		info = fmt.Sprintf("(failed after %s, %d lines)", formatDuration(s.duration), s.lines)
+	default:
+		mark = checkMark.String()
+		info = fmt.Sprintf("(%s, %d lines)", formatDuration(s.duration), s.lines)
+	}
+	return fmt.Sprintf("%s %s %s %s", fold, mark, s.label, skippedStyle.Render(info))
+}
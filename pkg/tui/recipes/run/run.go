@@ -15,12 +15,11 @@
 package run
 
 import (
-	"bytes"
+	"context"
 	"fmt"
-	"io"
-	"path/filepath"
+	"regexp"
 	"strings"
-	"sync/atomic"
+	"time"
 
 	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/spinner"
@@ -28,15 +27,20 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
+	"github.com/hypershift-community/hyper-console/pkg/config"
 	"github.com/hypershift-community/hyper-console/pkg/env"
-	"github.com/hypershift-community/hyper-console/pkg/iter"
+	"github.com/hypershift-community/hyper-console/pkg/history"
 	"github.com/hypershift-community/hyper-console/pkg/logging"
 	"github.com/hypershift-community/hyper-console/pkg/recipes"
+	"github.com/hypershift-community/hyper-console/pkg/runner"
+	"github.com/hypershift-community/hyper-console/pkg/scrollback"
+	"github.com/hypershift-community/hyper-console/pkg/task"
 	"github.com/hypershift-community/hyper-console/pkg/task/taskfile/ast"
 	"github.com/hypershift-community/hyper-console/pkg/taskexec"
 	"github.com/hypershift-community/hyper-console/pkg/tui/lib/keys"
 	"github.com/hypershift-community/hyper-console/pkg/tui/lib/navigation"
 	"github.com/hypershift-community/hyper-console/pkg/tui/lib/styles"
+	"github.com/hypershift-community/hyper-console/pkg/tui/sessions"
 )
 
 // You generally won't need this unless you're processing stuff with
@@ -46,7 +50,6 @@ import (
 // that use the full size of the terminal. We're enabling that below with
 // tea.EnterAltScreen().
 // const useHighPerformanceRenderer = false
-const defaultBufferSize = 256
 
 var (
 	Logger = logging.Logger
@@ -65,21 +68,30 @@ var (
 
 	currentCmdStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFCC66"))
 	checkMark       = lipgloss.NewStyle().Foreground(lipgloss.Color("42")).SetString("✓")
+	crossMark       = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).SetString("✗")
+	cleanupStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("111"))
 )
 
 type ExecutionReady int
 
 type RecipeExecuted string
 
+// executionFailed is sent when the execution of the recipe couldn't be set up.
+type executionFailed struct {
+	summary string
+	err     error
+}
+
 type CommandExecuted string
 
-type CmdOutput struct {
-	cmd    string
-	output string
-	done   bool
-}
+// commandUpToDate is sent instead of a regular CommandExecuted when the step didn't
+// run because the task is up-to-date.
+const commandUpToDate CommandExecuted = "Command skipped, task is up to date"
 
-type bufferReadMsg []byte
+// CommandFailed is sent when the current command failed.
+type CommandFailed struct {
+	Err error
+}
 
 //type SafeStack struct {
 //	mu    sync.Mutex
@@ -126,31 +138,109 @@ type model struct {
 	viewport        viewport.Model
 	error           error
 	keyMap          *keys.KeyMap
-	envDir          string
+	cfg             *config.Config
 	task            *ast.Task
-	execIterator    iter.Iterable[taskexec.Executor]
+	execIterator    taskexec.ExecutorIterator
+	steps           []taskexec.Step
+	step            taskexec.Step
+	cleaningUp      bool
+	cleanupErrors   int
 	index           int
 	total           int
 	done            bool
 	spinner         spinner.Model
 	progress        progress.Model
-	content         string
-	currentCommand  atomic.Pointer[CmdOutput]
-	doneView        string
-	inProgressView  string
+	currentCommand  *section
+	sections        []*section
+	group           *section
+	attempt         *section
+	retries         []task.Attempt
+	wait            *waitStatus
+	groupBegin      *regexp.Regexp
+	groupEnd        *regexp.Regexp
+	scanned         int
+	log             *scrollback.Buffer
+	logOffset       int
+	logHeight       int
+	search          search
+	startedAt       time.Time
+	finishedAt      time.Time
+	durations       []time.Duration
+	estimates       map[string]time.Duration
 	progressBarView string
 	footer          string
-	taskReader      io.Reader
+	events          <-chan taskexec.Event
 	detached        bool
+	previewing      bool
+	cursor          int
+	stepMode        bool
+	paused          bool
+	breakpoints     map[int]bool
+	history         *history.Store
+	record          *history.Run
+	resumeFrom      *history.Run
+	autoStart       bool
+	aborted         bool
+	treeView        bool
+	rawOutput       bool
+	outputs         map[string]string
+	envChanges      []env.Change
+	// envConfirming is set once ApplyEnvKey was pressed, to apply envChanges when
+	// it is pressed again.
+	envConfirming bool
+	runID         string
+	artifactsDir  string
+	workspace     string
+	execution     *runner.Execution
+	// ctx ends the command running when the session of the run is closed.
+	ctx        context.Context
+	cancel     context.CancelFunc
+	treeCursor int
+	focused    int
 }
 
-func New(width, height int, recipe recipes.Recipe, envDir string) tea.Model {
+// New creates the run of the recipe, meant to be started as a session.
+func New(width, height int, recipe recipes.Recipe, cfg *config.Config, opts ...Option) sessions.Runner {
 	m := model{
 		recipe: recipe,
 		width:  width,
 		height: height,
-		keyMap: keys.NewViewportKeyMap(),
-		envDir: envDir,
+		keyMap: keys.NewViewportKeyMap().
+			WithKey(StepModeKey, false).
+			WithKey(BreakpointKey, false).
+			WithKey(ContinueKey, false).
+			WithKey(SkipKey, false).
+			WithKey(AbortKey, false).
+			WithKey(RetryKey, false).
+			WithKey(ResumeKey, false).
+			WithKey(TreeKey, false).
+			WithKey(FocusKey, false).
+			WithKey(SearchKey, false).
+			WithKey(SearchBackwardKey, false).
+			WithKey(NextMatchKey, false).
+			WithKey(PrevMatchKey, false).
+			WithKey(FoldKey, false).
+			WithKey(ExpandAllKey, false).
+			WithKey(CollapseAllKey, false).
+			WithKey(RawOutputKey, false).
+			WithKey(ApplyEnvKey, false).
+			WithKey(ArtifactsKey, false),
+		cfg:         cfg,
+		breakpoints: make(map[int]bool),
+		focused:     -1,
+		runID:       history.NewRunID(),
+		search:      newSearch(),
+		log: scrollback.New(
+			scrollback.WithMaxLines(cfg.ScrollbackLines),
+			scrollback.WithSpillDir(cfg.ScrollbackDir),
+		),
+	}
+	if cfg.HistoryDir != "" {
+		m.history = history.NewStore(cfg.HistoryDir)
+	}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(&m)
 	}
 	headerHeight := lipgloss.Height(m.headerView())
 	footerHeight := lipgloss.Height(m.footerView())
@@ -177,57 +267,37 @@ func (m *model) Init() tea.Cmd {
 
 func (m *model) prepareExecution() tea.Cmd {
 	return func() tea.Msg {
-		pr, pw := io.Pipe()
-		m.taskReader = pr
-		var stdIn bytes.Buffer
-		var stdErr bytes.Buffer
-
-		options := []taskexec.TaskOption{taskexec.WithIO(&stdIn, pw, &stdErr)}
-
-		if m.recipe.Environment != "" {
-			e, err := env.Load(filepath.Join(m.envDir, m.recipe.Environment))
-			if err != nil {
-				m.error = err
-				return RecipeExecuted("Error loading environment: " + err.Error())
-
-			}
-			options = append(options, taskexec.WithEnv(e))
-		}
-		iter, n, err := taskexec.NewExecutorIterator(m.recipe.Dir, options...)
+		x, err := runner.Prepare(m.recipe, runner.Options{
+			Config:     m.cfg,
+			History:    m.history,
+			RunID:      m.runID,
+			ResumeFrom: m.resumeFrom,
+		})
 		if err != nil {
-			m.error = err
-			return RecipeExecuted("Error setting up recipe executor: " + err.Error())
+			return executionFailed{summary: "Error preparing the run: " + err.Error(), err: err}
 		}
-		m.execIterator = iter
-		m.total = n
-		m.task = iter.GetTask()
-		return ExecutionReady(n)
+		m.execution = x
+		m.execIterator = x.Iterator
+		m.artifactsDir = x.ArtifactsDir
+		m.workspace = x.Workspace
+		m.total = x.Steps
+		m.task = x.Iterator.GetTask()
+		m.steps = x.Iterator.Steps()
+		m.loadEstimates()
+		return ExecutionReady(x.Steps)
 	}
 }
 
+// NextCommand runs the next command. Its progress and outcome are reported by the
+// events of the iterator, see waitForEvents.
 func (m *model) NextCommand() tea.Cmd {
-
 	return func() tea.Msg {
-		if !m.execIterator.HasNext() {
-			return RecipeExecuted("Recipe executed successfully")
-		}
-		m.currentCommand.Store(
-			&CmdOutput{
-				cmd: m.task.Cmds[m.index].Cmd,
-			},
-		)
 		e, err := m.execIterator.Next()
 		if err != nil {
-			m.error = err
-			return RecipeExecuted("Error running recipe: " + err.Error())
-		}
-
-		if err := e.Execute(); err != nil {
-			m.error = err
-			return RecipeExecuted("Error running recipe: " + err.Error())
+			return CommandFailed{Err: err}
 		}
-
-		return CommandExecuted("Command executed successfully")
+		_ = e.Execute(m.ctx)
+		return nil
 	}
 }
 
@@ -240,13 +310,42 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if !m.keyMap.Matches(msg, ApplyEnvKey) {
+			// Any other key cancels applying the changes to the environment
+			m.envConfirming = false
+		}
+		if handled, cmd := m.handleSearchKeys(msg); handled {
+			return m, cmd
+		}
+		if handled, cmd := m.handleFoldKeys(msg); handled {
+			return m, cmd
+		}
+		if handled, cmd := m.handleTreeKeys(msg); handled {
+			return m, cmd
+		}
+		if handled, cmd := m.handleStepKeys(msg); handled {
+			return m, cmd
+		}
+		if handled, cmd := m.handleResumeKeys(msg); handled {
+			return m, cmd
+		}
+		if handled, cmd := m.handleEnvKeys(msg); handled {
+			return m, cmd
+		}
+		if handled, cmd := m.handleArtifactsKeys(msg); handled {
+			return m, cmd
+		}
 		switch {
 		case m.keyMap.Matches(msg, keys.Quit) || m.keyMap.Matches(msg, keys.ForceQuit):
 			return m, tea.Quit
 		case m.keyMap.Matches(msg, keys.Cancel):
+			// The run carries on in its session, which releases it once closed
 			return m, navigation.Back()
 		case m.keyMap.Matches(msg, keys.Up) || m.keyMap.Matches(msg, keys.Down) || m.keyMap.Matches(msg, keys.PageUp) || m.keyMap.Matches(msg, keys.PageDown) || m.keyMap.Matches(msg, keys.HalfPageUp) || m.keyMap.Matches(msg, keys.HalfPageDown):
 			m.detached = true
+			if !m.previewing && !m.treeView {
+				m.scrollLog(msg)
+			}
 		// TODO: Add support for attached mode
 		case msg.String() == "a":
 			m.detached = false
@@ -263,48 +362,46 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case ExecutionReady:
 		m.ready = true
-		progressCmd := m.progress.SetPercent(0)
-		cmds = append(cmds, tea.Batch(m.NextCommand(), progressCmd))
+		m.events = m.execIterator.Events()
+		m.setOutputStyle(m.execIterator.GetOutputStyle())
+		cmds = append(cmds, m.waitForEvents())
+		// The preview is only shown when a pause was asked for, so breakpoints can
+		// be set before anything runs, or to pick the step to resume from
+		if m.autoStart || (!m.stepMode && m.resumeFrom == nil) {
+			cmds = append(cmds, m.start(m.cursor))
+			break
+		}
+		m.previewing = true
+	case executionFailed:
+		m.error = msg.err
+		m.footer = msg.summary
+		m.ready = true
+		m.done = true
 	case RecipeExecuted:
+		if m.execIterator != nil {
+			m.execIterator.Close()
+		}
+		m.finishedAt = time.Now()
 		m.footer = string(msg)
 		m.done = true
+		m.previewing = false
+		m.paused = false
+		m.writeOutputs()
+		m.writeArtifacts()
+		m.finishRecord()
+		m.planEnvWrites()
+		m.cleanWorkspace()
 	case CommandExecuted:
-		m.currentCommand.Load().done = true
-		if m.index < m.total {
-			m.index++
-			progressCmd := m.progress.SetPercent(float64(m.index) / float64(m.total))
-			cmds = append(cmds, tea.Batch(m.NextCommand(), progressCmd))
-		}
+		cmds = append(cmds, m.commandExecuted(msg))
+	case CommandFailed:
+		cmds = append(cmds, m.commandFailed(msg.Err))
+	case eventsMsg:
+		cmds = append(cmds, m.handleEvents(msg))
 	case spinner.TickMsg:
 		if !m.done {
-			readMsg := func() tea.Msg {
-				buffer := make([]byte, defaultBufferSize)
-				n, err := m.taskReader.Read(buffer)
-				Logger.Debug("Update :: Received buffer read message", "n", n, "err", err)
-				if err != nil {
-					if err != io.EOF {
-						m.error = err
-					}
-				}
-				return bufferReadMsg(buffer[:n])
-			}
-
-			//buffer := make([]byte, defaultBufferSize)
-			//n, err := m.taskReader.Read(buffer)
-			//Logger.Debug("Update :: Received buffer read message", "n", n, "err", err)
-			//if err != nil {
-			//	if err != io.EOF {
-			//		m.error = err
-			//	}
-			//}
-			//m.lastCommand.output += string(buffer[:n])
 			m.spinner, cmd = m.spinner.Update(msg)
-			return m, tea.Batch(cmd, readMsg)
+			return m, cmd
 		}
-	case bufferReadMsg:
-		buffer := msg
-		n := len(buffer)
-		m.currentCommand.Load().output += string(buffer[:n])
 	case progress.FrameMsg:
 		//if !m.done {
 		newModel, cmd := m.progress.Update(msg)
@@ -332,57 +429,96 @@ func (m *model) View() string {
 }
 
 func (m *model) updateVPContent() {
-	m.updateDoneView()
-	m.updateInProgressView()
+	if m.previewing {
+		m.viewport.SetContent(m.previewView())
+		if m.cursor >= m.viewport.YOffset+m.viewport.Height {
+			m.viewport.SetYOffset(m.cursor - m.viewport.Height + 1)
+		} else if m.cursor < m.viewport.YOffset {
+			m.viewport.SetYOffset(m.cursor)
+		}
+		return
+	}
 	m.updateProgressBarView()
-	log := ""
-	if m.doneView != "" {
-		log = m.doneView
-
+	if m.treeView {
+		m.viewport.SetContent(lipgloss.JoinVertical(lipgloss.Left, m.treeContent(), m.progressBarView, m.footer))
+		if m.focused < 0 {
+			if m.treeCursor >= m.viewport.YOffset+m.viewport.Height {
+				m.viewport.SetYOffset(m.treeCursor - m.viewport.Height + 1)
+			} else if m.treeCursor < m.viewport.YOffset {
+				m.viewport.SetYOffset(m.treeCursor)
+			}
+		}
+		return
 	}
-	if m.inProgressView != "" {
-		log += m.inProgressView
-
+	var parts []string
+	if wait := m.waitView(); wait != "" {
+		parts = append(parts, wait)
+	}
+	if m.paused {
+		parts = append(parts, m.pausedView())
+	}
+	parts = append(parts, m.progressBarView, m.footer)
+	if help := m.resumeHelp(); help != "" {
+		parts = append(parts, help)
+	}
+	if search := m.searchView(); search != "" {
+		parts = append(parts, search)
 	}
-	m.content = lipgloss.JoinVertical(lipgloss.Left, log, m.progressBarView, m.footer)
-	m.viewport.SetContent(m.content)
+	// The progress bar and footer stay at the bottom, only the visible window of
+	// the log is rendered above them however long it gets.
+	tail := lipgloss.JoinVertical(lipgloss.Left, parts...)
+	m.logHeight = max(0, m.viewport.Height-lipgloss.Height(tail))
+	total := m.totalRows()
 	if !m.detached {
-		m.viewport.GotoBottom()
+		m.logOffset = total - m.logHeight
 	}
-}
-
-func (m *model) updateDoneView() {
-	cmd := m.currentCommand.Load()
-	if cmd == nil {
-		return
+	m.logOffset = max(0, min(m.logOffset, total-m.logHeight))
+	lines, lineIndexes, err := m.rows(m.logOffset, m.logHeight)
+	if err != nil {
+		lines = []string{crossMark.Render(err.Error())}
+	}
+	m.highlightMatches(lines, lineIndexes)
+	truncate := lipgloss.NewStyle().MaxWidth(m.viewport.Width)
+	for i, l := range lines {
+		lines[i] = truncate.Render(l)
 	}
-	sb := strings.Builder{}
-	sb.WriteString(m.doneView)
-	if cmd.done {
-		sb.WriteString(m.inProgressView)
-		sb.WriteString(cmd.output)
-		sb.WriteString("\n")
-		sb.WriteString(fmt.Sprintf("%s Done.\n", checkMark))
-		sb.WriteString(fmt.Sprintf("%s\n", strings.Repeat("─", m.width)))
-		m.currentCommand.Store(nil)
-		m.inProgressView = ""
+	for len(lines) < m.logHeight {
+		lines = append(lines, "")
 	}
-	m.doneView = sb.String()
+	m.viewport.SetContent(strings.Join(append(lines, tail), "\n"))
+	m.viewport.GotoTop()
 }
 
-func (m *model) updateInProgressView() {
-	cmd := m.currentCommand.Load()
-	if cmd == nil {
-		return
+// scrollLog scrolls the log according to the given key. Scrolling back to the
+// bottom attaches the view to the output again.
+func (m *model) scrollLog(msg tea.KeyMsg) {
+	switch {
+	case m.keyMap.Matches(msg, keys.Up):
+		m.logOffset--
+	case m.keyMap.Matches(msg, keys.Down):
+		m.logOffset++
+	case m.keyMap.Matches(msg, keys.PageUp):
+		m.logOffset -= m.logHeight
+	case m.keyMap.Matches(msg, keys.PageDown):
+		m.logOffset += m.logHeight
+	case m.keyMap.Matches(msg, keys.HalfPageUp):
+		m.logOffset -= m.logHeight / 2
+	case m.keyMap.Matches(msg, keys.HalfPageDown):
+		m.logOffset += m.logHeight / 2
 	}
-	sb := strings.Builder{}
-	if m.inProgressView == "" {
-		sb.WriteString("- " + currentCmdStyle.Render(cmd.cmd) + "\n\n")
+	bottom := max(0, m.totalRows()-m.logHeight)
+	m.logOffset = max(0, min(m.logOffset, bottom))
+	m.detached = m.logOffset < bottom
+}
+
+// release frees the resources of the run once its session is closed.
+func (m *model) release() {
+	m.cancel()
+	if m.execIterator != nil {
+		// Stop publishing events nobody is going to read anymore
+		m.execIterator.Close()
 	}
-	sb.WriteString(m.inProgressView)
-	sb.WriteString(cmd.output)
-	cmd.output = ""
-	m.inProgressView = sb.String()
+	_ = m.log.Close()
 }
 func (m *model) updateProgressBarView() {
 	//cmd := m.currentCommand.Load()
@@ -392,17 +528,24 @@ func (m *model) updateProgressBarView() {
 	n := m.total
 	w := lipgloss.Width(fmt.Sprintf("%d", n))
 
-	pkgCount := fmt.Sprintf(" %*d/%*d", w, m.index, w, n)
+	pkgCount := fmt.Sprintf(" %*d/%*d", w, m.index, w, n) + m.timingView()
 
 	spin := m.spinner.View() + " "
-	if m.index == m.total {
+	switch {
+	case m.done && m.error != nil:
+		spin = crossMark.String() + " "
+	case m.done || m.index == m.total:
 		spin = checkMark.String() + " "
 	}
 	prog := m.progress.View()
 	cellsAvail := max(0, m.width-lipgloss.Width(spin+prog+pkgCount))
 
 	recipeName := m.recipe.Name
-	info := lipgloss.NewStyle().MaxWidth(cellsAvail).Render("Executing " + recipeName)
+	action := "Executing "
+	if m.cleaningUp {
+		action = "Cleaning up "
+	}
+	info := lipgloss.NewStyle().MaxWidth(cellsAvail).Render(action + recipeName)
 
 	cellsRemaining := max(0, m.width-lipgloss.Width(spin+info+prog+pkgCount))
 	gap := strings.Repeat(" ", cellsRemaining)
@@ -410,6 +553,38 @@ func (m *model) updateProgressBarView() {
 	m.progressBarView = spin + info + gap + prog + pkgCount
 }
 
+// commandExecuted handles the success of the current command.
+func (m *model) commandExecuted(msg CommandExecuted) tea.Cmd {
+	m.endCommand(nil, msg == commandUpToDate)
+	if m.record != nil {
+		status := history.StepSucceeded
+		if msg == commandUpToDate {
+			status = history.StepSkipped
+		}
+		m.record.FinishStep(m.step.Index, status, nil)
+		m.saveRecord()
+	}
+	m.index++
+	progressCmd := m.progress.SetPercent(float64(m.index) / float64(m.total))
+	return tea.Batch(m.advance(), progressCmd)
+}
+
+// finishRecord records the outcome of the run in the history.
+func (m *model) finishRecord() {
+	if m.record == nil || m.record.Status != history.StatusRunning {
+		return
+	}
+	switch {
+	case m.error != nil:
+		m.record.Finish(history.StatusFailed, m.error)
+	case m.aborted:
+		m.record.Finish(history.StatusAborted, nil)
+	default:
+		m.record.Finish(history.StatusSucceeded, nil)
+	}
+	m.saveRecord()
+}
+
 func (m *model) headerView() string {
 	title := titleStyle.Render(m.recipe.DisplayName)
 	line := strings.Repeat("─", max(0, m.width-lipgloss.Width(title)))
@@ -417,7 +592,14 @@ func (m *model) headerView() string {
 }
 
 func (m *model) footerView() string {
-	info := infoStyle.Render(fmt.Sprintf("%3.f%%", m.viewport.ScrollPercent()*100))
+	percent := m.viewport.ScrollPercent()
+	if !m.previewing && !m.treeView {
+		percent = 1
+		if bottom := m.totalRows() - m.logHeight; bottom > 0 {
+			percent = float64(m.logOffset) / float64(bottom)
+		}
+	}
+	info := infoStyle.Render(fmt.Sprintf("%3.f%%", percent*100))
 	line := strings.Repeat("─", max(0, m.width-lipgloss.Width(info)))
 	return lipgloss.JoinHorizontal(lipgloss.Center, line, info)
 }
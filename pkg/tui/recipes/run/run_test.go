@@ -0,0 +1,60 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package run
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/recipes"
+)
+
+// BenchmarkModel_View measures rendering the run view, from the sections of the
+// log down to the viewport. Like the window of the scrollback, it should stay flat
+// as the output grows.
+func BenchmarkModel_View(b *testing.B) {
+	const sections = 10
+	line := strings.Repeat("x", 120) + "\n"
+	for _, size := range []int{1_000, 100_000, 1_000_000} {
+		cfg := &config.Config{ScrollbackDir: b.TempDir()}
+		m := New(120, 50, recipes.Recipe{}, cfg).(*model)
+		m.ready = true
+		m.total = sections
+		for s := 0; s < sections; s++ {
+			m.beginCommand(fmt.Sprintf("task: cmd %d", s), false, time.Now())
+			for i := 0; i < size/sections; i++ {
+				_, _ = m.log.WriteString(line)
+			}
+			m.endCommand(nil, false)
+		}
+		b.Run(fmt.Sprintf("tail/%d", size), func(b *testing.B) {
+			m.detached = false
+			for i := 0; i < b.N; i++ {
+				_ = m.View()
+			}
+		})
+		b.Run(fmt.Sprintf("head/%d", size), func(b *testing.B) {
+			m.detached = true
+			m.logOffset = 0
+			for i := 0; i < b.N; i++ {
+				_ = m.View()
+			}
+		})
+		m.release()
+	}
+}
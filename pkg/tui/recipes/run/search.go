@@ -0,0 +1,188 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package run
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/keys"
+)
+
+var (
+	SearchKey         = keys.NewCustomKey("Search", "/", "Search forward in the output")
+	SearchBackwardKey = keys.NewCustomKey("Search backward", "?", "Search backward in the output")
+	NextMatchKey      = keys.NewCustomKey("Next match", "n", "Jump to the next match")
+	PrevMatchKey      = keys.NewCustomKey("Previous match", "N", "Jump to the previous match")
+
+	matchStyle        = lipgloss.NewStyle().Background(lipgloss.Color("#FFCC66")).Foreground(lipgloss.Color("0"))
+	currentMatchStyle = lipgloss.NewStyle().Background(lipgloss.Color("#FF8800")).Foreground(lipgloss.Color("0")).Bold(true)
+)
+
+// search is the state of a search in the output of the run.
+type search struct {
+	input textinput.Model
+	// typing is true while the pattern is being typed.
+	typing   bool
+	re       *regexp.Regexp
+	backward bool
+	// line is the line of the current match, -1 if there is none.
+	line   int
+	status string
+}
+
+func newSearch() search {
+	input := textinput.New()
+	input.Placeholder = "regular expression"
+	return search{input: input, line: -1}
+}
+
+// handleSearchKeys handles the keys used to search the log. It returns true if the
+// key was consumed and shouldn't be passed to the viewport.
+func (m *model) handleSearchKeys(msg tea.KeyMsg) (bool, tea.Cmd) {
+	if !m.ready || m.previewing || m.treeView {
+		return false, nil
+	}
+	s := &m.search
+	if s.typing {
+		switch msg.Type {
+		case tea.KeyEnter:
+			s.typing = false
+			s.input.Blur()
+			re, err := regexp.Compile(s.input.Value())
+			if err != nil {
+				s.re = nil
+				s.status = crossMark.Render("Invalid pattern: " + err.Error())
+				return true, nil
+			}
+			s.re = re
+			s.line = -1
+			m.findMatch(s.backward)
+		case tea.KeyEsc:
+			s.typing = false
+			s.input.Blur()
+		default:
+			var cmd tea.Cmd
+			s.input, cmd = s.input.Update(msg)
+			return true, cmd
+		}
+		return true, nil
+	}
+	switch {
+	case m.keyMap.Matches(msg, SearchKey), m.keyMap.Matches(msg, SearchBackwardKey):
+		s.typing = true
+		s.backward = m.keyMap.Matches(msg, SearchBackwardKey)
+		s.status = ""
+		s.input.Prompt = "/"
+		if s.backward {
+			s.input.Prompt = "?"
+		}
+		s.input.Reset()
+		return true, s.input.Focus()
+	case s.re != nil && m.keyMap.Matches(msg, NextMatchKey):
+		m.findMatch(s.backward)
+	case s.re != nil && m.keyMap.Matches(msg, PrevMatchKey):
+		m.findMatch(!s.backward)
+	case s.re != nil && m.keyMap.Matches(msg, keys.Cancel):
+		// Clear the search before leaving the view
+		m.search = newSearch()
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// findMatch moves the log to the next match in the given direction, wrapping around
+// the ends of the log. The view is detached so new output doesn't move it away.
+func (m *model) findMatch(backward bool) {
+	s := &m.search
+	match := func(line string) bool {
+		return s.re.MatchString(ansi.Strip(line))
+	}
+	from, wrapFrom := s.line+1, 0
+	switch {
+	case backward && s.line < 0:
+		from, wrapFrom = m.lineAt(m.logOffset+m.logHeight-1), m.log.Len()-1
+	case backward:
+		from, wrapFrom = s.line-1, m.log.Len()-1
+	case s.line < 0:
+		from = m.lineAt(m.logOffset)
+	}
+	line, err := m.log.Find(from, backward, match)
+	s.status = ""
+	if err == nil && line < 0 {
+		line, err = m.log.Find(wrapFrom, backward, match)
+		if backward {
+			s.status = "search hit TOP, continuing at BOTTOM"
+		} else {
+			s.status = "search hit BOTTOM, continuing at TOP"
+		}
+	}
+	switch {
+	case err != nil:
+		s.status = crossMark.Render(err.Error())
+		return
+	case line < 0:
+		s.status = crossMark.Render("Pattern not found: " + s.re.String())
+		return
+	}
+	s.line = line
+	m.detached = true
+	m.logOffset = m.rowOf(line) - m.logHeight/2
+}
+
+// highlightMatches highlights the matches of the search in the given rows of the
+// log, showing the given lines. The styles of the matching lines are dropped.
+func (m *model) highlightMatches(rows []string, lines []int) {
+	if m.search.re == nil {
+		return
+	}
+	for i, l := range rows {
+		if i >= len(lines) || lines[i] < 0 {
+			// Section headers aren't part of the log
+			continue
+		}
+		plain := ansi.Strip(l)
+		if !m.search.re.MatchString(plain) {
+			continue
+		}
+		style := matchStyle
+		if lines[i] == m.search.line {
+			style = currentMatchStyle
+		}
+		rows[i] = m.search.re.ReplaceAllStringFunc(plain, func(s string) string {
+			return style.Render(s)
+		})
+	}
+}
+
+// searchView renders the search prompt or the status of the last search.
+func (m *model) searchView() string {
+	s := m.search
+	switch {
+	case s.typing:
+		return s.input.View()
+	case s.status != "":
+		return s.status
+	case s.re != nil:
+		return skippedStyle.Render(fmt.Sprintf("%s%s • n: next match • N: previous match • esc: clear", s.input.Prompt, s.re))
+	}
+	return ""
+}
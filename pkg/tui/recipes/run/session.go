@@ -0,0 +1,56 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package run
+
+import (
+	"github.com/hypershift-community/hyper-console/pkg/tui/sessions"
+)
+
+// Title describes the run in the sessions panel.
+func (m *model) Title() string {
+	title := m.recipe.DisplayName
+	if title == "" {
+		title = m.recipe.Name
+	}
+	if m.recipe.Environment != "" {
+		title += " @ " + m.recipe.Environment
+	}
+	return title
+}
+
+// Status returns the status of the run in the sessions panel.
+func (m *model) Status() sessions.Status {
+	switch {
+	case m.done && m.error != nil:
+		return sessions.StatusFailed
+	case m.done && m.aborted:
+		return sessions.StatusAborted
+	case m.done:
+		return sessions.StatusSucceeded
+	case !m.ready:
+		// The execution is being set up
+		return sessions.StatusRunning
+	case m.previewing:
+		return sessions.StatusPreview
+	case m.paused:
+		return sessions.StatusPaused
+	}
+	return sessions.StatusRunning
+}
+
+// Close releases the resources of the run once its session is closed.
+func (m *model) Close() {
+	m.release()
+}
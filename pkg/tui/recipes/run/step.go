@@ -0,0 +1,300 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package run
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/hypershift-community/hyper-console/pkg/history"
+	"github.com/hypershift-community/hyper-console/pkg/runner"
+	"github.com/hypershift-community/hyper-console/pkg/taskexec"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/keys"
+)
+
+var (
+	StepModeKey   = keys.NewCustomKey("Step mode", "t", "Toggle step-through execution")
+	BreakpointKey = keys.NewCustomKey("Breakpoint", "b", "Toggle a breakpoint on the selected command")
+	ContinueKey   = keys.NewCustomKey("Continue", "c", "Run the next command")
+	SkipKey       = keys.NewCustomKey("Skip", "s", "Skip the next command")
+	AbortKey      = keys.NewCustomKey("Abort", "x", "Abort the recipe")
+
+	breakpointMark = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).SetString("●")
+	pausedStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFCC66")).Bold(true)
+	skippedStyle   = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#A49FA5", Dark: "#777777"})
+)
+
+// WithStepMode pauses before every command, starting with the preview of the
+// recipe so breakpoints can be set before anything runs.
+func WithStepMode() Option {
+	return func(m *model) {
+		m.stepMode = true
+	}
+}
+
+// handleStepKeys handles the keys used by the preview and step-through execution.
+// It returns true if the key was consumed and shouldn't be passed to the viewport.
+func (m *model) handleStepKeys(msg tea.KeyMsg) (bool, tea.Cmd) {
+	switch {
+	case m.previewing:
+		switch {
+		case m.keyMap.Matches(msg, keys.Up):
+			m.cursor = max(0, m.cursor-1)
+		case m.keyMap.Matches(msg, keys.Down):
+			m.cursor = min(m.total-1, m.cursor+1)
+		case m.keyMap.Matches(msg, BreakpointKey):
+			m.toggleBreakpoint(m.cursor)
+		case m.keyMap.Matches(msg, StepModeKey):
+			m.stepMode = !m.stepMode
+		case m.keyMap.Matches(msg, keys.Enter):
+			startIndex := 0
+			if m.resumeFrom != nil {
+				startIndex = m.cursor
+			}
+			return true, m.start(startIndex)
+		default:
+			return false, nil
+		}
+		return true, nil
+	case m.paused:
+		switch {
+		case m.keyMap.Matches(msg, ContinueKey):
+			m.paused = false
+			return true, m.runNext()
+		case m.keyMap.Matches(msg, SkipKey):
+			return true, m.skipCommand()
+		case m.keyMap.Matches(msg, AbortKey):
+			return true, m.abort()
+		case m.keyMap.Matches(msg, StepModeKey):
+			m.stepMode = !m.stepMode
+			return true, nil
+		case m.keyMap.Matches(msg, BreakpointKey):
+			m.toggleBreakpoint(m.step.Index)
+			return true, nil
+		}
+	case !m.done && m.keyMap.Matches(msg, StepModeKey):
+		// Switching step mode on while a command is running pauses before the next one.
+		m.stepMode = !m.stepMode
+		return true, nil
+	}
+	return false, nil
+}
+
+// start leaves the preview and starts running the recipe from the given command.
+func (m *model) start(startIndex int) tea.Cmd {
+	m.previewing = false
+	if startIndex > 0 {
+		if err := m.execIterator.Seek(startIndex); err != nil {
+			m.error = err
+			return func() tea.Msg {
+				return RecipeExecuted("Error resuming recipe: " + err.Error())
+			}
+		}
+		sb := strings.Builder{}
+		if warning := m.staleWarning(startIndex); warning != "" {
+			sb.WriteString(warning + "\n\n")
+		}
+		for i := 0; i < startIndex; i++ {
+			sb.WriteString(skippedStyle.Render(fmt.Sprintf("- %s (not re-run)", m.steps[i])) + "\n")
+		}
+		sb.WriteString(strings.Repeat("─", m.width) + "\n")
+		_, _ = m.log.WriteString(sb.String())
+	}
+	m.index = startIndex
+	m.startedAt = time.Now()
+	m.durations = make([]time.Duration, m.total)
+	m.startRecord(startIndex)
+	return tea.Batch(m.progress.SetPercent(float64(m.index)/float64(m.total)), m.advance())
+}
+
+// advance either starts the next command or pauses before it when step mode is on
+// or a breakpoint was set on it.
+func (m *model) advance() tea.Cmd {
+	step, ok := m.execIterator.Peek()
+	if !ok {
+		summary := m.summary()
+		return func() tea.Msg {
+			return RecipeExecuted(summary)
+		}
+	}
+	m.step = step
+	if step.Phase == taskexec.PhaseCleanup && !m.cleaningUp {
+		// Deferred commands run last, as a distinct cleanup phase
+		m.cleaningUp = true
+		_, _ = m.log.WriteString(cleanupStyle.Render("Cleanup") + "\n" + strings.Repeat("─", m.width) + "\n")
+	}
+	if m.stepMode || m.breakpoints[step.Index] {
+		m.paused = true
+		return nil
+	}
+	return m.runNext()
+}
+
+// runNext records the next command as running and executes it.
+func (m *model) runNext() tea.Cmd {
+	if m.record != nil {
+		m.record.StartStep(m.step.Index)
+		m.saveRecord()
+	}
+	return m.NextCommand()
+}
+
+// commandFailed handles the failure of the current command. A failure in the main
+// phase fails the recipe but the deferred commands registered so far still run; a
+// failure of a deferred command is reported and the cleanup carries on.
+func (m *model) commandFailed(err error) tea.Cmd {
+	m.endCommand(err, false)
+	if m.record != nil {
+		status := history.StepFailed
+		if runner.TimedOut(err) {
+			status = history.StepTimedOut
+		}
+		m.record.FinishStep(m.step.Index, status, err)
+		m.saveRecord()
+	}
+	if m.step.Phase == taskexec.PhaseCleanup {
+		m.cleanupErrors++
+	} else {
+		m.error = err
+		m.execIterator.Cleanup(err)
+	}
+	m.index++
+	return tea.Batch(m.progress.SetPercent(float64(m.index)/float64(m.total)), m.advance())
+}
+
+// abort stops running the commands of the recipe. The deferred commands registered
+// so far still run unless we are already cleaning up, in which case we stop right away.
+func (m *model) abort() tea.Cmd {
+	m.paused = false
+	m.aborted = true
+	if m.cleaningUp {
+		summary := m.summary()
+		return func() tea.Msg {
+			return RecipeExecuted(summary)
+		}
+	}
+	m.execIterator.Cleanup(nil)
+	return m.advance()
+}
+
+// summary describes the outcome of the run.
+func (m *model) summary() string {
+	var summary string
+	switch {
+	case m.error != nil:
+		summary = "Error running recipe: " + m.error.Error()
+	case m.aborted:
+		summary = "Recipe aborted"
+	default:
+		summary = "Recipe executed successfully"
+	}
+	if m.cleanupErrors > 0 {
+		summary += fmt.Sprintf(" (%d cleanup command(s) failed)", m.cleanupErrors)
+	}
+	return summary + "\n" + m.durationSummary()
+}
+
+// skipCommand moves the iterator past the next command without executing it.
+func (m *model) skipCommand() tea.Cmd {
+	m.paused = false
+	e, err := m.execIterator.Next()
+	if err != nil {
+		m.error = err
+		return func() tea.Msg {
+			return RecipeExecuted("Error running recipe: " + err.Error())
+		}
+	}
+	e.Skip()
+	if m.record != nil {
+		m.record.FinishStep(m.step.Index, history.StepSkipped, nil)
+		m.saveRecord()
+	}
+	_, _ = m.log.WriteString(skippedStyle.Render(fmt.Sprintf("- %s (skipped)", m.step)) + "\n" + strings.Repeat("─", m.width) + "\n")
+	m.index++
+	return tea.Batch(m.progress.SetPercent(float64(m.index)/float64(m.total)), m.advance())
+}
+
+func (m *model) toggleBreakpoint(index int) {
+	if index < 0 || index >= m.total {
+		return
+	}
+	if m.breakpoints[index] {
+		delete(m.breakpoints, index)
+		return
+	}
+	m.breakpoints[index] = true
+}
+
+// previewView renders the list of commands the recipe is going to run so breakpoints
+// can be set before starting it.
+func (m *model) previewView() string {
+	sb := strings.Builder{}
+	if warning := m.staleWarning(m.cursor); warning != "" {
+		sb.WriteString(warning + "\n\n")
+	}
+	for i, s := range m.steps {
+		prefix := "  "
+		if i == m.cursor {
+			prefix = "> "
+		}
+		mark := " "
+		if m.breakpoints[i] {
+			mark = breakpointMark.String()
+		}
+		label := s.String()
+		switch {
+		case i == m.cursor:
+			label = currentCmdStyle.Render(label)
+		case m.resumeFrom != nil && i < m.cursor:
+			label = skippedStyle.Render(label + " (not re-run)")
+		}
+		sb.WriteString(fmt.Sprintf("%s%s %d. %s\n", prefix, mark, i+1, label))
+	}
+	stepMode := "off"
+	if m.stepMode {
+		stepMode = "on"
+	}
+	sb.WriteString(fmt.Sprintf("\nStep mode: %s\n", stepMode))
+	start := "enter: start"
+	if m.resumeFrom != nil {
+		start = "enter: resume from selected step"
+	}
+	sb.WriteString(skippedStyle.Render(start + " • b: toggle breakpoint • t: toggle step mode • esc: back"))
+	return sb.String()
+}
+
+// pausedView renders the prompt shown while execution is paused before a command.
+func (m *model) pausedView() string {
+	if !m.paused {
+		return ""
+	}
+	reason := "Step"
+	if m.breakpoints[m.step.Index] {
+		reason = breakpointMark.String() + " Breakpoint"
+	}
+	label := currentCmdStyle.Render(m.step.String())
+	if m.step.Phase == taskexec.PhaseCleanup {
+		label = cleanupStyle.Render("[cleanup] ") + label
+	}
+	return fmt.Sprintf("%s %s\n%s",
+		pausedStyle.Render(reason+" - paused before:"),
+		label,
+		skippedStyle.Render("c: continue • s: skip • x: abort • t: toggle step mode • b: toggle breakpoint"),
+	)
+}
@@ -0,0 +1,132 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package run
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hypershift-community/hyper-console/pkg/history"
+)
+
+// slowestSteps is the number of steps listed in the summary of the run.
+const slowestSteps = 3
+
+// loadEstimates estimates the duration of the steps from the past runs of the recipe
+// in the same environment.
+func (m *model) loadEstimates() {
+	if m.history == nil {
+		return
+	}
+	runs, err := m.history.List()
+	if err != nil {
+		Logger.Debug("Unable to load the run history", "err", err)
+		return
+	}
+	m.estimates = history.EstimateDurations(runs, m.recipe.Name, m.recipe.Environment)
+}
+
+// elapsed returns how long the recipe has been running, or ran once it is done.
+func (m *model) elapsed() time.Duration {
+	switch {
+	case m.startedAt.IsZero():
+		return 0
+	case !m.finishedAt.IsZero():
+		return m.finishedAt.Sub(m.startedAt)
+	default:
+		return time.Since(m.startedAt)
+	}
+}
+
+// remaining estimates how long the remaining steps are going to take. It returns
+// false when none of them ran successfully before, or when the recipe isn't running
+// its main steps anymore.
+func (m *model) remaining() (time.Duration, bool) {
+	if m.done || m.startedAt.IsZero() || m.cleaningUp || m.error != nil {
+		return 0, false
+	}
+	var remaining time.Duration
+	known := false
+	for i := m.index; i < len(m.steps); i++ {
+		d, ok := m.estimates[m.steps[i].String()]
+		if !ok {
+			continue
+		}
+		known = true
+		if i == m.index && m.currentCommand != nil {
+			d -= time.Since(m.currentCommand.startedAt)
+			if d < 0 {
+				d = 0
+			}
+		}
+		remaining += d
+	}
+	return remaining, known
+}
+
+// recordDuration records how long the current step ran.
+func (m *model) recordDuration(d time.Duration) {
+	if m.step.Index >= 0 && m.step.Index < len(m.durations) {
+		m.durations[m.step.Index] = d
+	}
+}
+
+// timingView renders the elapsed time and the estimated remaining time, if any.
+func (m *model) timingView() string {
+	if m.startedAt.IsZero() {
+		return ""
+	}
+	view := " " + formatDuration(m.elapsed())
+	if remaining, ok := m.remaining(); ok {
+		view += skippedStyle.Render(" ~" + formatDuration(remaining) + " left")
+	}
+	return view
+}
+
+// durationSummary describes how long the recipe took and its slowest steps.
+func (m *model) durationSummary() string {
+	summary := "Took " + formatDuration(m.elapsed())
+	indexes := make([]int, 0, len(m.durations))
+	for i, d := range m.durations {
+		if d > 0 {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		return m.durations[indexes[a]] > m.durations[indexes[b]]
+	})
+	var slowest []string
+	for _, i := range indexes[:min(slowestSteps, len(indexes))] {
+		slowest = append(slowest, fmt.Sprintf("%s (%s)", m.steps[i], formatDuration(m.durations[i])))
+	}
+	if len(slowest) > 0 {
+		summary += ", slowest steps: " + strings.Join(slowest, ", ")
+	}
+	return summary
+}
+
+// formatDuration formats a duration with a precision that depends on its magnitude.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Minute:
+		return d.Round(time.Second).String()
+	case d >= time.Second:
+		return d.Round(100 * time.Millisecond).String()
+	default:
+		return d.Round(time.Millisecond).String()
+	}
+}
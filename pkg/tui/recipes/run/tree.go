@@ -0,0 +1,161 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package run
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/hypershift-community/hyper-console/pkg/runner"
+	"github.com/hypershift-community/hyper-console/pkg/taskexec"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/keys"
+)
+
+var (
+	TreeKey  = keys.NewCustomKey("Tree", "tab", "Toggle the tree of tasks and commands")
+	FocusKey = keys.NewCustomKey("Focus", "enter", "Show the output of the selected node")
+
+	pendingMark  = skippedStyle.SetString("○")
+	skippedMark  = skippedStyle.SetString("↷")
+	upToDateMark = skippedStyle.SetString("✓")
+	parallelMark = cleanupStyle.SetString("⇉")
+	focusStyle   = lipgloss.NewStyle().Bold(true)
+)
+
+// treeLine is a node of the tree flattened for rendering.
+type treeLine struct {
+	node  *taskexec.Node
+	depth int
+}
+
+// handleTreeKeys handles the keys of the tree view. It returns true if the key was
+// consumed and shouldn't be passed to the viewport.
+func (m *model) handleTreeKeys(msg tea.KeyMsg) (bool, tea.Cmd) {
+	if m.previewing || m.execIterator == nil {
+		return false, nil
+	}
+	if m.keyMap.Matches(msg, TreeKey) {
+		m.treeView = !m.treeView
+		m.focused = -1
+		return true, nil
+	}
+	if !m.treeView {
+		return false, nil
+	}
+	switch {
+	case m.focused >= 0 && (m.keyMap.Matches(msg, FocusKey) || m.keyMap.Matches(msg, keys.Cancel)):
+		m.focused = -1
+	case m.focused >= 0:
+		// Scroll the output of the focused node
+		return false, nil
+	case m.keyMap.Matches(msg, keys.Up):
+		m.treeCursor = max(0, m.treeCursor-1)
+	case m.keyMap.Matches(msg, keys.Down):
+		m.treeCursor = min(len(m.treeLines())-1, m.treeCursor+1)
+	case m.keyMap.Matches(msg, FocusKey):
+		if lines := m.treeLines(); m.treeCursor < len(lines) {
+			m.focused = lines[m.treeCursor].node.ID
+			m.viewport.GotoTop()
+		}
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// treeLines returns the nodes of the tree in the order they are rendered.
+func (m *model) treeLines() []treeLine {
+	var lines []treeLine
+	var walk func(nodes []*taskexec.Node, depth int)
+	walk = func(nodes []*taskexec.Node, depth int) {
+		for _, n := range nodes {
+			lines = append(lines, treeLine{node: n, depth: depth})
+			walk(n.Children, depth+1)
+		}
+	}
+	walk(m.execIterator.Tree().Roots(), 0)
+	return lines
+}
+
+// treeContent renders either the tree of tasks and commands or, when a node is
+// focused, its output.
+func (m *model) treeContent() string {
+	tree := m.execIterator.Tree()
+	lines := m.treeLines()
+	if m.focused >= 0 {
+		for _, l := range lines {
+			if l.node.ID == m.focused {
+				return fmt.Sprintf("%s %s\n%s\n%s\n%s",
+					m.nodeMark(l.node),
+					currentCmdStyle.Render(l.node.Label),
+					strings.Repeat("─", m.width),
+					tree.Output(l.node.ID),
+					skippedStyle.Render("enter/esc: back to the tree • tab: back to the log"),
+				)
+			}
+		}
+	}
+	sb := strings.Builder{}
+	for i, l := range lines {
+		prefix := "  "
+		if i == m.treeCursor {
+			prefix = "> "
+		}
+		indent := strings.Repeat("  ", l.depth)
+		if l.node.Parallel {
+			indent += parallelMark.String() + " "
+		}
+		label := l.node.Label
+		if i == m.treeCursor {
+			label = focusStyle.Render(label)
+		}
+		line := fmt.Sprintf("%s%s%s %s", prefix, indent, m.nodeMark(l.node), label)
+		if d := l.node.Duration(); d > 0 {
+			line += skippedStyle.Render(fmt.Sprintf(" (%s)", d.Round(100*time.Millisecond)))
+		}
+		if l.node.Attempts > 0 && l.node.Status == taskexec.NodeRunning {
+			line += skippedStyle.Render(fmt.Sprintf(" attempt %d/%d", l.node.Attempt, l.node.Attempts))
+		}
+		if runner.TimedOut(l.node.Err) {
+			line += skippedStyle.Render(" timed out")
+		}
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString("\n")
+	sb.WriteString(skippedStyle.Render("↑/↓: select • enter: show output • tab: back to the log"))
+	return sb.String()
+}
+
+// nodeMark returns the icon showing the status of a node.
+func (m *model) nodeMark(n *taskexec.Node) string {
+	switch n.Status {
+	case taskexec.NodeRunning:
+		return m.spinner.View()
+	case taskexec.NodeSucceeded:
+		return checkMark.String()
+	case taskexec.NodeFailed:
+		return crossMark.String()
+	case taskexec.NodeSkipped:
+		return skippedMark.String()
+	case taskexec.NodeUpToDate:
+		return upToDateMark.String()
+	default:
+		return pendingMark.String()
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package run
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hypershift-community/hyper-console/pkg/task"
+	"github.com/hypershift-community/hyper-console/pkg/task/taskfile/ast"
+	"github.com/hypershift-community/hyper-console/pkg/taskexec"
+)
+
+// waitStatus is the state of the wait step running. Its checks don't write to the
+// log, their latest outcome is rendered below it instead.
+type waitStatus struct {
+	label     string
+	startedAt time.Time
+	// wait is the configuration of the step, nil for the wait steps of the nested
+	// tasks which are only known once they poll.
+	wait   *ast.Wait
+	poll   task.Poll
+	polled bool
+}
+
+// beginWait starts tracking the wait step which started running, if it is one.
+func (m *model) beginWait(e taskexec.CommandStarted) {
+	if e.Step.Kind != task.StepWait || e.Step.Cmd == nil {
+		return
+	}
+	m.wait = &waitStatus{label: e.Step.Cmd.Cmd, startedAt: e.Time, wait: e.Step.Cmd.Wait}
+}
+
+// conditionPolled records the outcome of the latest check of a wait step.
+func (m *model) conditionPolled(e taskexec.ConditionPolled) {
+	if m.wait == nil {
+		m.wait = &waitStatus{label: e.Step.String(), startedAt: time.Now().Add(-e.Poll.Elapsed)}
+	}
+	m.wait.poll = e.Poll
+	m.wait.polled = true
+}
+
+// endWait writes the outcome of the wait step to the log once it is done.
+func (m *model) endWait(err error) {
+	w := m.wait
+	if w == nil {
+		return
+	}
+	m.wait = nil
+	elapsed := formatDuration(time.Since(w.startedAt))
+	switch {
+	case err == nil && w.polled:
+		_, _ = m.log.WriteString(fmt.Sprintf("Condition met after %s (%d checks): %s\n", elapsed, w.poll.Check, quoteValue(w.poll.Value)))
+	case w.polled:
+		_, _ = m.log.WriteString(fmt.Sprintf("Condition not met after %s (%d checks), last observed: %s\n", elapsed, w.poll.Check, quoteValue(w.poll.Value)))
+	}
+}
+
+// waitInfo summarizes the wait step for the header of its section.
+func (m *model) waitInfo() string {
+	w := m.wait
+	info := fmt.Sprintf("waiting %s", time.Since(w.startedAt).Round(time.Second))
+	if w.polled {
+		info += ", observed " + quoteValue(w.poll.Value)
+	}
+	return info
+}
+
+// waitView renders the latest outcome of the checks of the wait step running, in
+// place of their output.
+func (m *model) waitView() string {
+	w := m.wait
+	if w == nil {
+		return ""
+	}
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("%s Waiting for %s\n", m.spinner.View(), currentCmdStyle.Render(w.label)))
+	observed := skippedStyle.Render("not checked yet")
+	if w.polled {
+		observed = quoteValue(w.poll.Value)
+		if w.poll.Err != nil {
+			observed += " " + crossMark.Render(w.poll.Err.Error())
+		}
+	}
+	sb.WriteString("  Observed: " + observed)
+	if w.wait != nil && w.wait.JSONPath != "" {
+		expected := "any value"
+		if w.wait.Value != "" {
+			expected = quoteValue(w.wait.Value)
+		}
+		sb.WriteString(skippedStyle.Render(fmt.Sprintf(" (expecting %s at %s)", expected, w.wait.JSONPath)))
+	}
+	details := []string{"Elapsed: " + formatDuration(time.Since(w.startedAt))}
+	if w.polled {
+		details = append(details, fmt.Sprintf("%d checks", w.poll.Check))
+	}
+	if w.wait != nil {
+		details = append(details, "every "+formatDuration(w.wait.Interval))
+	}
+	sb.WriteString("\n  " + strings.Join(details, " • "))
+	return sb.String()
+}
+
+func quoteValue(v string) string {
+	if v == "" {
+		return "(empty)"
+	}
+	return fmt.Sprintf("%q", v)
+}
@@ -0,0 +1,30 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package run
+
+import (
+	"fmt"
+)
+
+// cleanWorkspace removes the workspace of the run once it is over unless the
+// retention of the recipe keeps it, see runner.Execution.Finish.
+func (m *model) cleanWorkspace() {
+	if m.execution == nil {
+		return
+	}
+	if m.execution.Finish(m.error == nil && !m.aborted) {
+		_, _ = m.log.WriteString(fmt.Sprintf("\nWorkspace kept in %s\n", m.workspace))
+	}
+}
@@ -0,0 +1,202 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sessions
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/keys"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/navigation"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/simplelist"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/styles"
+)
+
+var (
+	// PanelKey opens the sessions panel from any view.
+	PanelKey = keys.NewCustomKey("Sessions", "ctrl+s", "Show the running and finished sessions")
+
+	badges = map[Status]lipgloss.Style{
+		StatusPreview:   lipgloss.NewStyle().Foreground(lipgloss.Color("241")),
+		StatusRunning:   lipgloss.NewStyle().Foreground(lipgloss.Color("63")),
+		StatusPaused:    lipgloss.NewStyle().Foreground(lipgloss.Color("214")),
+		StatusSucceeded: lipgloss.NewStyle().Foreground(lipgloss.Color("42")),
+		StatusFailed:    lipgloss.NewStyle().Foreground(lipgloss.Color("196")),
+		StatusAborted:   lipgloss.NewStyle().Foreground(lipgloss.Color("214")),
+	}
+	badgeMarks = map[Status]string{
+		StatusPreview:   "○",
+		StatusRunning:   "●",
+		StatusPaused:    "‖",
+		StatusSucceeded: "✓",
+		StatusFailed:    "✗",
+		StatusAborted:   "-",
+	}
+)
+
+// AttachMessage asks for the view of a session.
+type AttachMessage struct {
+	ID int
+}
+
+// CloseMessage asks for a session to be closed.
+type CloseMessage struct {
+	ID int
+}
+
+type tickMessage time.Time
+
+// Badge renders the status of a session.
+func Badge(s Status) string {
+	return badges[s].Render(badgeMarks[s] + " " + string(s))
+}
+
+// Panel lists the sessions.
+type Panel struct {
+	list    list.Model
+	manager *Manager
+	keyMap  *keys.KeyMap
+	// status reports the outcome of the last action on a session.
+	status string
+}
+
+// NewPanel creates the panel listing the sessions of the manager.
+func NewPanel(windowWidth, windowHeight int, manager *Manager) tea.Model {
+	defaultStyles := styles.DefaultStyles()
+	keyMap := keys.NewListKeyMap().
+		WithKey(keys.Delete, true).
+		WithKey(keys.Cancel, false)
+
+	l := simplelist.NewList(keyMap, &defaultStyles, windowWidth, windowHeight)
+
+	l.Title = "Sessions"
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(false)
+	l.Styles.PaginationStyle = defaultStyles.Pagination
+	l.Styles.HelpStyle = defaultStyles.Help
+
+	p := &Panel{
+		list:    l,
+		manager: manager,
+		keyMap:  keyMap,
+	}
+	p.setItems()
+	return p
+}
+
+func (p *Panel) Init() tea.Cmd {
+	return tick()
+}
+
+// tick refreshes the status of the sessions.
+func tick() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
+		return tickMessage(t)
+	})
+}
+
+func (p *Panel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		p.list.SetWidth(msg.Width)
+		p.list.SetHeight(msg.Height)
+		return p, nil
+	case tickMessage:
+		p.setItems()
+		return p, tick()
+	case tea.KeyMsg:
+		switch {
+		case p.keyMap.Matches(msg, keys.Enter):
+			cmd = p.attachCmd()
+		case p.keyMap.Matches(msg, keys.Delete):
+			cmd = p.closeCmd()
+		case p.keyMap.Matches(msg, keys.Cancel):
+			return p, navigation.Back()
+		}
+		cmds = append(cmds, cmd)
+	case CloseMessage:
+		p.setItems()
+	}
+
+	p.list, cmd = p.list.Update(msg)
+	cmds = append(cmds, cmd)
+	return p, tea.Batch(cmds...)
+}
+
+func (p *Panel) View() string {
+	if len(p.manager.List()) == 0 {
+		return "\nNo sessions. Running a recipe starts one."
+	}
+	if p.status != "" {
+		return "\n" + p.list.View() + "\n" + p.status
+	}
+	return "\n" + p.list.View()
+}
+
+func (p *Panel) setItems() {
+	sessions := p.manager.List()
+	items := make([]list.Item, len(sessions))
+	for i, s := range sessions {
+		items[i] = &simplelist.Item{
+			Name: fmt.Sprintf("#%d %s", s.ID, s.Runner.Title()),
+			Description: fmt.Sprintf("%s, started %s ago", Badge(s.Runner.Status()),
+				time.Since(s.StartedAt).Round(time.Second)),
+		}
+	}
+	p.list.SetItems(items)
+}
+
+func (p *Panel) selected() *Session {
+	sessions := p.manager.List()
+	if i := p.list.Cursor(); i < len(sessions) {
+		return sessions[i]
+	}
+	return nil
+}
+
+func (p *Panel) attachCmd() tea.Cmd {
+	s := p.selected()
+	if s == nil {
+		return nil
+	}
+	p.status = ""
+	return func() tea.Msg {
+		return AttachMessage{ID: s.ID}
+	}
+}
+
+// closeCmd closes the selected session, unless it is running: closing it would
+// leave its commands, and the deferred ones, unattended.
+func (p *Panel) closeCmd() tea.Cmd {
+	s := p.selected()
+	if s == nil {
+		return nil
+	}
+	if status := s.Runner.Status(); status != StatusPreview && !status.Done() {
+		p.status = fmt.Sprintf("Session #%d is %s, abort it before closing it.", s.ID, status)
+		return nil
+	}
+	p.status = ""
+	return func() tea.Msg {
+		return CloseMessage{ID: s.ID}
+	}
+}
@@ -0,0 +1,177 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sessions manages the runs of recipes independently of the views on
+// screen. A run is a session which keeps running when its view is left, so several
+// recipes can run at the same time while browsing other screens, and which can be
+// attached again until it is closed.
+//
+// The messages of the commands of a session are tagged with its ID, so they reach
+// its model whichever view is on screen.
+package sessions
+
+import (
+	"reflect"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/hypershift-community/hyper-console/pkg/logging"
+)
+
+var Logger = logging.Logger
+
+// Status is the status of a session.
+type Status string
+
+const (
+	// StatusPreview is the status of the runs waiting to be started.
+	StatusPreview   Status = "preview"
+	StatusRunning   Status = "running"
+	StatusPaused    Status = "paused"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusAborted   Status = "aborted"
+)
+
+// Done returns whether the run of the session is over.
+func (s Status) Done() bool {
+	return s == StatusSucceeded || s == StatusFailed || s == StatusAborted
+}
+
+// Runner is the model of a session.
+type Runner interface {
+	tea.Model
+	// Title describes what the session runs.
+	Title() string
+	// Status returns the status of the run.
+	Status() Status
+	// Close releases the resources of the run once the session is closed.
+	Close()
+}
+
+// Session is a run managed outside of the views.
+type Session struct {
+	ID        int
+	Runner    Runner
+	StartedAt time.Time
+}
+
+// Message is a message of a command of a session.
+type Message struct {
+	ID  int
+	Msg tea.Msg
+}
+
+// Manager keeps track of the sessions. It is only used from the update loop of the
+// program, like the models.
+type Manager struct {
+	sessions []*Session
+	nextID   int
+}
+
+func NewManager() *Manager {
+	return &Manager{nextID: 1}
+}
+
+// Start adds a session running the given model and returns the command initializing
+// it.
+func (m *Manager) Start(r Runner) (*Session, tea.Cmd) {
+	s := &Session{ID: m.nextID, Runner: r, StartedAt: time.Now()}
+	m.nextID++
+	m.sessions = append(m.sessions, s)
+	Logger.Debug("Session started", "session", s.ID, "title", r.Title())
+	return s, wrap(s.ID, r.Init())
+}
+
+// Get returns the session with the given ID, nil if it was closed.
+func (m *Manager) Get(id int) *Session {
+	for _, s := range m.sessions {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// List returns the sessions, in the order they were started.
+func (m *Manager) List() []*Session {
+	return m.sessions
+}
+
+// Update passes the message to the model of the session.
+func (m *Manager) Update(id int, msg tea.Msg) tea.Cmd {
+	s := m.Get(id)
+	if s == nil {
+		// The session was closed while one of its commands was running
+		return nil
+	}
+	model, cmd := s.Runner.Update(msg)
+	if r, ok := model.(Runner); ok {
+		s.Runner = r
+	}
+	return wrap(id, cmd)
+}
+
+// Close closes the session and releases its resources.
+func (m *Manager) Close(id int) {
+	for i, s := range m.sessions {
+		if s.ID == id {
+			s.Runner.Close()
+			m.sessions = append(m.sessions[:i], m.sessions[i+1:]...)
+			Logger.Debug("Session closed", "session", id)
+			return
+		}
+	}
+}
+
+var cmdType = reflect.TypeOf(tea.Cmd(nil))
+
+// wrap tags the messages of the command with the ID of the session. The messages
+// the program handles itself are passed through, with the commands they carry
+// wrapped in turn.
+func wrap(id int, cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		msg := cmd()
+		switch msg := msg.(type) {
+		case nil:
+			return nil
+		case tea.QuitMsg:
+			return msg
+		case tea.BatchMsg:
+			cmds := make(tea.BatchMsg, len(msg))
+			for i, c := range msg {
+				cmds[i] = wrap(id, c)
+			}
+			return cmds
+		}
+		t := reflect.TypeOf(msg)
+		if t.PkgPath() != reflect.TypeOf(tea.QuitMsg{}).PkgPath() {
+			return Message{ID: id, Msg: msg}
+		}
+		// The unexported messages of the program, e.g. the sequences of commands
+		// which can only be identified by their type.
+		if v := reflect.ValueOf(msg); t.Kind() == reflect.Slice && t.Elem() == cmdType {
+			cmds := make([]tea.Cmd, v.Len())
+			for i := range cmds {
+				cmds[i] = wrap(id, v.Index(i).Interface().(tea.Cmd))
+			}
+			return tea.Sequence(cmds...)()
+		}
+		return msg
+	}
+}
@@ -0,0 +1,51 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sessions
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// View shows a session. Leaving the view detaches the session, which keeps running.
+type View struct {
+	manager *Manager
+	id      int
+}
+
+// Attach returns the view of the session with the given ID.
+func (m *Manager) Attach(id int) *View {
+	return &View{manager: m, id: id}
+}
+
+// ID returns the ID of the session shown.
+func (v *View) ID() int {
+	return v.id
+}
+
+func (v *View) Init() tea.Cmd {
+	return nil
+}
+
+func (v *View) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	return v, v.manager.Update(v.id, msg)
+}
+
+func (v *View) View() string {
+	s := v.manager.Get(v.id)
+	if s == nil {
+		return "\nSession closed."
+	}
+	return s.Runner.View()
+}
@@ -0,0 +1,323 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/history"
+	"github.com/hypershift-community/hyper-console/pkg/recipes"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/keys"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/navigation"
+	"github.com/hypershift-community/hyper-console/pkg/workflow"
+)
+
+var (
+	AbortKey        = keys.NewCustomKey("Abort", "x", "Abort the running nodes, their deferred commands still run, and skip the others")
+	ResumeFailedKey = keys.NewCustomKey("Resume", "R", "Resume the run from the nodes which didn't succeed")
+
+	titleStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("63"))
+	headerStyle   = lipgloss.NewStyle().Bold(true)
+	selectedStyle = lipgloss.NewStyle().Reverse(true)
+	helpStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	errorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+
+	nodeMarks = map[workflow.NodeStatus]string{
+		workflow.NodePending:   lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render("·"),
+		workflow.NodeRunning:   lipgloss.NewStyle().Foreground(lipgloss.Color("63")).Render("●"),
+		workflow.NodeSucceeded: lipgloss.NewStyle().Foreground(lipgloss.Color("42")).Render("✓"),
+		workflow.NodeFailed:    lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Render("✗"),
+		workflow.NodeSkipped:   lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Render("-"),
+	}
+)
+
+// updatedMessage is sent when the run of a node changed, or once the workflow is
+// done when the updates are closed.
+type updatedMessage struct {
+	closed bool
+}
+
+type tickMessage time.Time
+
+type runModel struct {
+	workflow workflow.Workflow
+	executor *workflow.Executor
+	// err is set when the workflow can't run, e.g. it isn't a valid DAG.
+	err    error
+	cancel context.CancelFunc
+	run    *workflow.Run
+	depths map[string]int
+	keyMap *keys.KeyMap
+	width  int
+	height int
+	row    int
+	// detail is set while the output of the selected node is shown.
+	detail   bool
+	viewport viewport.Model
+	done     bool
+}
+
+// NewRun creates the view running the workflow, resuming the given run of it if
+// any.
+func NewRun(width, height int, w workflow.Workflow, resumeFrom *workflow.Run, cfg *config.Config) tea.Model {
+	m := &runModel{
+		workflow: w,
+		keyMap: keys.NewViewportKeyMap().
+			WithKey(AbortKey, true).
+			WithKey(ResumeFailedKey, true).
+			WithKey(keys.ForceQuit, false),
+		width:    width,
+		height:   height,
+		viewport: viewport.New(width, max(height-4, 1)),
+	}
+	all, err := recipes.GetRecipes(cfg.RecipesDir)
+	if err == nil {
+		m.depths, err = w.Depths()
+	}
+	if err == nil {
+		m.executor, err = workflow.NewExecutor(&w, all, workflow.Options{
+			Config:      cfg,
+			Parallelism: len(w.Nodes),
+			ResumeFrom:  resumeFrom,
+		})
+	}
+	if err != nil {
+		m.err = err
+		m.done = true
+		return m
+	}
+	m.run = m.executor.Snapshot()
+	return m
+}
+
+func (m *runModel) Init() tea.Cmd {
+	if m.executor == nil {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	run := func() tea.Msg {
+		m.executor.Run(ctx)
+		return nil
+	}
+	return tea.Batch(run, m.waitForUpdates(), tick())
+}
+
+// waitForUpdates waits for the run of a node to change.
+func (m *runModel) waitForUpdates() tea.Cmd {
+	updates := m.executor.Updates()
+	return func() tea.Msg {
+		_, ok := <-updates
+		return updatedMessage{closed: !ok}
+	}
+}
+
+// tick refreshes the durations of the nodes while they are running.
+func tick() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
+		return tickMessage(t)
+	})
+}
+
+func (m *runModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.viewport.Width = msg.Width
+		m.viewport.Height = max(msg.Height-4, 1)
+		m.refreshDetail()
+		return m, nil
+	case updatedMessage:
+		m.run = m.executor.Snapshot()
+		m.refreshDetail()
+		if msg.closed {
+			m.done = true
+			return m, nil
+		}
+		return m, m.waitForUpdates()
+	case tickMessage:
+		if m.done {
+			return m, nil
+		}
+		m.run = m.executor.Snapshot()
+		m.refreshDetail()
+		return m, tick()
+	case tea.KeyMsg:
+		return m, m.handleKeys(msg)
+	}
+	return m, nil
+}
+
+func (m *runModel) handleKeys(msg tea.KeyMsg) tea.Cmd {
+	switch {
+	case m.keyMap.Matches(msg, keys.Quit) || m.keyMap.Matches(msg, keys.ForceQuit):
+		if m.cancel != nil {
+			m.cancel()
+		}
+		return tea.Quit
+	case m.keyMap.Matches(msg, AbortKey):
+		if m.cancel != nil {
+			m.cancel()
+		}
+		return nil
+	case m.detail && (m.keyMap.Matches(msg, keys.Cancel) || m.keyMap.Matches(msg, keys.Enter)):
+		m.detail = false
+		return nil
+	case m.detail:
+		var cmd tea.Cmd
+		m.viewport, cmd = m.viewport.Update(msg)
+		return cmd
+	case m.keyMap.Matches(msg, keys.Cancel):
+		if !m.done {
+			// Leaving would leave the nodes unattended, the run must be aborted first
+			return nil
+		}
+		return navigation.Back()
+	case m.keyMap.Matches(msg, ResumeFailedKey):
+		if !m.done || m.run == nil || m.run.Status == history.StatusSucceeded {
+			return nil
+		}
+		resume := RunMessage{Workflow: m.workflow, ResumeFrom: m.run, Replace: true}
+		return func() tea.Msg {
+			return resume
+		}
+	case m.run == nil:
+		return nil
+	case m.keyMap.Matches(msg, keys.Up):
+		m.row = max(m.row-1, 0)
+	case m.keyMap.Matches(msg, keys.Down):
+		m.row = min(m.row+1, max(len(m.executor.Order())-1, 0))
+	case m.keyMap.Matches(msg, keys.Enter):
+		m.detail = true
+		m.refreshDetail()
+		m.viewport.GotoBottom()
+	}
+	return nil
+}
+
+// refreshDetail refreshes the output of the selected node while it is shown.
+func (m *runModel) refreshDetail() {
+	if !m.detail {
+		return
+	}
+	name := m.executor.Order()[m.row]
+	content := strings.Join(m.executor.Output(name), "\n")
+	if n := m.run.Nodes[name]; n.Error != "" {
+		content += "\n" + errorStyle.Render(n.Error)
+	}
+	if content == "" {
+		content = "No output."
+	}
+	atBottom := m.viewport.AtBottom()
+	m.viewport.SetContent(content)
+	if atBottom {
+		m.viewport.GotoBottom()
+	}
+}
+
+func (m *runModel) View() string {
+	var sb strings.Builder
+	title := m.workflow.DisplayName
+	if title == "" {
+		title = m.workflow.Name
+	}
+	sb.WriteString("\n" + titleStyle.Render("Workflow "+title) + "\n\n")
+	if m.err != nil {
+		sb.WriteString(errorStyle.Render("Unable to run the workflow: "+m.err.Error()) + "\n\n")
+		sb.WriteString(helpStyle.Render("esc back"))
+		return sb.String()
+	}
+	if m.detail {
+		name := m.executor.Order()[m.row]
+		sb.WriteString(headerStyle.Render(fmt.Sprintf("[%s] output", name)) + "\n")
+		sb.WriteString(m.viewport.View() + "\n")
+		sb.WriteString(helpStyle.Render("esc back • ↑/↓ scroll"))
+		return sb.String()
+	}
+	sb.WriteString(m.nodesView() + "\n\n")
+	sb.WriteString(m.summaryView() + "\n")
+	help := "↑/↓ select • enter output • x abort"
+	if m.done {
+		if m.run.Status != history.StatusSucceeded {
+			help += " • R resume"
+		}
+		help += " • esc back"
+	}
+	sb.WriteString(helpStyle.Render(help))
+	return sb.String()
+}
+
+// nodesView renders a row per node, in topological order and indented by depth,
+// with its status, where it runs, its progress and its duration.
+func (m *runModel) nodesView() string {
+	order := m.executor.Order()
+	labels := make([]string, len(order))
+	labelWidth := 0
+	for i, name := range order {
+		labels[i] = strings.Repeat("  ", m.depths[name]) + name
+		labelWidth = max(labelWidth, lipgloss.Width(labels[i]))
+	}
+	var sb strings.Builder
+	for i, name := range order {
+		n := m.run.Nodes[name]
+		node, _ := m.workflow.Node(name)
+		line := fmt.Sprintf("%s %-*s  %s@%s", nodeMarks[n.Status], labelWidth, labels[i], node.Recipe, m.workflow.EnvironmentOf(node))
+		if n.Steps > 0 && n.Status == workflow.NodeRunning {
+			line += fmt.Sprintf("  step %d/%d", n.Step+1, n.Steps)
+		}
+		if d := n.Duration(); d > 0 {
+			line += "  " + d.Round(time.Second).String()
+		}
+		if i == m.row {
+			line = selectedStyle.Render(line)
+		}
+		if n.Error != "" {
+			line += "  " + errorStyle.Render(n.Error)
+		}
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+func (m *runModel) summaryView() string {
+	counts := make(map[workflow.NodeStatus]int)
+	for _, n := range m.run.Nodes {
+		counts[n.Status]++
+	}
+	summary := fmt.Sprintf("%d succeeded, %d failed, %d skipped",
+		counts[workflow.NodeSucceeded], counts[workflow.NodeFailed], counts[workflow.NodeSkipped])
+	if running := counts[workflow.NodeRunning]; running > 0 {
+		summary = fmt.Sprintf("%d running, %s", running, summary)
+	}
+	if m.run.ResumedFrom != "" {
+		summary += fmt.Sprintf(" (resumes run %s)", m.run.ResumedFrom)
+	}
+	if m.done {
+		summary = fmt.Sprintf("Done (%s): %s", m.run.Status, summary)
+	}
+	return summary
+}
@@ -0,0 +1,176 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/history"
+	"github.com/hypershift-community/hyper-console/pkg/logging"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/keys"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/navigation"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/simplelist"
+	"github.com/hypershift-community/hyper-console/pkg/tui/lib/styles"
+	"github.com/hypershift-community/hyper-console/pkg/workflow"
+)
+
+var (
+	Logger = logging.Logger
+
+	ResumeKey = keys.NewCustomKey("Resume last run", "r", "Resume the last run of the selected workflow if it didn't succeed")
+)
+
+// RunMessage asks for a run of a workflow.
+type RunMessage struct {
+	Workflow workflow.Workflow
+	// ResumeFrom is the run of the workflow resumed, if any.
+	ResumeFrom *workflow.Run
+	// Replace replaces the current view instead of opening a new one on top of it.
+	Replace bool
+}
+
+type workflowsLoadedMessage []workflow.Workflow
+
+// Model lists the workflows.
+type Model struct {
+	list        list.Model
+	cfg         *config.Config
+	workflows   []workflow.Workflow
+	keyMap      *keys.KeyMap
+	initialized bool
+	err         error
+	// status reports the outcome of the last action on a workflow.
+	status string
+}
+
+func New(windowWidth int, windowHeight int, cfg *config.Config) tea.Model {
+	defaultStyles := styles.DefaultStyles()
+	keyMap := keys.NewListKeyMap().
+		WithKey(ResumeKey, true).
+		WithKey(keys.Cancel, false)
+
+	l := simplelist.NewList(keyMap, &defaultStyles, windowWidth, windowHeight)
+
+	l.Title = "Workflows"
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(false)
+	l.Styles.PaginationStyle = defaultStyles.Pagination
+	l.Styles.HelpStyle = defaultStyles.Help
+
+	return &Model{
+		list:   l,
+		cfg:    cfg,
+		keyMap: keyMap,
+	}
+}
+
+func (m *Model) Init() tea.Cmd {
+	return func() tea.Msg {
+		Logger.Debug("Loading workflows")
+		workflows, err := workflow.Load(m.cfg.WorkflowsDir)
+		if err != nil {
+			m.err = err
+			return nil
+		}
+		return workflowsLoadedMessage(workflows)
+	}
+}
+
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.list.SetWidth(msg.Width)
+		m.list.SetHeight(msg.Height)
+		return m, nil
+	case tea.KeyMsg:
+		switch {
+		case m.keyMap.Matches(msg, keys.Enter):
+			cmd = m.runCmd(false)
+		case m.keyMap.Matches(msg, ResumeKey):
+			cmd = m.runCmd(true)
+		case m.keyMap.Matches(msg, keys.Cancel):
+			return m, navigation.Back()
+		}
+		cmds = append(cmds, cmd)
+	case workflowsLoadedMessage:
+		m.workflows = msg
+		items := make([]list.Item, len(m.workflows))
+		for i, w := range m.workflows {
+			name := w.DisplayName
+			if name == "" {
+				name = w.Name
+			}
+			desc := fmt.Sprintf("%d node(s)", len(w.Nodes))
+			if w.Description != "" {
+				desc = fmt.Sprintf("%s, %s", w.Description, desc)
+			}
+			items[i] = &simplelist.Item{Name: name, Description: desc}
+		}
+		m.list.SetItems(items)
+		m.initialized = true
+	}
+
+	m.list, cmd = m.list.Update(msg)
+	cmds = append(cmds, cmd)
+	return m, tea.Batch(cmds...)
+}
+
+func (m *Model) View() string {
+	if len(m.workflows) == 0 {
+		if m.err != nil {
+			return "\nError loading workflows: " + m.err.Error()
+		}
+		if m.initialized {
+			return "\nNo workflows found in " + m.cfg.WorkflowsDir
+		}
+		return "\nLoading workflows..."
+	}
+	if m.status != "" {
+		return "\n" + m.list.View() + "\n" + m.status
+	}
+	return "\n" + m.list.View()
+}
+
+// runCmd runs the selected workflow, or resumes its last run.
+func (m *Model) runCmd(resume bool) tea.Cmd {
+	if len(m.workflows) == 0 {
+		return nil
+	}
+	w := m.workflows[m.list.Cursor()]
+	msg := RunMessage{Workflow: w}
+	if resume {
+		runs, err := workflow.NewStore(m.cfg.HistoryDir).List(w.Name)
+		if err != nil {
+			m.status = "Unable to load the runs of the workflow: " + err.Error()
+			return nil
+		}
+		if len(runs) == 0 || runs[0].Status == history.StatusSucceeded || runs[0].Status == history.StatusRunning {
+			m.status = "The last run of the workflow has nothing to resume."
+			return nil
+		}
+		msg.ResumeFrom = runs[0]
+	}
+	m.status = ""
+	return func() tea.Msg {
+		return msg
+	}
+}
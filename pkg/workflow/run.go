@@ -0,0 +1,428 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/history"
+	"github.com/hypershift-community/hyper-console/pkg/logging"
+	"github.com/hypershift-community/hyper-console/pkg/recipes"
+	"github.com/hypershift-community/hyper-console/pkg/runner"
+	"github.com/hypershift-community/hyper-console/pkg/scrollback"
+)
+
+var Logger = logging.Logger
+
+// OutputLines is the number of lines of the output of each node kept, the older
+// ones are dropped.
+const OutputLines = 1000
+
+// NodeStatus is the status of a node in a run of a workflow.
+type NodeStatus string
+
+const (
+	NodePending   NodeStatus = "pending"
+	NodeRunning   NodeStatus = "running"
+	NodeSucceeded NodeStatus = "succeeded"
+	NodeFailed    NodeStatus = "failed"
+	// NodeSkipped is the status of the nodes which didn't run because a node they
+	// depend on didn't succeed, or the run was aborted.
+	NodeSkipped NodeStatus = "skipped"
+)
+
+// NodeRun is the run of a node of a workflow.
+type NodeRun struct {
+	Status NodeStatus `json:"status"`
+	// RunID is the ID of the run of the recipe in the run history.
+	RunID      string            `json:"runId,omitempty"`
+	StartedAt  time.Time         `json:"startedAt,omitempty"`
+	FinishedAt time.Time         `json:"finishedAt,omitempty"`
+	Outputs    map[string]string `json:"outputs,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	// Steps and Step are the number of steps of the recipe and the index of the
+	// step running, or which ran last.
+	Steps int `json:"steps,omitempty"`
+	Step  int `json:"step,omitempty"`
+}
+
+// Duration returns how long the node ran so far.
+func (n NodeRun) Duration() time.Duration {
+	switch {
+	case n.StartedAt.IsZero():
+		return 0
+	case n.FinishedAt.IsZero():
+		return time.Since(n.StartedAt)
+	}
+	return n.FinishedAt.Sub(n.StartedAt)
+}
+
+// Run is the record of a run of a workflow.
+type Run struct {
+	ID          string              `json:"id"`
+	Workflow    string              `json:"workflow"`
+	Status      history.Status      `json:"status"`
+	StartedAt   time.Time           `json:"startedAt"`
+	FinishedAt  time.Time           `json:"finishedAt,omitempty"`
+	ResumedFrom string              `json:"resumedFrom,omitempty"`
+	Nodes       map[string]*NodeRun `json:"nodes"`
+}
+
+// Clone returns a deep copy of the run.
+func (r *Run) Clone() *Run {
+	c := *r
+	c.Nodes = make(map[string]*NodeRun, len(r.Nodes))
+	for name, n := range r.Nodes {
+		nc := *n
+		nc.Outputs = maps.Clone(n.Outputs)
+		c.Nodes[name] = &nc
+	}
+	return &c
+}
+
+// Options configures the execution of a workflow.
+type Options struct {
+	Config *config.Config
+	// Parallelism is the maximum number of nodes running at the same time, the
+	// nodes are run one at a time when it is 1 or less.
+	Parallelism int
+	// ResumeFrom is a previous run of the workflow which didn't succeed. The nodes
+	// which succeeded in it are not run again, their outputs are reused, and the
+	// nodes which failed resume their recipe from the step which failed.
+	ResumeFrom *Run
+}
+
+// Executor runs a workflow.
+type Executor struct {
+	workflow *Workflow
+	order    []string
+	recipes  map[string]recipes.Recipe
+	opts     Options
+	history  *history.Store
+	store    *Store
+
+	mu      sync.Mutex
+	run     *Run
+	output  map[string]*scrollback.Buffer
+	updates chan string
+	// saveMu serializes the writes of the record of the run.
+	saveMu sync.Mutex
+}
+
+// NewExecutor creates an executor for the workflow running the given recipes. It
+// fails if the workflow isn't a valid DAG or refers to unknown recipes.
+func NewExecutor(w *Workflow, all []recipes.Recipe, opts Options) (*Executor, error) {
+	order, err := w.Order()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]recipes.Recipe, len(all))
+	for _, r := range all {
+		byName[r.Name] = r
+	}
+	nodeRecipes := make(map[string]recipes.Recipe, len(w.Nodes))
+	for _, n := range w.Nodes {
+		r, ok := byName[n.Recipe]
+		if !ok {
+			return nil, fmt.Errorf("node %s of workflow %s runs unknown recipe %s", n.Name, w.Name, n.Recipe)
+		}
+		r.Environment = w.EnvironmentOf(&n)
+		nodeRecipes[n.Name] = r
+	}
+	e := &Executor{
+		workflow: w,
+		order:    order,
+		recipes:  nodeRecipes,
+		opts:     opts,
+		output:   make(map[string]*scrollback.Buffer),
+		// Updates are dropped rather than blocking the nodes when nobody reads them
+		updates: make(chan string, 64),
+	}
+	if opts.Config != nil && opts.Config.HistoryDir != "" {
+		e.history = history.NewStore(opts.Config.HistoryDir)
+		e.store = NewStore(opts.Config.HistoryDir)
+	}
+	e.run = &Run{
+		ID:        history.NewRunID(),
+		Workflow:  w.Name,
+		Status:    history.StatusRunning,
+		StartedAt: time.Now(),
+		Nodes:     make(map[string]*NodeRun, len(order)),
+	}
+	for _, name := range order {
+		e.run.Nodes[name] = &NodeRun{Status: NodePending}
+	}
+	if prev := opts.ResumeFrom; prev != nil {
+		e.run.ResumedFrom = prev.ID
+		for name, n := range prev.Nodes {
+			if n.Status == NodeSucceeded && e.run.Nodes[name] != nil {
+				kept := *n
+				kept.Outputs = maps.Clone(n.Outputs)
+				e.run.Nodes[name] = &kept
+			}
+		}
+	}
+	return e, nil
+}
+
+// Order returns the names of the nodes, in topological order.
+func (e *Executor) Order() []string {
+	return e.order
+}
+
+// Updates returns the channel the name of a node is sent to whenever its run
+// changes. It is closed once the workflow is done.
+func (e *Executor) Updates() <-chan string {
+	return e.updates
+}
+
+// Snapshot returns a copy of the record of the run so far.
+func (e *Executor) Snapshot() *Run {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.run.Clone()
+}
+
+// Output returns the lines of the output of the node so far, up to its last
+// OutputLines lines.
+func (e *Executor) Output(name string) []string {
+	e.mu.Lock()
+	out := e.output[name]
+	e.mu.Unlock()
+	if out == nil {
+		return nil
+	}
+	lines, err := out.Lines(out.Dropped(), out.Len())
+	if err != nil {
+		Logger.Error("Error reading the output of a node", "node", name, "error", err)
+	}
+	return lines
+}
+
+// nodeDone is sent when a node is done running.
+type nodeDone struct {
+	name    string
+	outputs map[string]string
+	aborted bool
+	err     error
+}
+
+// Run runs the nodes of the workflow, each one once the ones it depends on
+// succeeded, and returns the record of the run. The nodes depending on a node which
+// failed are skipped, the others still run. Cancelling the context kills the current
+// step of the running nodes and skips the others.
+func (e *Executor) Run(ctx context.Context) *Run {
+	defer close(e.updates)
+	e.save()
+	done := make(chan nodeDone)
+	running := 0
+	for {
+		if ctx.Err() == nil {
+			for _, name := range e.ready(max(e.opts.Parallelism, 1) - running) {
+				running++
+				e.start(ctx, name, done)
+			}
+		}
+		if running == 0 {
+			break
+		}
+		d := <-done
+		running--
+		e.update(d.name, func(n *NodeRun) {
+			n.FinishedAt = time.Now()
+			if d.outputs != nil {
+				n.Outputs = d.outputs
+			}
+			switch {
+			case d.err != nil:
+				n.Status = NodeFailed
+				n.Error = d.err.Error()
+			case d.aborted:
+				n.Status = NodeSkipped
+				n.Error = "workflow aborted"
+			default:
+				n.Status = NodeSucceeded
+			}
+		})
+	}
+
+	e.mu.Lock()
+	status := history.StatusSucceeded
+	for _, name := range e.order {
+		n := e.run.Nodes[name]
+		switch {
+		case n.Status == NodePending && ctx.Err() != nil:
+			n.Status = NodeSkipped
+			n.Error = "workflow aborted"
+		case n.Status == NodePending:
+			n.Status = NodeSkipped
+			n.Error = "a dependency didn't succeed"
+		}
+		if n.Status != NodeSucceeded {
+			status = history.StatusFailed
+		}
+	}
+	if ctx.Err() != nil && status != history.StatusSucceeded {
+		status = history.StatusAborted
+	}
+	e.run.Status = status
+	e.run.FinishedAt = time.Now()
+	run := e.run.Clone()
+	e.mu.Unlock()
+	e.save()
+	return run
+}
+
+// ready returns up to n pending nodes whose dependencies all succeeded, in order.
+func (e *Executor) ready(n int) []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var ready []string
+	for _, name := range e.order {
+		if len(ready) >= n {
+			break
+		}
+		if e.run.Nodes[name].Status != NodePending {
+			continue
+		}
+		node, _ := e.workflow.Node(name)
+		ok := true
+		for _, dep := range node.Dependencies() {
+			if e.run.Nodes[dep].Status != NodeSucceeded {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			ready = append(ready, name)
+		}
+	}
+	return ready
+}
+
+// start runs the recipe of the node in the background.
+func (e *Executor) start(ctx context.Context, name string, done chan<- nodeDone) {
+	node, _ := e.workflow.Node(name)
+	e.mu.Lock()
+	outputs := make(map[string]map[string]string, len(e.run.Nodes))
+	for n, r := range e.run.Nodes {
+		outputs[n] = r.Outputs
+	}
+	e.mu.Unlock()
+	params, err := expandParams(node, outputs)
+	e.update(name, func(n *NodeRun) {
+		n.Status = NodeRunning
+		n.StartedAt = time.Now()
+		n.Error = ""
+	})
+	resume := e.resumedRun(name)
+	go func() {
+		if err != nil {
+			done <- nodeDone{name: name, err: err}
+			return
+		}
+		record, err := runner.Run(ctx, e.recipes[name], runner.Options{
+			Config:     e.opts.Config,
+			History:    e.history,
+			Inputs:     params,
+			ResumeFrom: resume,
+			Output: func(int) io.Writer {
+				return &outputWriter{e: e, node: name}
+			},
+			OnChange: func(record *history.Run) {
+				e.update(name, func(n *NodeRun) {
+					n.RunID = record.ID
+					n.Steps = len(record.Steps)
+					for _, s := range record.Steps {
+						if s.Status != history.StepPending {
+							n.Step = s.Index
+						}
+					}
+				})
+			},
+		})
+		d := nodeDone{name: name, err: err}
+		if record != nil {
+			d.outputs = record.Outputs
+			d.aborted = record.Status == history.StatusAborted
+		}
+		done <- d
+	}()
+}
+
+// resumedRun returns the run of the recipe of the node to resume, the one which
+// failed in the run of the workflow this run resumes.
+func (e *Executor) resumedRun(name string) *history.Run {
+	prev := e.opts.ResumeFrom
+	if prev == nil || e.history == nil {
+		return nil
+	}
+	n, ok := prev.Nodes[name]
+	if !ok || n.Status != NodeFailed || n.RunID == "" {
+		return nil
+	}
+	r, err := e.history.Get(n.RunID)
+	if err != nil {
+		Logger.Warn("Unable to resume node, running it again", "node", name, "error", err)
+		return nil
+	}
+	return r
+}
+
+func (e *Executor) update(name string, f func(n *NodeRun)) {
+	e.mu.Lock()
+	f(e.run.Nodes[name])
+	e.mu.Unlock()
+	e.save()
+	select {
+	case e.updates <- name:
+	default:
+	}
+}
+
+func (e *Executor) save() {
+	if e.store == nil {
+		return
+	}
+	e.saveMu.Lock()
+	defer e.saveMu.Unlock()
+	run := e.Snapshot()
+	if err := e.store.Save(run); err != nil {
+		Logger.Error("Error saving workflow run", "run", run.ID, "error", err)
+	}
+}
+
+// outputWriter appends the output of the steps of a node to its output.
+type outputWriter struct {
+	e    *Executor
+	node string
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.e.mu.Lock()
+	out := w.e.output[w.node]
+	if out == nil {
+		out = scrollback.New(scrollback.WithMaxLines(OutputLines))
+		w.e.output[w.node] = out
+	}
+	w.e.mu.Unlock()
+	return out.Write(p)
+}
@@ -0,0 +1,129 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflow
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/hypershift-community/hyper-console/pkg/config"
+	"github.com/hypershift-community/hyper-console/pkg/history"
+	"github.com/hypershift-community/hyper-console/pkg/recipes"
+)
+
+func newRecipe(t *testing.T, name, taskfile string) recipes.Recipe {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Taskfile.yml"), []byte(taskfile), 0o644))
+	return recipes.Recipe{RecipeInfo: recipes.RecipeInfo{Name: name}, Dir: dir}
+}
+
+func TestExecutor_Run(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "ready")
+	all := []recipes.Recipe{
+		newRecipe(t, "mgmt", `version: '3'
+tasks:
+  default:
+    cmds:
+      - echo "KUBECONFIG=/tmp/mgmt.kubeconfig" >> "$HYPERDEV_OUTPUT"
+`),
+		newRecipe(t, "operator", `version: '3'
+tasks:
+  default:
+    cmds:
+      - test "$KUBECONFIG" = /tmp/mgmt.kubeconfig
+      - test -f `+marker+`
+      - echo "OPERATOR=installed" >> "$HYPERDEV_OUTPUT"
+`),
+		newRecipe(t, "e2e", `version: '3'
+tasks:
+  default:
+    cmds:
+      - test "$OPERATOR" = installed
+`),
+	}
+	w := &Workflow{Name: "dev-loop", Nodes: []Node{
+		{Name: "mgmt", Recipe: "mgmt"},
+		{Name: "operator", Recipe: "operator", Params: map[string]string{"KUBECONFIG": "${mgmt.KUBECONFIG}"}},
+		{Name: "e2e", Recipe: "e2e", Params: map[string]string{"OPERATOR": "${operator.OPERATOR}"}},
+		{Name: "docs", Recipe: "mgmt"},
+	}}
+	cfg := &config.Config{HistoryDir: t.TempDir()}
+
+	e, err := NewExecutor(w, all, Options{Config: cfg, Parallelism: 2})
+	require.NoError(t, err)
+	run := e.Run(context.Background())
+
+	require.Equal(t, history.StatusFailed, run.Status)
+	require.Equal(t, NodeSucceeded, run.Nodes["mgmt"].Status)
+	require.Equal(t, map[string]string{"KUBECONFIG": "/tmp/mgmt.kubeconfig"}, run.Nodes["mgmt"].Outputs)
+	require.Equal(t, NodeFailed, run.Nodes["operator"].Status)
+	require.Equal(t, NodeSkipped, run.Nodes["e2e"].Status)
+	// The nodes which don't depend on the failed one still run
+	require.Equal(t, NodeSucceeded, run.Nodes["docs"].Status)
+
+	store := NewStore(cfg.HistoryDir)
+	saved, err := store.Get(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, run.Status, saved.Status)
+
+	// Resuming runs the failed node from the step which failed and the ones after it
+	require.NoError(t, os.WriteFile(marker, nil, 0o644))
+	e, err = NewExecutor(w, all, Options{Config: cfg, ResumeFrom: saved})
+	require.NoError(t, err)
+	resumed := e.Run(context.Background())
+
+	require.Equal(t, history.StatusSucceeded, resumed.Status)
+	require.Equal(t, run.ID, resumed.ResumedFrom)
+	require.Equal(t, run.Nodes["mgmt"].RunID, resumed.Nodes["mgmt"].RunID)
+	require.Equal(t, NodeSucceeded, resumed.Nodes["e2e"].Status)
+	operator, err := history.NewStore(cfg.HistoryDir).Get(resumed.Nodes["operator"].RunID)
+	require.NoError(t, err)
+	require.Equal(t, run.Nodes["operator"].RunID, operator.ResumedFrom)
+	require.Equal(t, history.StepSucceeded, operator.Steps[1].Status)
+
+	runs, err := store.List("dev-loop")
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+}
+
+func TestNewExecutor_UnknownRecipe(t *testing.T) {
+	w := &Workflow{Name: "dev-loop", Nodes: []Node{{Name: "mgmt", Recipe: "mgmt"}}}
+	_, err := NewExecutor(w, nil, Options{})
+	require.ErrorContains(t, err, "node mgmt of workflow dev-loop runs unknown recipe mgmt")
+}
+
+func TestExecutor_Output(t *testing.T) {
+	all := []recipes.Recipe{newRecipe(t, "logs", `version: '3'
+tasks:
+  default:
+    cmds:
+      - seq 1 5000
+`)}
+	w := &Workflow{Name: "logs", Nodes: []Node{{Name: "logs", Recipe: "logs"}}}
+	e, err := NewExecutor(w, all, Options{})
+	require.NoError(t, err)
+	run := e.Run(context.Background())
+	require.Equal(t, history.StatusSucceeded, run.Status)
+
+	// Only the last lines of the output are kept
+	lines := e.Output("logs")
+	require.LessOrEqual(t, len(lines), OutputLines)
+	require.Equal(t, "5000", lines[len(lines)-1])
+	require.Nil(t, e.Output("missing"))
+}
@@ -0,0 +1,102 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Store keeps the records of the runs of the workflows in the workflows directory
+// of the run history, next to the records of the runs of their recipes.
+type Store struct {
+	dir string
+}
+
+// NewStore creates a store keeping its records in the given run history directory.
+func NewStore(historyDir string) *Store {
+	return &Store{dir: filepath.Join(historyDir, "workflows")}
+}
+
+// Save writes the run record to the store, replacing any previous version of it.
+func (s *Store) Save(r *Run) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("error creating workflow history directory: %w", err)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling workflow run %s: %w", r.ID, err)
+	}
+	path := s.path(r.ID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("error writing workflow run %s: %w", r.ID, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error writing workflow run %s: %w", r.ID, err)
+	}
+	return nil
+}
+
+// Get loads the run with the given ID.
+func (s *Store) Get(id string) (*Run, error) {
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		return nil, fmt.Errorf("error reading workflow run %s: %w", id, err)
+	}
+	var r Run
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("error unmarshalling workflow run %s: %w", id, err)
+	}
+	return &r, nil
+}
+
+// List returns the runs of the given workflow, or of all of them if it is empty,
+// most recent first.
+func (s *Store) List(workflow string) ([]*Run, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading workflow history directory: %w", err)
+	}
+	var runs []*Run
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		r, err := s.Get(strings.TrimSuffix(file.Name(), ".json"))
+		if err != nil {
+			Logger.Warn("Skipping unreadable workflow run record", "file", file.Name(), "error", err)
+			continue
+		}
+		if workflow == "" || r.Workflow == workflow {
+			runs = append(runs, r)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+	return runs, nil
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
@@ -0,0 +1,234 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package workflow chains recipes into a DAG, e.g. create a management cluster,
+// install the HyperShift operator, create a hosted cluster and run the e2e tests.
+// Each node of a workflow runs a recipe in an environment once the nodes it depends
+// on succeeded, and can take their outputs as parameters.
+//
+// Example:
+//
+//	name: dev-loop
+//	display-name: Full dev loop
+//	environment: dev
+//	nodes:
+//	  - name: mgmt
+//	    recipe: hypershift-ci-mgmt-cluster
+//	  - name: operator
+//	    recipe: hypershift-operator
+//	    params:
+//	      KUBECONFIG: ${mgmt.KUBECONFIG}
+//	  - name: e2e
+//	    recipe: hypershift-e2e
+//	    needs: [operator]
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/dominikbraun/graph"
+	"gopkg.in/yaml.v3"
+)
+
+// Workflow is a DAG of recipes.
+type Workflow struct {
+	Name        string `yaml:"name"`
+	DisplayName string `yaml:"display-name"`
+	Description string `yaml:"description"`
+	// Environment is the environment of the nodes which don't set one.
+	Environment string `yaml:"environment,omitempty"`
+	Nodes       []Node `yaml:"nodes"`
+	// Path is the path of the file the workflow is defined in.
+	Path string `yaml:"-"`
+}
+
+// Node runs a recipe as part of a workflow.
+type Node struct {
+	Name        string `yaml:"name"`
+	Recipe      string `yaml:"recipe"`
+	Environment string `yaml:"environment,omitempty"`
+	// Needs are the nodes which must succeed before this one runs.
+	Needs []string `yaml:"needs,omitempty"`
+	// Params are passed to the commands of the recipe as if a previous command had
+	// output them. The values can refer to the outputs of other nodes as
+	// ${node.OUTPUT}, which makes this node depend on them.
+	Params map[string]string `yaml:"params,omitempty"`
+}
+
+// outputRef matches the references to the outputs of other nodes in parameters.
+var outputRef = regexp.MustCompile(`\$\{([A-Za-z0-9_-]+)\.([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Dependencies returns the nodes this node depends on, explicitly or through its
+// parameters, sorted by name.
+func (n *Node) Dependencies() []string {
+	deps := slices.Clone(n.Needs)
+	for _, v := range n.Params {
+		for _, m := range outputRef.FindAllStringSubmatch(v, -1) {
+			deps = append(deps, m[1])
+		}
+	}
+	slices.Sort(deps)
+	return slices.Compact(deps)
+}
+
+// Load loads the workflows defined in the YAML files of the given directory. A
+// missing directory has no workflows.
+func Load(dir string) ([]Workflow, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading workflows directory: %w", err)
+	}
+	var workflows []Workflow
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading workflow %s: %w", path, err)
+		}
+		var w Workflow
+		if err := yaml.Unmarshal(data, &w); err != nil {
+			return nil, fmt.Errorf("error unmarshalling workflow %s: %w", path, err)
+		}
+		w.Path = path
+		if w.Name == "" {
+			w.Name = strings.TrimSuffix(e.Name(), ext)
+		}
+		workflows = append(workflows, w)
+	}
+	return workflows, nil
+}
+
+// Node returns the node with the given name.
+func (w *Workflow) Node(name string) (*Node, bool) {
+	for i := range w.Nodes {
+		if w.Nodes[i].Name == name {
+			return &w.Nodes[i], true
+		}
+	}
+	return nil, false
+}
+
+// EnvironmentOf returns the environment the node runs in.
+func (w *Workflow) EnvironmentOf(n *Node) string {
+	if n.Environment != "" {
+		return n.Environment
+	}
+	return w.Environment
+}
+
+// Graph returns the DAG of the nodes of the workflow, where the edges go from the
+// nodes to the ones depending on them. It fails if a node is unnamed or declared
+// twice, depends on an unknown node or if the dependencies form a cycle.
+func (w *Workflow) Graph() (graph.Graph[string, *Node], error) {
+	g := graph.New(func(n *Node) string { return n.Name }, graph.Directed(), graph.PreventCycles())
+	for i := range w.Nodes {
+		n := &w.Nodes[i]
+		if n.Name == "" {
+			return nil, fmt.Errorf("node %d of workflow %s has no name", i+1, w.Name)
+		}
+		if n.Recipe == "" {
+			return nil, fmt.Errorf("node %s of workflow %s has no recipe", n.Name, w.Name)
+		}
+		if err := g.AddVertex(n); err != nil {
+			if errors.Is(err, graph.ErrVertexAlreadyExists) {
+				return nil, fmt.Errorf("node %s of workflow %s is declared twice", n.Name, w.Name)
+			}
+			return nil, err
+		}
+	}
+	for i := range w.Nodes {
+		n := &w.Nodes[i]
+		for _, dep := range n.Dependencies() {
+			if _, ok := w.Node(dep); !ok {
+				return nil, fmt.Errorf("node %s of workflow %s depends on unknown node %s", n.Name, w.Name, dep)
+			}
+			if err := g.AddEdge(dep, n.Name); err != nil {
+				if errors.Is(err, graph.ErrEdgeCreatesCycle) {
+					return nil, fmt.Errorf("dependency of node %s on %s creates a cycle in workflow %s", n.Name, dep, w.Name)
+				}
+				return nil, err
+			}
+		}
+	}
+	return g, nil
+}
+
+// Order returns the names of the nodes in an order where every node comes after
+// the ones it depends on. The nodes whose dependencies come first are in the order
+// of declaration.
+func (w *Workflow) Order() ([]string, error) {
+	g, err := w.Graph()
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int, len(w.Nodes))
+	for i, n := range w.Nodes {
+		index[n.Name] = i
+	}
+	return graph.StableTopologicalSort(g, func(a, b string) bool {
+		return index[a] < index[b]
+	})
+}
+
+// Depths returns the depth of each node in the DAG: 0 for the nodes without
+// dependencies, or one more than the deepest of their dependencies.
+func (w *Workflow) Depths() (map[string]int, error) {
+	order, err := w.Order()
+	if err != nil {
+		return nil, err
+	}
+	depths := make(map[string]int, len(order))
+	for _, name := range order {
+		n, _ := w.Node(name)
+		depths[name] = 0
+		for _, dep := range n.Dependencies() {
+			depths[name] = max(depths[name], depths[dep]+1)
+		}
+	}
+	return depths, nil
+}
+
+// expandParams expands the references to the outputs of other nodes in the
+// parameters of the node.
+func expandParams(n *Node, outputs map[string]map[string]string) (map[string]string, error) {
+	params := make(map[string]string, len(n.Params))
+	for k, v := range n.Params {
+		var missing []string
+		params[k] = outputRef.ReplaceAllStringFunc(v, func(ref string) string {
+			m := outputRef.FindStringSubmatch(ref)
+			value, ok := outputs[m[1]][m[2]]
+			if !ok {
+				missing = append(missing, m[1]+"."+m[2])
+			}
+			return value
+		})
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("parameter %s refers to missing output(s) %s", k, strings.Join(missing, ", "))
+		}
+	}
+	return params, nil
+}
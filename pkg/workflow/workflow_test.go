@@ -0,0 +1,109 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflow
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "dev-loop.yaml"), []byte(`display-name: Full dev loop
+environment: dev
+nodes:
+  - name: mgmt
+    recipe: mgmt-cluster
+  - name: hosted
+    recipe: hosted-cluster
+    environment: stage
+    params:
+      KUBECONFIG: ${mgmt.KUBECONFIG}
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("Not a workflow"), 0o644))
+
+	workflows, err := Load(dir)
+	require.NoError(t, err)
+	require.Len(t, workflows, 1)
+	w := workflows[0]
+	require.Equal(t, "dev-loop", w.Name)
+	require.Equal(t, filepath.Join(dir, "dev-loop.yaml"), w.Path)
+	hosted, ok := w.Node("hosted")
+	require.True(t, ok)
+	require.Equal(t, []string{"mgmt"}, hosted.Dependencies())
+	require.Equal(t, "stage", w.EnvironmentOf(hosted))
+	mgmt, _ := w.Node("mgmt")
+	require.Equal(t, "dev", w.EnvironmentOf(mgmt))
+
+	workflows, err = Load(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	require.Empty(t, workflows)
+}
+
+func TestWorkflow_Order(t *testing.T) {
+	w := &Workflow{Name: "dev-loop", Nodes: []Node{
+		{Name: "e2e", Recipe: "e2e", Needs: []string{"hosted", "operator"}},
+		{Name: "mgmt", Recipe: "mgmt"},
+		{Name: "hosted", Recipe: "hosted", Params: map[string]string{"KUBECONFIG": "${operator.KUBECONFIG}"}},
+		{Name: "operator", Recipe: "operator", Needs: []string{"mgmt"}},
+		{Name: "docs", Recipe: "docs"},
+	}}
+	order, err := w.Order()
+	require.NoError(t, err)
+	require.Equal(t, []string{"mgmt", "docs", "operator", "hosted", "e2e"}, order)
+
+	depths, err := w.Depths()
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"mgmt": 0, "docs": 0, "operator": 1, "hosted": 2, "e2e": 3}, depths)
+}
+
+func TestWorkflow_Graph_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		nodes []Node
+		err   string
+	}{
+		{
+			name:  "duplicate node",
+			nodes: []Node{{Name: "a", Recipe: "r"}, {Name: "a", Recipe: "r"}},
+			err:   "node a of workflow w is declared twice",
+		},
+		{
+			name:  "unknown dependency",
+			nodes: []Node{{Name: "a", Recipe: "r", Params: map[string]string{"X": "${b.X}"}}},
+			err:   "node a of workflow w depends on unknown node b",
+		},
+		{
+			name:  "cycle",
+			nodes: []Node{{Name: "a", Recipe: "r", Needs: []string{"b"}}, {Name: "b", Recipe: "r", Needs: []string{"a"}}},
+			err:   "creates a cycle in workflow w",
+		},
+		{
+			name:  "missing recipe",
+			nodes: []Node{{Name: "a"}},
+			err:   "node a of workflow w has no recipe",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &Workflow{Name: "w", Nodes: tt.nodes}
+			_, err := w.Graph()
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package workspace creates the directories recipes run in when they are isolated:
+// a copy of the recipe directory per run, so that concurrent or repeated runs don't
+// share the files the commands generate.
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/otiai10/copy"
+)
+
+// stateDir is where the Taskfile keeps the checksums of the sources of its tasks.
+// It isn't copied so that each workspace starts from a clean state.
+const stateDir = ".task"
+
+// Create copies the recipe directory src into a new workspace named id under root
+// and returns its path. The modification times are preserved so that the tasks
+// checking the timestamps of their sources behave as in the recipe directory.
+func Create(root, src, id string) (string, error) {
+	dir := filepath.Join(root, id)
+	if _, err := os.Stat(dir); err == nil {
+		return "", fmt.Errorf("workspace %s already exists", dir)
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return "", fmt.Errorf("error creating workspaces directory: %w", err)
+	}
+	err := copy.Copy(src, dir, copy.Options{
+		PreserveTimes: true,
+		OnSymlink: func(string) copy.SymlinkAction {
+			return copy.Shallow
+		},
+		Skip: func(info os.FileInfo, path, _ string) (bool, error) {
+			return info.IsDir() && info.Name() == stateDir && filepath.Dir(path) == filepath.Clean(src), nil
+		},
+	})
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("error copying recipe into workspace: %w", err)
+	}
+	return dir, nil
+}
+
+// Exists returns true if the workspace at dir is still there, i.e. it was retained
+// after its run.
+func Exists(dir string) bool {
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
+
+// Remove removes the workspace at dir.
+func Remove(dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("error removing workspace: %w", err)
+	}
+	return nil
+}
+
+// Prune removes the oldest workspaces under root so that at most keep of them are
+// left, and returns the paths of the removed ones. The workspaces are ordered by
+// name, which starts with the start time of their run.
+func Prune(root string, keep int) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error listing workspaces: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	if len(names) <= keep {
+		return nil, nil
+	}
+	sort.Strings(names)
+	var removed []string
+	for _, name := range names[:len(names)-max(keep, 0)] {
+		dir := filepath.Join(root, name)
+		if err := Remove(dir); err != nil {
+			return removed, err
+		}
+		removed = append(removed, dir)
+	}
+	return removed, nil
+}
@@ -0,0 +1,74 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreate(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "Taskfile.yml"), []byte("version: '3'\n"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "manifests"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "manifests", "cluster.yaml"), []byte("kind: HostedCluster\n"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(src, ".task", "checksum"), 0o755))
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, os.Chtimes(filepath.Join(src, "Taskfile.yml"), modTime, modTime))
+
+	root := filepath.Join(t.TempDir(), "workspaces")
+	dir, err := Create(root, src, "20250101-120000-abcd")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(root, "20250101-120000-abcd"), dir)
+
+	data, err := os.ReadFile(filepath.Join(dir, "manifests", "cluster.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, "kind: HostedCluster\n", string(data))
+	info, err := os.Stat(filepath.Join(dir, "Taskfile.yml"))
+	require.NoError(t, err)
+	require.True(t, info.ModTime().Equal(modTime))
+	require.NoDirExists(t, filepath.Join(dir, ".task"))
+
+	// Writing to the workspace leaves the recipe untouched
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "kubeconfig"), []byte("apiVersion: v1\n"), 0o600))
+	require.NoFileExists(t, filepath.Join(src, "kubeconfig"))
+
+	_, err = Create(root, src, "20250101-120000-abcd")
+	require.Error(t, err)
+}
+
+func TestPrune(t *testing.T) {
+	root := t.TempDir()
+	for _, id := range []string{"20250103-000000-cccc", "20250101-000000-aaaa", "20250102-000000-bbbb"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, id), 0o755))
+	}
+
+	removed, err := Prune(root, 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(root, "20250101-000000-aaaa"),
+		filepath.Join(root, "20250102-000000-bbbb"),
+	}, removed)
+	require.True(t, Exists(filepath.Join(root, "20250103-000000-cccc")))
+	require.False(t, Exists(filepath.Join(root, "20250101-000000-aaaa")))
+
+	removed, err = Prune(filepath.Join(root, "missing"), 1)
+	require.NoError(t, err)
+	require.Empty(t, removed)
+}